@@ -9,6 +9,8 @@ import org.springframework.web.client.RestTemplate;
 import io.jaegertracing.Configuration;
 import io.jaegertracing.Configuration.ReporterConfiguration;
 import io.jaegertracing.Configuration.SamplerConfiguration;
+import io.jaegertracing.internal.propagation.TextMapCodec;
+import io.opentracing.propagation.Format;
 
 @SpringBootApplication
 public class Application {
@@ -18,7 +20,12 @@ public class Application {
 		SamplerConfiguration samplerConfig = new SamplerConfiguration().withType("const").withParam(1);
 		ReporterConfiguration reporterConfig = ReporterConfiguration.fromEnv().withLogSpans(true);
 
-		return Configuration.fromEnv("customer").withSampler(samplerConfig).withReporter(reporterConfig).getTracer();
+		// Baggage is restricted on the HTTP requests the service receives,
+		// which carry it in Jaeger headers.
+		return Configuration.fromEnv("customer").withSampler(samplerConfig).withReporter(reporterConfig)
+			.getTracerBuilder()
+			.registerExtractor(Format.Builtin.HTTP_HEADERS, BaggageRestrictions.fromEnv(new TextMapCodec(true)))
+			.build();
 	}
 
 	@Bean
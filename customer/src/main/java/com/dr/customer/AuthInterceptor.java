@@ -0,0 +1,71 @@
+package com.dr.customer;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+import javax.servlet.http.HttpServletRequest;
+import javax.servlet.http.HttpServletResponse;
+
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.context.annotation.Configuration;
+import org.springframework.web.servlet.HandlerInterceptor;
+import org.springframework.web.servlet.config.annotation.InterceptorRegistry;
+import org.springframework.web.servlet.config.annotation.WebMvcConfigurer;
+
+import io.opentracing.Span;
+import io.opentracing.Tracer;
+import io.opentracing.tag.Tags;
+
+/**
+ * Validates the bearer token of each request that has one, and tags the request span with
+ * its user, which it also sets as baggage. Requests with an invalid or expired token are
+ * rejected with 401 Unauthorized. Requests without a token are served anonymously.
+ * Tokens are not checked unless JWT_SECRET is set.
+ */
+@Configuration
+public class AuthInterceptor implements HandlerInterceptor, WebMvcConfigurer {
+    private final TokenVerifier verifier = TokenVerifier.fromEnv();
+
+    @Autowired
+    private Tracer tracer;
+
+    @Override
+    public void addInterceptors(InterceptorRegistry registry) {
+        registry.addInterceptor(this);
+    }
+
+    @Override
+    public boolean preHandle(HttpServletRequest request, HttpServletResponse response, Object handler) throws Exception {
+        if (verifier == null) {
+            return true;
+        }
+        Span span = tracer.activeSpan();
+        String authorization = request.getHeader("Authorization");
+        if (authorization == null || !authorization.regionMatches(true, 0, "Bearer ", 0, 7)) {
+            if (span != null && span.getBaggageItem("user") != null) {
+                span.setBaggageItem("user", "");
+            }
+            return true;
+        }
+        String user;
+        try {
+            user = verifier.verify(authorization.substring(7));
+        } catch (TokenVerifier.InvalidTokenException e) {
+            if (span != null) {
+                Tags.ERROR.set(span, true);
+                Map<String, String> fields = new LinkedHashMap<>();
+                fields.put("event", "invalid token");
+                fields.put("message", e.getMessage());
+                span.log(fields);
+            }
+            response.setHeader("WWW-Authenticate", "Bearer error=\"invalid_token\"");
+            response.sendError(HttpServletResponse.SC_UNAUTHORIZED, "invalid token: " + e.getMessage());
+            return false;
+        }
+        if (span != null) {
+            span.setTag("user", user);
+            span.setBaggageItem("user", user);
+        }
+        return true;
+    }
+}
@@ -0,0 +1,141 @@
+package com.dr.customer;
+
+import java.io.UnsupportedEncodingException;
+import java.net.URLDecoder;
+import java.net.URLEncoder;
+import java.util.AbstractMap;
+import java.util.ArrayList;
+import java.util.HashSet;
+import java.util.Iterator;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.Set;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+
+import io.jaegertracing.internal.JaegerSpanContext;
+import io.jaegertracing.spi.Extractor;
+import io.opentracing.propagation.TextMap;
+
+/**
+ * Drops the baggage items callers send that are not allowed, and truncates values that are
+ * too long, before the wrapped extractor reads the headers. The restrictions are those of the
+ * Go services, read from BAGGAGE_ALLOWED_KEYS and BAGGAGE_MAX_VALUE_LENGTH.
+ */
+public class BaggageRestrictions implements Extractor<TextMap> {
+    private static final Logger log = LoggerFactory.getLogger(BaggageRestrictions.class);
+
+    private static final String DEFAULT_ALLOWED_KEYS = "customer,session,request,log-level,synthetic";
+    private static final int DEFAULT_MAX_VALUE_LENGTH = 256;
+    private static final String BAGGAGE_PREFIX = "uberctx-";
+    private static final String BAGGAGE_HEADER = "jaeger-baggage";
+
+    private final Extractor<TextMap> extractor;
+    private final Set<String> allowed = new HashSet<>();
+    private final int maxValueLength;
+
+    public BaggageRestrictions(Extractor<TextMap> extractor, String allowedKeys, int maxValueLength) {
+        this.extractor = extractor;
+        for (String key : allowedKeys.split(",")) {
+            allowed.add(key.trim());
+        }
+        this.maxValueLength = maxValueLength;
+    }
+
+    /**
+     * Returns restrictions wrapping extractor, read from the env vars or the defaults.
+     */
+    public static BaggageRestrictions fromEnv(Extractor<TextMap> extractor) {
+        String keys = System.getenv("BAGGAGE_ALLOWED_KEYS");
+        if (keys == null || keys.isEmpty()) {
+            keys = DEFAULT_ALLOWED_KEYS;
+        }
+        int length = DEFAULT_MAX_VALUE_LENGTH;
+        String value = System.getenv("BAGGAGE_MAX_VALUE_LENGTH");
+        if (value != null && !value.isEmpty()) {
+            length = Integer.parseInt(value);
+        }
+        return new BaggageRestrictions(extractor, keys, length);
+    }
+
+    @Override
+    public JaegerSpanContext extract(TextMap carrier) {
+        List<Map.Entry<String, String>> entries = new ArrayList<>();
+        for (Map.Entry<String, String> entry : carrier) {
+            String key = entry.getKey().toLowerCase(Locale.ROOT);
+            String value = entry.getValue();
+            if (key.startsWith(BAGGAGE_PREFIX)) {
+                value = restrictItem(key.substring(BAGGAGE_PREFIX.length()), decode(value));
+                if (value == null) {
+                    continue;
+                }
+                value = encode(value);
+            } else if (key.equals(BAGGAGE_HEADER)) {
+                value = restrictList(value);
+            }
+            entries.add(new AbstractMap.SimpleImmutableEntry<>(entry.getKey(), value));
+        }
+        return extractor.extract(new TextMap() {
+            @Override
+            public Iterator<Map.Entry<String, String>> iterator() {
+                return entries.iterator();
+            }
+
+            @Override
+            public void put(String key, String value) {
+                throw new UnsupportedOperationException("carrier is read-only");
+            }
+        });
+    }
+
+    /**
+     * Filters the "key1=value1, key2=value2" list of the jaeger-baggage header.
+     */
+    private String restrictList(String list) {
+        List<String> items = new ArrayList<>();
+        for (String item : list.split(",")) {
+            String[] kv = item.trim().split("=", 2);
+            if (kv.length != 2) {
+                continue;
+            }
+            String value = restrictItem(kv[0], kv[1]);
+            if (value != null) {
+                items.add(kv[0] + "=" + value);
+            }
+        }
+        return String.join(", ", items);
+    }
+
+    /**
+     * Returns the value to keep for a baggage item, or null if it must be dropped.
+     */
+    private String restrictItem(String key, String value) {
+        if (!allowed.contains(key)) {
+            log.info("Baggage item restricted: reason=key_not_allowed key={}", key);
+            return null;
+        }
+        if (value.length() > maxValueLength) {
+            log.info("Baggage item restricted: reason=value_too_long key={}", key);
+            return value.substring(0, maxValueLength);
+        }
+        return value;
+    }
+
+    private static String decode(String value) {
+        try {
+            return URLDecoder.decode(value, "UTF-8");
+        } catch (UnsupportedEncodingException | IllegalArgumentException e) {
+            return value;
+        }
+    }
+
+    private static String encode(String value) {
+        try {
+            return URLEncoder.encode(value, "UTF-8");
+        } catch (UnsupportedEncodingException e) {
+            return value;
+        }
+    }
+}
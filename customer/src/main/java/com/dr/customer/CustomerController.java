@@ -1,24 +1,33 @@
 package com.dr.customer;
 
 import java.net.URI;
+import java.security.GeneralSecurityException;
 import java.util.Collections;
 import java.util.LinkedHashMap;
 import java.util.Map;
+import java.util.concurrent.ThreadLocalRandom;
 
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
 import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.http.HttpStatus;
 import org.springframework.http.ResponseEntity;
 import org.springframework.web.bind.annotation.GetMapping;
 import org.springframework.web.bind.annotation.RequestParam;
 import org.springframework.web.bind.annotation.RestController;
 import org.springframework.web.client.RestTemplate;
+import org.springframework.web.server.ResponseStatusException;
 import org.springframework.web.util.UriComponentsBuilder;
 
 import io.opentracing.Scope;
 import io.opentracing.Span;
 import io.opentracing.Tracer;
+import io.opentracing.tag.Tags;
 
 @RestController
 public class CustomerController {
+    private static final Logger log = LoggerFactory.getLogger(CustomerController.class);
     private static final Map<String, Customer> demoCustomers = new LinkedHashMap<String, Customer>();
 
     static {
@@ -28,28 +37,44 @@ public class CustomerController {
         demoCustomers.put("731", new Customer("731", "Japanese Desserts", "728,326"));
     }
 
+    private final FieldCipher fieldCipher = FieldCipher.fromEnv();
+
     @Autowired
     private RestTemplate restTemplate;
 
     @Autowired
     private Tracer tracer;
 
+    // Fraction (0..1) of requests failed with 500 Internal Server Error, set
+    // with --error-rate=0.1 or ERROR_RATE.
+    @Value("${error-rate:0}")
+    private double errorRate;
+
     @GetMapping("/customer")
     public Customer get(@RequestParam(value="customer", defaultValue="") String id) {
         try (Scope scope = tracer.buildSpan("get-customer-handler").startActive(true)) {
           Span span = scope.span();
+          tagBaggage(span);
           Map<String, String> fields = new LinkedHashMap<>();
           fields.put("event", "request_params_parsed");
           fields.put("customer_id", id);
           span.log(fields);
 
+          if (ThreadLocalRandom.current().nextDouble() < errorRate) {
+            Tags.ERROR.set(span, true);
+            span.log("chaos: injected error");
+            throw new ResponseStatusException(HttpStatus.INTERNAL_SERVER_ERROR, "chaos: injected error");
+          }
+
           Customer customer = demoCustomers.get(id);
 
           if (customer == null) {
             customer = demoCustomers.get("123");
           }
+          debug(span, "Found customer {} for id {}", customer.getName(), id);
       
           long delay = fetchDelay();
+          debug(span, "Sleeping for {}ms", delay);
       
           try {
             Thread.sleep(delay);
@@ -57,12 +82,44 @@ public class CustomerController {
             e.printStackTrace();
           }
       
-          span.setTag("response", customer.toString());
+          // Only the fields that aren't sensitive: the location is encrypted
+          // in transit, and must not land in traces either.
+          span.setTag("response", "Customer{id=" + customer.getId() + ", name=" + customer.getName() + "}");
+
+          if (fieldCipher != null) {
+            customer = encryptFields(customer);
+          }
           
           return customer;
       }
     }
 
+    private Customer encryptFields(Customer customer) {
+        try (Scope scope = tracer.buildSpan("encrypt-fields").startActive(true)) {
+            scope.span().setTag("fields", "location");
+            return new Customer(customer.getId(), customer.getName(), fieldCipher.encrypt(customer.getLocation()));
+        } catch (GeneralSecurityException e) {
+            throw new IllegalStateException("cannot encrypt customer fields", e);
+        }
+    }
+
+    // Tags the span with the customer, session and user baggage items the frontend sets
+    private void tagBaggage(Span span) {
+        for (String key : new String[] {"customer", "session", "user"}) {
+            String value = span.getBaggageItem(key);
+            if (value != null && !value.isEmpty()) {
+                span.setTag(key, value);
+            }
+        }
+    }
+
+    // Only logs when the request asked for debug logs via the log-level baggage item
+    private void debug(Span span, String format, Object... args) {
+        if ("debug".equals(span.getBaggageItem("log-level"))) {
+            log.info("DEBUG " + format, args);
+        }
+    }
+
     private long fetchDelay() {
         try (Scope scope = tracer.buildSpan("fetch-delay").startActive(true)) {
             Span span = scope.span();
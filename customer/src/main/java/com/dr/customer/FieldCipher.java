@@ -0,0 +1,51 @@
+package com.dr.customer;
+
+import java.nio.charset.StandardCharsets;
+import java.security.GeneralSecurityException;
+import java.security.SecureRandom;
+import java.util.Base64;
+
+import javax.crypto.Cipher;
+import javax.crypto.spec.GCMParameterSpec;
+import javax.crypto.spec.SecretKeySpec;
+
+/**
+ * Encrypts individual response fields with AES-GCM using a key shared with the callers.
+ * Encrypted fields are base64 encoded and carry the nonce in front of the ciphertext.
+ */
+public class FieldCipher {
+    private static final int NONCE_LENGTH = 12;
+    private static final int TAG_LENGTH_BITS = 128;
+
+    private final SecretKeySpec key;
+    private final SecureRandom random = new SecureRandom();
+
+    public FieldCipher(String base64Key) {
+        this.key = new SecretKeySpec(Base64.getDecoder().decode(base64Key), "AES");
+    }
+
+    /**
+     * Returns a cipher using the FIELD_ENCRYPTION_KEY env var, or null if it is not set.
+     */
+    public static FieldCipher fromEnv() {
+        String key = System.getenv("FIELD_ENCRYPTION_KEY");
+        if (key == null || key.isEmpty()) {
+            return null;
+        }
+        return new FieldCipher(key);
+    }
+
+    public String encrypt(String plaintext) throws GeneralSecurityException {
+        byte[] nonce = new byte[NONCE_LENGTH];
+        random.nextBytes(nonce);
+
+        Cipher cipher = Cipher.getInstance("AES/GCM/NoPadding");
+        cipher.init(Cipher.ENCRYPT_MODE, key, new GCMParameterSpec(TAG_LENGTH_BITS, nonce));
+        byte[] ciphertext = cipher.doFinal(plaintext.getBytes(StandardCharsets.UTF_8));
+
+        byte[] out = new byte[nonce.length + ciphertext.length];
+        System.arraycopy(nonce, 0, out, 0, nonce.length);
+        System.arraycopy(ciphertext, 0, out, nonce.length, ciphertext.length);
+        return Base64.getEncoder().encodeToString(out);
+    }
+}
@@ -0,0 +1,72 @@
+package com.dr.customer;
+
+import java.io.IOException;
+import java.net.InetAddress;
+import java.net.InetSocketAddress;
+import java.net.Socket;
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+
+import org.springframework.http.HttpStatus;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.GetMapping;
+import org.springframework.web.bind.annotation.RestController;
+
+@RestController
+public class HealthController {
+    private static final int READINESS_TIMEOUT_MS = 2000;
+
+    @GetMapping("/healthz")
+    public String healthz() {
+        return "ok\n";
+    }
+
+    // Checks that the delay service accepts connections and that the Jaeger
+    // agent host resolves; the agent is reached over UDP, so it cannot be
+    // probed with a connection.
+    @GetMapping("/readyz")
+    public ResponseEntity<Map<String, Object>> readyz() {
+        String delayHost = getenv("DELAY_SERVICE_HOST", "customer-delay");
+        int delayPort = Integer.parseInt(getenv("DELAY_SERVICE_PORT", "8085"));
+        String agentHost = getenv("JAEGER_AGENT_HOST", "localhost");
+
+        List<Map<String, Object>> checks = new ArrayList<>();
+        checks.add(check("customer-delay", delayHost + ":" + delayPort, () -> {
+            try (Socket socket = new Socket()) {
+                socket.connect(new InetSocketAddress(delayHost, delayPort), READINESS_TIMEOUT_MS);
+            }
+        }));
+        checks.add(check("jaeger-agent", agentHost, () -> InetAddress.getByName(agentHost)));
+
+        boolean ready = checks.stream().allMatch(c -> Boolean.TRUE.equals(c.get("OK")));
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("Ready", ready);
+        body.put("Checks", checks);
+        return new ResponseEntity<>(body, ready ? HttpStatus.OK : HttpStatus.SERVICE_UNAVAILABLE);
+    }
+
+    private interface Probe {
+        void run() throws IOException;
+    }
+
+    private static Map<String, Object> check(String name, String target, Probe probe) {
+        Map<String, Object> result = new LinkedHashMap<>();
+        result.put("Name", name);
+        result.put("Target", target);
+        try {
+            probe.run();
+            result.put("OK", true);
+        } catch (IOException e) {
+            result.put("OK", false);
+            result.put("Error", e.toString());
+        }
+        return result;
+    }
+
+    private static String getenv(String name, String fallback) {
+        String value = System.getenv(name);
+        return value != null ? value : fallback;
+    }
+}
@@ -0,0 +1,102 @@
+package com.dr.customer;
+
+import java.nio.charset.StandardCharsets;
+import java.security.GeneralSecurityException;
+import java.security.MessageDigest;
+import java.util.Base64;
+
+import javax.crypto.Mac;
+import javax.crypto.spec.SecretKeySpec;
+
+import com.fasterxml.jackson.databind.JsonNode;
+import com.fasterxml.jackson.databind.ObjectMapper;
+
+/**
+ * Verifies the HS256 JSON web tokens the frontend issues to its users, with the secret
+ * shared by all services.
+ */
+public class TokenVerifier {
+    private static final String HEADER = Base64.getUrlEncoder().withoutPadding()
+        .encodeToString("{\"alg\":\"HS256\",\"typ\":\"JWT\"}".getBytes(StandardCharsets.UTF_8));
+
+    private final SecretKeySpec key;
+    private final ObjectMapper mapper = new ObjectMapper();
+
+    public TokenVerifier(String secret) {
+        this.key = new SecretKeySpec(secret.getBytes(StandardCharsets.UTF_8), "HmacSHA256");
+    }
+
+    /**
+     * Returns a verifier using the JWT_SECRET env var, or null if it is not set.
+     */
+    public static TokenVerifier fromEnv() {
+        String secret = System.getenv("JWT_SECRET");
+        if (secret == null || secret.isEmpty()) {
+            return null;
+        }
+        return new TokenVerifier(secret);
+    }
+
+    /**
+     * Checks the signature and expiry of token, and returns its user, the sub claim. The rules
+     * are those of "Token format" in the README, which api/auth/jwt-vectors.json tests.
+     */
+    public String verify(String token) throws InvalidTokenException {
+        return verify(token, System.currentTimeMillis() / 1000);
+    }
+
+    /**
+     * Verifies token at the Unix time now.
+     */
+    public String verify(String token, long now) throws InvalidTokenException {
+        String[] parts = token.split("\\.", -1);
+        if (parts.length != 3 || !parts[0].equals(HEADER)) {
+            throw new InvalidTokenException("malformed");
+        }
+        byte[] expected;
+        try {
+            Mac mac = Mac.getInstance("HmacSHA256");
+            mac.init(key);
+            expected = Base64.getUrlEncoder().withoutPadding()
+                .encode(mac.doFinal((parts[0] + "." + parts[1]).getBytes(StandardCharsets.UTF_8)));
+        } catch (GeneralSecurityException e) {
+            throw new IllegalStateException("cannot sign with HmacSHA256", e);
+        }
+        if (!MessageDigest.isEqual(expected, parts[2].getBytes(StandardCharsets.UTF_8))) {
+            throw new InvalidTokenException("bad_signature");
+        }
+        // The decoder also takes padded base64url, which tokens must not use.
+        if (parts[1].indexOf('=') >= 0) {
+            throw new InvalidTokenException("malformed");
+        }
+        JsonNode claims;
+        try {
+            claims = mapper.readTree(Base64.getUrlDecoder().decode(parts[1]));
+        } catch (Exception e) {
+            throw new InvalidTokenException("malformed");
+        }
+        if (claims == null) {
+            throw new InvalidTokenException("malformed");
+        }
+        JsonNode sub = claims.path("sub");
+        JsonNode exp = claims.path("exp");
+        if (!claims.path("iss").isTextual() || !"hotrod".equals(claims.path("iss").asText())
+            || !sub.isTextual() || sub.asText().isEmpty()
+            || !exp.isIntegralNumber() || !exp.canConvertToLong() || exp.asLong() <= 0) {
+            throw new InvalidTokenException("malformed");
+        }
+        if (now >= exp.asLong()) {
+            throw new InvalidTokenException("expired");
+        }
+        return sub.asText();
+    }
+
+    /**
+     * Thrown for tokens that are malformed, badly signed or expired.
+     */
+    public static class InvalidTokenException extends Exception {
+        public InvalidTokenException(String reason) {
+            super(reason);
+        }
+    }
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// candidate is a driver that could serve a dispatch.
+type candidate struct {
+	dispatch int
+	driver   Driver
+	distance float64
+}
+
+// AssignDrivers implements gRPC driver interface. It assigns one driver to
+// every dispatch of the batch in two phases, each traced as its own span:
+// candidates are looked up near every dispatch, then matched greedily,
+// closest pair first, so that no driver is assigned twice. Dispatches left
+// without a driver get an assignment with an empty DriverID.
+func (s *Server) AssignDrivers(ctx context.Context, req *AssignDriversRequest) (*AssignDriversResponse, error) {
+	s.logger.For(ctx).Info("Assigning drivers", zap.Int("num_dispatches", len(req.Dispatches)))
+
+	candidates, err := s.findCandidates(ctx, req.Dispatches)
+	if err != nil {
+		return nil, err
+	}
+	assignments := s.match(ctx, req.Dispatches, candidates)
+
+	s.logger.For(ctx).Info("Assignment successful", zap.Int("num_assignments", len(assignments)))
+
+	return &AssignDriversResponse{Assignments: assignments}, nil
+}
+
+// findCandidates returns the drivers near each dispatch, with their distance.
+func (s *Server) findCandidates(ctx context.Context, dispatches []*Dispatch) ([]candidate, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, s.tracer, "AssignDrivers: find candidates")
+	defer span.Finish()
+
+	var candidates []candidate
+	for i, dispatch := range dispatches {
+		drivers, err := s.findDrivers(ctx, dispatch.Location)
+		if err != nil {
+			return nil, err
+		}
+		for _, drv := range drivers {
+			candidates = append(candidates, candidate{
+				dispatch: i,
+				driver:   drv,
+				distance: distance(dispatch.Location, drv.Location),
+			})
+		}
+	}
+	span.SetTag("candidates", len(candidates))
+
+	return candidates, nil
+}
+
+// match assigns the closest free driver to each dispatch, considering all
+// candidate pairs from the shortest distance up.
+func (s *Server) match(ctx context.Context, dispatches []*Dispatch, candidates []candidate) []*Assignment {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, s.tracer, "AssignDrivers: match")
+	defer span.Finish()
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	assignments := make([]*Assignment, len(dispatches))
+	for i, dispatch := range dispatches {
+		assignments[i] = &Assignment{DispatchID: dispatch.DispatchID}
+	}
+	taken := make(map[string]bool)
+	assigned := 0
+	for _, c := range candidates {
+		if assignments[c.dispatch].DriverID != "" || taken[c.driver.DriverID] {
+			continue
+		}
+		taken[c.driver.DriverID] = true
+		assignments[c.dispatch].DriverID = c.driver.DriverID
+		assignments[c.dispatch].DriverLocation = c.driver.Location
+		assignments[c.dispatch].Distance = c.distance
+		assigned++
+	}
+	span.SetTag("assigned", assigned)
+	span.SetTag("unassigned", len(dispatches)-assigned)
+
+	return assignments
+}
+
+// distance returns the distance between two "x,y" locations, or +Inf if
+// either cannot be parsed.
+func distance(from, to string) float64 {
+	x1, y1, ok1 := parseLocation(from)
+	x2, y2, ok2 := parseLocation(to)
+	if !ok1 || !ok2 {
+		return math.Inf(1)
+	}
+	return math.Hypot(x2-x1, y2-y1)
+}
+
+func parseLocation(location string) (x, y float64, ok bool) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	return x, y, errX == nil && errY == nil
+}
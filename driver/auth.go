@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// JWTSecret is the secret the frontend signs the tokens of its users with.
+// Empty disables authentication.
+var JWTSecret string
+
+// userBaggageKey carries the user of a call to the services downstream.
+const userBaggageKey = "user"
+
+// tokenHeader is the JOSE header of the tokens the frontend issues,
+// base64url encoded.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// claims are the claims of the tokens the frontend issues that services
+// check.
+type claims struct {
+	Issuer    string
+	Subject   string
+	ExpiresAt int64
+}
+
+// authenticate validates the bearer token in the authorization metadata
+// of calls that have one, and tags their span with its user, which it also
+// sets as baggage. Calls with an invalid or expired token fail with
+// Unauthenticated. Calls without a token are served anonymously. It must
+// come after the tracing interceptor, so the server span is in the context.
+func authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if JWTSecret == "" {
+		return handler(ctx, req)
+	}
+	span := opentracing.SpanFromContext(ctx)
+	token := bearerToken(ctx)
+	if token == "" {
+		if span != nil && span.BaggageItem(userBaggageKey) != "" {
+			span.SetBaggageItem(userBaggageKey, "")
+		}
+		return handler(ctx, req)
+	}
+	c, err := verifyToken(token)
+	if err != nil {
+		if span != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "invalid token"), log.Error(err))
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid token: "+err.Error())
+	}
+	if span != nil {
+		span.SetTag(userBaggageKey, c.Subject)
+		span.SetBaggageItem(userBaggageKey, c.Subject)
+	}
+	return handler(ctx, req)
+}
+
+// bearerToken returns the token of the authorization metadata of ctx, or
+// "" if there is none.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("authorization")) == 0 {
+		return ""
+	}
+	parts := strings.SplitN(md.Get("authorization")[0], " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return parts[1]
+}
+
+// verifyToken checks the HS256 signature and the expiry of token, and
+// returns its claims. The rules are those of "Token format" in the README,
+// which api/auth/jwt-vectors.json tests.
+func verifyToken(token string) (claims, error) {
+	return verifyTokenAt(token, time.Now().Unix())
+}
+
+// verifyTokenAt is verifyToken at the Unix time now.
+func verifyTokenAt(token string, now int64) (claims, error) {
+	var c claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return c, errors.New("malformed")
+	}
+	mac := hmac.New(sha256.New, []byte(JWTSecret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal([]byte(base64.RawURLEncoding.EncodeToString(mac.Sum(nil))), []byte(parts[2])) {
+		return c, errors.New("bad_signature")
+	}
+	payload, err := base64.RawURLEncoding.Strict().DecodeString(parts[1])
+	if err != nil || !parseClaims(payload, &c) || c.Issuer != "hotrod" || c.Subject == "" || c.ExpiresAt <= 0 {
+		return c, errors.New("malformed")
+	}
+	if now >= c.ExpiresAt {
+		return c, errors.New("expired")
+	}
+	return c, nil
+}
+
+// parseClaims decodes the iss, sub and exp claims of payload, a JSON
+// object, into c. Unlike json.Unmarshal, it matches their names exactly.
+func parseClaims(payload []byte, c *claims) bool {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(payload, &raw) != nil {
+		return false
+	}
+	for name, v := range map[string]interface{}{"iss": &c.Issuer, "sub": &c.Subject, "exp": &c.ExpiresAt} {
+		if value, ok := raw[name]; ok && json.Unmarshal(value, v) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorRate is the fraction (0..1) of calls that injectErrors fails.
+var ErrorRate float64
+
+// injectErrors fails calls at ErrorRate with codes.Internal, gRPC's
+// equivalent of a 500, before they reach the handler. It must come after
+// the tracing interceptor, which tags the span of the failed call as an error.
+func injectErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	// #nosec
+	if ErrorRate <= 0 || rand.Float64() >= ErrorRate {
+		return handler(ctx, req)
+	}
+	err := status.Error(codes.Internal, "chaos: injected error")
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.LogFields(log.String("event", "chaos: injected error"), log.Error(err))
+	}
+	return nil, err
+}
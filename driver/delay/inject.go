@@ -0,0 +1,117 @@
+package delay
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// Injected is an artificial latency: Base plus a random jitter of up to
+// Jitter either way, never less than zero.
+type Injected struct {
+	Base   time.Duration
+	Jitter time.Duration
+}
+
+// String formats the delay as Parse reads it.
+func (d Injected) String() string {
+	if d.Jitter == 0 {
+		return d.Base.String()
+	}
+	return d.Base.String() + "±" + d.Jitter.String()
+}
+
+func (d Injected) sample() time.Duration {
+	delay := d.Base
+	if d.Jitter > 0 {
+		// #nosec
+		delay += time.Duration(rand.Int63n(int64(2*d.Jitter)+1)) - d.Jitter
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Injections are the artificial latencies added to the calls to each
+// dependency, by dependency name. A nil Injections adds none.
+type Injections map[string]Injected
+
+// Parse parses "dependency=delay,..." as given to --inject-delay, e.g.
+// "redis=30ms±10ms". The jitter may also follow "+-". The format is the
+// one the frontend's --inject-delay reads.
+func Parse(s string) (Injections, error) {
+	delays := make(Injections)
+	if s == "" {
+		return delays, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		target, value, ok := cut(pair, "=")
+		target = strings.TrimSpace(target)
+		if !ok || target == "" {
+			return nil, fmt.Errorf("invalid delay %q, want dependency=delay", pair)
+		}
+		base, jitter, hasJitter := cut(value, "±")
+		if !hasJitter {
+			base, jitter, hasJitter = cut(value, "+-")
+		}
+		var d Injected
+		var err error
+		if d.Base, err = time.ParseDuration(strings.TrimSpace(base)); err != nil || d.Base < 0 {
+			return nil, fmt.Errorf("invalid delay %q, want dependency=delay", pair)
+		}
+		if hasJitter {
+			if d.Jitter, err = time.ParseDuration(strings.TrimSpace(jitter)); err != nil || d.Jitter < 0 {
+				return nil, fmt.Errorf("invalid jitter in delay %q", pair)
+			}
+		}
+		delays[target] = d
+	}
+	return delays, nil
+}
+
+// cut slices s around the first sep, like strings.Cut in newer Go.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Format formats delays as Parse reads them, sorted by dependency.
+func (delays Injections) Format() string {
+	pairs := make([]string, 0, len(delays))
+	for target, d := range delays {
+		pairs = append(pairs, target+"="+d.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Inject waits for the delay of target, if it has one, and logs it on the
+// span in ctx. It returns ctx.Err() early if ctx is done first.
+func (delays Injections) Inject(ctx context.Context, target string) error {
+	d, ok := delays[target]
+	if !ok {
+		return nil
+	}
+	delay := d.sample()
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("chaos.delay", delay.String())
+		span.LogFields(log.String("event", "chaos: injected delay"), log.String("delay", delay.String()))
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
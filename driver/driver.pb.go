@@ -1,12 +1,16 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: pkg/clients/driver.proto
+
 package main
 
 import (
 	context "context"
 	fmt "fmt"
-	math "math"
-
 	proto "github.com/gogo/protobuf/proto"
 	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -18,7 +22,7 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type DriverLocationRequest struct {
 	Location             string   `protobuf:"bytes,1,opt,name=location,proto3" json:"location,omitempty"`
@@ -31,7 +35,7 @@ func (m *DriverLocationRequest) Reset()         { *m = DriverLocationRequest{} }
 func (m *DriverLocationRequest) String() string { return proto.CompactTextString(m) }
 func (*DriverLocationRequest) ProtoMessage()    {}
 func (*DriverLocationRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cdcd28b7ebdcd54f, []int{0}
+	return fileDescriptor_8904d12f7036c08a, []int{0}
 }
 func (m *DriverLocationRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DriverLocationRequest.Unmarshal(m, b)
@@ -70,7 +74,7 @@ func (m *DriverLocation) Reset()         { *m = DriverLocation{} }
 func (m *DriverLocation) String() string { return proto.CompactTextString(m) }
 func (*DriverLocation) ProtoMessage()    {}
 func (*DriverLocation) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cdcd28b7ebdcd54f, []int{1}
+	return fileDescriptor_8904d12f7036c08a, []int{1}
 }
 func (m *DriverLocation) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DriverLocation.Unmarshal(m, b)
@@ -115,7 +119,7 @@ func (m *DriverLocationResponse) Reset()         { *m = DriverLocationResponse{}
 func (m *DriverLocationResponse) String() string { return proto.CompactTextString(m) }
 func (*DriverLocationResponse) ProtoMessage()    {}
 func (*DriverLocationResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_cdcd28b7ebdcd54f, []int{2}
+	return fileDescriptor_8904d12f7036c08a, []int{2}
 }
 func (m *DriverLocationResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DriverLocationResponse.Unmarshal(m, b)
@@ -142,31 +146,225 @@ func (m *DriverLocationResponse) GetLocations() []*DriverLocation {
 	return nil
 }
 
+type Dispatch struct {
+	DispatchID           string   `protobuf:"bytes,1,opt,name=dispatchID,proto3" json:"dispatchID,omitempty"`
+	Location             string   `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Dispatch) Reset()         { *m = Dispatch{} }
+func (m *Dispatch) String() string { return proto.CompactTextString(m) }
+func (*Dispatch) ProtoMessage()    {}
+func (*Dispatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8904d12f7036c08a, []int{3}
+}
+func (m *Dispatch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Dispatch.Unmarshal(m, b)
+}
+func (m *Dispatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Dispatch.Marshal(b, m, deterministic)
+}
+func (m *Dispatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Dispatch.Merge(m, src)
+}
+func (m *Dispatch) XXX_Size() int {
+	return xxx_messageInfo_Dispatch.Size(m)
+}
+func (m *Dispatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_Dispatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Dispatch proto.InternalMessageInfo
+
+func (m *Dispatch) GetDispatchID() string {
+	if m != nil {
+		return m.DispatchID
+	}
+	return ""
+}
+
+func (m *Dispatch) GetLocation() string {
+	if m != nil {
+		return m.Location
+	}
+	return ""
+}
+
+type AssignDriversRequest struct {
+	Dispatches           []*Dispatch `protobuf:"bytes,1,rep,name=dispatches,proto3" json:"dispatches,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *AssignDriversRequest) Reset()         { *m = AssignDriversRequest{} }
+func (m *AssignDriversRequest) String() string { return proto.CompactTextString(m) }
+func (*AssignDriversRequest) ProtoMessage()    {}
+func (*AssignDriversRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8904d12f7036c08a, []int{4}
+}
+func (m *AssignDriversRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AssignDriversRequest.Unmarshal(m, b)
+}
+func (m *AssignDriversRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AssignDriversRequest.Marshal(b, m, deterministic)
+}
+func (m *AssignDriversRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AssignDriversRequest.Merge(m, src)
+}
+func (m *AssignDriversRequest) XXX_Size() int {
+	return xxx_messageInfo_AssignDriversRequest.Size(m)
+}
+func (m *AssignDriversRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AssignDriversRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AssignDriversRequest proto.InternalMessageInfo
+
+func (m *AssignDriversRequest) GetDispatches() []*Dispatch {
+	if m != nil {
+		return m.Dispatches
+	}
+	return nil
+}
+
+type Assignment struct {
+	DispatchID           string   `protobuf:"bytes,1,opt,name=dispatchID,proto3" json:"dispatchID,omitempty"`
+	DriverID             string   `protobuf:"bytes,2,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	DriverLocation       string   `protobuf:"bytes,3,opt,name=driverLocation,proto3" json:"driverLocation,omitempty"`
+	Distance             float64  `protobuf:"fixed64,4,opt,name=distance,proto3" json:"distance,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Assignment) Reset()         { *m = Assignment{} }
+func (m *Assignment) String() string { return proto.CompactTextString(m) }
+func (*Assignment) ProtoMessage()    {}
+func (*Assignment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8904d12f7036c08a, []int{5}
+}
+func (m *Assignment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Assignment.Unmarshal(m, b)
+}
+func (m *Assignment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Assignment.Marshal(b, m, deterministic)
+}
+func (m *Assignment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Assignment.Merge(m, src)
+}
+func (m *Assignment) XXX_Size() int {
+	return xxx_messageInfo_Assignment.Size(m)
+}
+func (m *Assignment) XXX_DiscardUnknown() {
+	xxx_messageInfo_Assignment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Assignment proto.InternalMessageInfo
+
+func (m *Assignment) GetDispatchID() string {
+	if m != nil {
+		return m.DispatchID
+	}
+	return ""
+}
+
+func (m *Assignment) GetDriverID() string {
+	if m != nil {
+		return m.DriverID
+	}
+	return ""
+}
+
+func (m *Assignment) GetDriverLocation() string {
+	if m != nil {
+		return m.DriverLocation
+	}
+	return ""
+}
+
+func (m *Assignment) GetDistance() float64 {
+	if m != nil {
+		return m.Distance
+	}
+	return 0
+}
+
+type AssignDriversResponse struct {
+	Assignments          []*Assignment `protobuf:"bytes,1,rep,name=assignments,proto3" json:"assignments,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *AssignDriversResponse) Reset()         { *m = AssignDriversResponse{} }
+func (m *AssignDriversResponse) String() string { return proto.CompactTextString(m) }
+func (*AssignDriversResponse) ProtoMessage()    {}
+func (*AssignDriversResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8904d12f7036c08a, []int{6}
+}
+func (m *AssignDriversResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AssignDriversResponse.Unmarshal(m, b)
+}
+func (m *AssignDriversResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AssignDriversResponse.Marshal(b, m, deterministic)
+}
+func (m *AssignDriversResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AssignDriversResponse.Merge(m, src)
+}
+func (m *AssignDriversResponse) XXX_Size() int {
+	return xxx_messageInfo_AssignDriversResponse.Size(m)
+}
+func (m *AssignDriversResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AssignDriversResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AssignDriversResponse proto.InternalMessageInfo
+
+func (m *AssignDriversResponse) GetAssignments() []*Assignment {
+	if m != nil {
+		return m.Assignments
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*DriverLocationRequest)(nil), "driver.DriverLocationRequest")
 	proto.RegisterType((*DriverLocation)(nil), "driver.DriverLocation")
 	proto.RegisterType((*DriverLocationResponse)(nil), "driver.DriverLocationResponse")
+	proto.RegisterType((*Dispatch)(nil), "driver.Dispatch")
+	proto.RegisterType((*AssignDriversRequest)(nil), "driver.AssignDriversRequest")
+	proto.RegisterType((*Assignment)(nil), "driver.Assignment")
+	proto.RegisterType((*AssignDriversResponse)(nil), "driver.AssignDriversResponse")
 }
 
-func init() {
-	proto.RegisterFile("pkg/clients/driver.proto", fileDescriptor_cdcd28b7ebdcd54f)
-}
-
-var fileDescriptor_cdcd28b7ebdcd54f = []byte{
-	// 207 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xd2, 0x49, 0xad, 0x48, 0xcc,
-	0x2d, 0xc8, 0x49, 0x2d, 0xd6, 0xcf, 0xc8, 0x2f, 0x29, 0xca, 0x4f, 0xd1, 0x2f, 0x4e, 0x2d, 0x2a,
-	0xcb, 0x4c, 0x4e, 0x2d, 0xd6, 0x4f, 0x29, 0xca, 0x2c, 0x4b, 0x2d, 0x82, 0x52, 0x7a, 0x05, 0x45,
-	0xf9, 0x25, 0xf9, 0x42, 0x6c, 0x10, 0x9e, 0x92, 0x31, 0x97, 0xa8, 0x0b, 0x98, 0xe5, 0x93, 0x9f,
-	0x9c, 0x58, 0x92, 0x99, 0x9f, 0x17, 0x94, 0x5a, 0x58, 0x9a, 0x5a, 0x5c, 0x22, 0x24, 0xc5, 0xc5,
-	0x91, 0x03, 0x15, 0x92, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x82, 0xf3, 0x95, 0x3c, 0xb8, 0xf8,
-	0x50, 0x35, 0x81, 0x54, 0x43, 0x0c, 0xf4, 0x74, 0x81, 0xa9, 0x86, 0xf1, 0x51, 0x4c, 0x62, 0x42,
-	0x33, 0xc9, 0x8f, 0x4b, 0x0c, 0xdd, 0xfa, 0xe2, 0x82, 0xfc, 0xbc, 0xe2, 0x54, 0x21, 0x13, 0x2e,
-	0x4e, 0x98, 0xaa, 0x62, 0x09, 0x46, 0x05, 0x66, 0x0d, 0x6e, 0x23, 0x31, 0x3d, 0xa8, 0x17, 0xd0,
-	0xb4, 0x20, 0x14, 0x1a, 0xc5, 0x72, 0xf1, 0x42, 0x24, 0x83, 0x21, 0x9e, 0x17, 0xf2, 0xe1, 0xe2,
-	0x76, 0xcb, 0xcc, 0x4b, 0xf1, 0x4b, 0x4d, 0x2c, 0x02, 0xf9, 0x4a, 0x16, 0x87, 0x11, 0x10, 0x4f,
-	0x4b, 0xc9, 0xe1, 0x92, 0x86, 0x38, 0xca, 0x89, 0x23, 0x0a, 0x1a, 0x6e, 0x49, 0x6c, 0xe0, 0x60,
-	0x34, 0x06, 0x04, 0x00, 0x00, 0xff, 0xff, 0xc8, 0x90, 0x0b, 0x66, 0x76, 0x01, 0x00, 0x00,
+func init() { proto.RegisterFile("pkg/clients/driver.proto", fileDescriptor_8904d12f7036c08a) }
+
+var fileDescriptor_8904d12f7036c08a = []byte{
+	// 331 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0x5d, 0x6b, 0xc2, 0x30,
+	0x14, 0x25, 0x3a, 0x44, 0xaf, 0x28, 0x23, 0x4c, 0x29, 0x32, 0x45, 0xfa, 0x30, 0x7c, 0xd2, 0xa1,
+	0xfb, 0x03, 0x1b, 0x45, 0x1c, 0x74, 0x3e, 0x74, 0x6f, 0x7b, 0xeb, 0xda, 0xe0, 0xc2, 0x5c, 0xda,
+	0x35, 0x99, 0xbf, 0x62, 0xff, 0x64, 0x7f, 0x72, 0xd8, 0x7c, 0xb4, 0x09, 0x74, 0xec, 0x2d, 0xf7,
+	0xde, 0x93, 0x93, 0x73, 0xce, 0x6d, 0xc1, 0xcb, 0xdf, 0x0f, 0xab, 0xe4, 0x48, 0x09, 0x13, 0x7c,
+	0x95, 0x16, 0xf4, 0x44, 0x8a, 0x65, 0x5e, 0x64, 0x22, 0xc3, 0x1d, 0x59, 0xf9, 0x1b, 0x18, 0x05,
+	0xe5, 0x29, 0xcc, 0x92, 0x58, 0xd0, 0x8c, 0x45, 0xe4, 0xf3, 0x8b, 0x70, 0x81, 0x27, 0xd0, 0x3d,
+	0xaa, 0x96, 0x87, 0xe6, 0x68, 0xd1, 0x8b, 0x4c, 0xed, 0xef, 0x60, 0x68, 0x5f, 0x3a, 0xa3, 0x25,
+	0xe1, 0x63, 0xa0, 0xd1, 0xba, 0xb6, 0x98, 0x5a, 0x0e, 0xd3, 0x1e, 0xc6, 0xee, 0xf3, 0x3c, 0xcf,
+	0x18, 0x27, 0xf8, 0x0e, 0x7a, 0x1a, 0xc5, 0x3d, 0x34, 0x6f, 0x2f, 0xfa, 0xeb, 0xf1, 0x52, 0x59,
+	0x70, 0xae, 0x54, 0x40, 0x7f, 0x0b, 0xdd, 0x80, 0xf2, 0x3c, 0x16, 0xc9, 0x1b, 0x9e, 0x01, 0xa4,
+	0xea, 0x6c, 0x54, 0xd5, 0x3a, 0x7f, 0xea, 0xda, 0xc1, 0xd5, 0x3d, 0xe7, 0xf4, 0xc0, 0xe4, 0x53,
+	0x5c, 0xa7, 0x72, 0x5b, 0x71, 0x12, 0x2d, 0xeb, 0xd2, 0xc8, 0x52, 0x93, 0xa8, 0x86, 0xf1, 0xbf,
+	0x11, 0x80, 0xa4, 0xfa, 0x20, 0x4c, 0xfc, 0x47, 0x94, 0x09, 0xb2, 0xe5, 0x04, 0x79, 0x03, 0xc3,
+	0xd4, 0x72, 0xee, 0xb5, 0x4b, 0x84, 0xd3, 0x2d, 0x39, 0x28, 0x17, 0x31, 0x4b, 0x88, 0x77, 0x31,
+	0x47, 0x0b, 0x14, 0x99, 0xda, 0x7f, 0x82, 0x91, 0x63, 0xcc, 0xe4, 0xdd, 0x8f, 0x8d, 0x4c, 0x6d,
+	0x0d, 0x6b, 0x6b, 0x95, 0x83, 0xa8, 0x0e, 0x5b, 0xff, 0x20, 0x18, 0x48, 0xa6, 0x67, 0x52, 0x9c,
+	0x68, 0x42, 0x70, 0x08, 0xfd, 0x2d, 0x65, 0xe9, 0x9e, 0xc4, 0xc5, 0x39, 0xb0, 0x69, 0xc3, 0xce,
+	0x64, 0x9e, 0x93, 0x59, 0xd3, 0x58, 0xa9, 0x0a, 0x61, 0x60, 0xc9, 0xc5, 0xd7, 0xb6, 0x22, 0x7b,
+	0x3d, 0x93, 0x69, 0xc3, 0x54, 0xb2, 0x3d, 0x74, 0x5f, 0xd4, 0x67, 0xff, 0xda, 0x29, 0xff, 0x82,
+	0xcd, 0xef, 0x00, 0x0e, 0x4e, 0x31, 0x6c, 0x21, 0x03, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -182,6 +380,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type DriverServiceClient interface {
 	FindNearest(ctx context.Context, in *DriverLocationRequest, opts ...grpc.CallOption) (*DriverLocationResponse, error)
+	AssignDrivers(ctx context.Context, in *AssignDriversRequest, opts ...grpc.CallOption) (*AssignDriversResponse, error)
 }
 
 type driverServiceClient struct {
@@ -201,9 +400,30 @@ func (c *driverServiceClient) FindNearest(ctx context.Context, in *DriverLocatio
 	return out, nil
 }
 
+func (c *driverServiceClient) AssignDrivers(ctx context.Context, in *AssignDriversRequest, opts ...grpc.CallOption) (*AssignDriversResponse, error) {
+	out := new(AssignDriversResponse)
+	err := c.cc.Invoke(ctx, "/driver.DriverService/AssignDrivers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DriverServiceServer is the server API for DriverService service.
 type DriverServiceServer interface {
 	FindNearest(context.Context, *DriverLocationRequest) (*DriverLocationResponse, error)
+	AssignDrivers(context.Context, *AssignDriversRequest) (*AssignDriversResponse, error)
+}
+
+// UnimplementedDriverServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedDriverServiceServer struct {
+}
+
+func (*UnimplementedDriverServiceServer) FindNearest(ctx context.Context, req *DriverLocationRequest) (*DriverLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindNearest not implemented")
+}
+func (*UnimplementedDriverServiceServer) AssignDrivers(ctx context.Context, req *AssignDriversRequest) (*AssignDriversResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignDrivers not implemented")
 }
 
 func RegisterDriverServiceServer(s *grpc.Server, srv DriverServiceServer) {
@@ -228,6 +448,24 @@ func _DriverService_FindNearest_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DriverService_AssignDrivers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignDriversRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServiceServer).AssignDrivers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/driver.DriverService/AssignDrivers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServiceServer).AssignDrivers(ctx, req.(*AssignDriversRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _DriverService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "driver.DriverService",
 	HandlerType: (*DriverServiceServer)(nil),
@@ -236,6 +474,10 @@ var _DriverService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "FindNearest",
 			Handler:    _DriverService_FindNearest_Handler,
 		},
+		{
+			MethodName: "AssignDrivers",
+			Handler:    _DriverService_AssignDrivers_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/clients/driver.proto",
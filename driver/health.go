@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// readinessTimeout bounds the checks of a readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// healthCheck is the outcome of one readiness check.
+type healthCheck struct {
+	Name   string
+	Target string
+	OK     bool
+	Error  string `json:",omitempty"`
+}
+
+// serveHealth serves /healthz, /readyz and /debug/vars on health until it
+// is shut down. The driver API is gRPC only, so the probes get an HTTP
+// listener of their own.
+func (s *Server) serveHealth(health *http.Server) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", s.ready)
+	mux.Handle("/debug/vars", expvar.Handler())
+	health.Handler = mux
+
+	s.logger.Bg().Info("Serving health checks", zap.String("address", "http://"+health.Addr))
+	if err := health.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		s.logger.Bg().Error("Cannot serve health checks", zap.Error(err))
+	}
+}
+
+// ready checks that the gRPC server accepts connections and that the
+// Jaeger agent host resolves. The agent is reached over UDP, so it cannot
+// be probed with a connection.
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	agent := os.Getenv("JAEGER_AGENT_HOST")
+	if agent == "" {
+		agent = "localhost"
+	}
+	checks := []healthCheck{
+		check(ctx, "grpc", s.hostPort, func(ctx context.Context) error {
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.hostPort)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}),
+		check(ctx, "jaeger-agent", agent, func(ctx context.Context) error {
+			_, err := net.DefaultResolver.LookupHost(ctx, agent)
+			return err
+		}),
+	}
+
+	status := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Ready  bool
+		Checks []healthCheck
+	}{status == http.StatusOK, checks})
+}
+
+func check(ctx context.Context, name, target string, probe func(context.Context) error) healthCheck {
+	c := healthCheck{Name: name, Target: target, OK: true}
+	if err := probe(ctx); err != nil {
+		c.OK = false
+		c.Error = err.Error()
+	}
+	return c
+}
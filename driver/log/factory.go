@@ -8,20 +8,30 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// LogLevelBaggageKey is the baggage item that, when set to "debug",
+// enables debug logging for a single request across all services.
+const LogLevelBaggageKey = "log-level"
+
 // Factory is the default logging wrapper that can create
 // logger instances either for a given Context or context-less.
 type Factory struct {
-	logger *zap.Logger
+	logger      *zap.Logger
+	debugLogger *zap.Logger
 }
 
-// NewFactory creates a new Factory.
-func NewFactory(logger *zap.Logger) Factory {
-	return Factory{logger: logger}
+// NewFactory creates a new Factory. Messages below level are dropped,
+// unless the request asks for debug logs via the log-level baggage item,
+// so logger itself should be built with debug level enabled.
+func NewFactory(logger *zap.Logger, level zapcore.LevelEnabler) Factory {
+	return Factory{
+		logger:      logger.WithOptions(zap.IncreaseLevel(level)),
+		debugLogger: logger,
+	}
 }
 
 // Bg creates a context-unaware logger.
 func (b Factory) Bg() Logger {
-	return logger(b)
+	return logger{logger: b.logger}
 }
 
 // For returns a context-aware Logger. If the context
@@ -29,6 +39,9 @@ func (b Factory) Bg() Logger {
 // echo-ed into the span.
 func (b Factory) For(ctx context.Context) Logger {
 	if span := opentracing.SpanFromContext(ctx); span != nil {
+		if span.BaggageItem(LogLevelBaggageKey) == "debug" {
+			return spanLogger{span: span, logger: b.debugLogger}
+		}
 		return spanLogger{span: span, logger: b.logger}
 	}
 	return b.Bg()
@@ -36,5 +49,8 @@ func (b Factory) For(ctx context.Context) Logger {
 
 // With creates a child logger, and optionally adds some context fields to that logger.
 func (b Factory) With(fields ...zapcore.Field) Factory {
-	return Factory{logger: b.logger.With(fields...)}
+	return Factory{
+		logger:      b.logger.With(fields...),
+		debugLogger: b.debugLogger.With(fields...),
+	}
 }
@@ -7,6 +7,7 @@ import (
 
 // Logger is a simplified abstraction of the zap.Logger
 type Logger interface {
+	Debug(msg string, fields ...zapcore.Field)
 	Info(msg string, fields ...zapcore.Field)
 	Error(msg string, fields ...zapcore.Field)
 	Fatal(msg string, fields ...zapcore.Field)
@@ -18,6 +19,11 @@ type logger struct {
 	logger *zap.Logger
 }
 
+// Debug logs a debug msg with fields
+func (l logger) Debug(msg string, fields ...zapcore.Field) {
+	l.logger.Debug(msg, fields...)
+}
+
 // Info logs an info msg with fields
 func (l logger) Info(msg string, fields ...zapcore.Field) {
 	l.logger.Info(msg, fields...)
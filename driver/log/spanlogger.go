@@ -15,6 +15,14 @@ type spanLogger struct {
 	span   opentracing.Span
 }
 
+func (sl spanLogger) Debug(msg string, fields ...zapcore.Field) {
+	if !sl.logger.Core().Enabled(zapcore.DebugLevel) {
+		return
+	}
+	sl.logToSpan("debug", msg, fields...)
+	sl.logger.Debug(msg, fields...)
+}
+
 func (sl spanLogger) Info(msg string, fields ...zapcore.Field) {
 	sl.logToSpan("info", msg, fields...)
 	sl.logger.Info(msg, fields...)
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"net"
 	"os"
 	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 
+	"github.com/superliuwr/jaeger-demo/driver/delay"
 	"github.com/superliuwr/jaeger-demo/driver/log"
+	"github.com/superliuwr/jaeger-demo/driver/resources"
 	"github.com/superliuwr/jaeger-demo/driver/tracing"
 )
 
@@ -19,17 +26,74 @@ func main() {
 }
 
 func execute() error {
+	flag.Float64Var(&ErrorRate, "error-rate", 0, "fraction (0..1) of calls to fail with an internal error")
+	flag.DurationVar(&RedisFindDelay, "redis-find-delay", RedisFindDelay, "mean latency of the simulated redis lookup of nearby drivers")
+	flag.DurationVar(&RedisGetDelay, "redis-get-delay", RedisGetDelay, "mean latency of each simulated redis lookup of a driver record")
+	injectDelay := flag.String("inject-delay", "", "artificial latency of the calls to each dependency, e.g. redis=30ms±10ms")
+	var keepaliveParams keepalive.ServerParameters
+	flag.DurationVar(&keepaliveParams.Time, "keepalive-time", 0, "ping a client after its connection is idle this long (0 keeps gRPC's default of 2h)")
+	flag.DurationVar(&keepaliveParams.Timeout, "keepalive-timeout", 0, "close a connection if a ping is not answered within this long (0 keeps gRPC's default of 20s)")
+	flag.DurationVar(&keepaliveParams.MaxConnectionAge, "max-connection-age", 0, "close connections after this long, so clients reconnect (0 keeps them open)")
+	flag.DurationVar(&keepaliveParams.MaxConnectionAgeGrace, "max-connection-age-grace", 0, "how long calls in flight may take after max-connection-age (0 waits for them)")
+	keepaliveMinTime := flag.Duration("keepalive-min-time", 5*time.Minute, "shortest interval between client pings; clients that ping more often are disconnected")
+	flag.Parse()
+
 	rootLogger, _ := zap.NewDevelopment(
 		zap.AddStacktrace(zapcore.FatalLevel),
 		zap.AddCallerSkip(1),
 	)
 	appLogger := rootLogger.With(zap.String("service", "driver"))
-	loggerFactory := log.NewFactory(appLogger)
+	loggerFactory := log.NewFactory(appLogger, zapcore.InfoLevel)
+
+	effective, err := resources.Apply()
+	if err != nil {
+		return logError(appLogger, err)
+	}
+	appLogger.Info("Runtime resources", zap.Int("gomaxprocs", effective.GOMAXPROCS), zap.Int64("memlimit", effective.MemoryLimit))
+
+	if ErrorRate < 0 || ErrorRate > 1 {
+		return logError(appLogger, fmt.Errorf("--error-rate must be between 0 and 1, got %g", ErrorRate))
+	}
+	if ErrorRate > 0 {
+		appLogger.Info("Failing calls", zap.Float64("rate", ErrorRate))
+	}
+
+	if RedisFindDelay < 0 || RedisGetDelay < 0 {
+		return logError(appLogger, fmt.Errorf("--redis-find-delay and --redis-get-delay must not be negative"))
+	}
+	RedisFindDelayStdDev = RedisFindDelay / 4
+	RedisGetDelayStdDev = RedisGetDelay / 4
+
+	delays, err := delay.Parse(*injectDelay)
+	if err != nil {
+		return logError(appLogger, err)
+	}
+	if len(delays) > 0 {
+		appLogger.Info("Delaying calls to dependencies", zap.String("delays", delays.Format()))
+	}
+
+	if JWTSecret = os.Getenv("JWT_SECRET"); JWTSecret != "" {
+		appLogger.Info("Authenticating calls with JWTs")
+	}
+
+	// The format of the trace context in the gRPC metadata of incoming calls.
+	propagation := os.Getenv("TRACE_PROPAGATION")
+	if propagation == "" {
+		propagation = tracing.PropagationJaeger
+	}
 
 	server := NewServer(
 		net.JoinHostPort("0.0.0.0", strconv.Itoa(8081)),
-		tracing.Init("driver", loggerFactory),
+		net.JoinHostPort("0.0.0.0", strconv.Itoa(8087)),
+		tracing.Init("driver",
+			tracing.WithLogger(loggerFactory),
+			tracing.WithPropagation(propagation),
+			tracing.WithTags(effective.Tags()...),
+		),
 		loggerFactory,
+		delays,
+		grpc.KeepaliveParams(keepaliveParams),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: *keepaliveMinTime}),
 	)
 
 	return logError(appLogger, server.Run())
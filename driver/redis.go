@@ -18,13 +18,15 @@ import (
 )
 
 var (
-	// RedisFindDelay is how long finding closest drivers takes.
+	// RedisFindDelay is how long finding closest drivers takes. Set with
+	// --redis-find-delay.
 	RedisFindDelay = 20 * time.Millisecond
 
 	// RedisFindDelayStdDev is standard deviation.
 	RedisFindDelayStdDev = RedisFindDelay / 4
 
-	// RedisGetDelay is how long retrieving a driver record takes.
+	// RedisGetDelay is how long retrieving a driver record takes. Set with
+	// --redis-get-delay.
 	RedisGetDelay = 10 * time.Millisecond
 
 	// RedisGetDelayStdDev is standard deviation
@@ -35,13 +37,15 @@ var (
 type Redis struct {
 	tracer opentracing.Tracer // simulate redis as a separate process
 	logger log.Factory
+	delays delay.Injections
 	errorSimulator
 }
 
-func newRedis(logger log.Factory) *Redis {
+func newRedis(logger log.Factory, delays delay.Injections) *Redis {
 	return &Redis{
-		tracer: tracing.Init("redis", logger),
+		tracer: tracing.Init("redis", tracing.WithLogger(logger)),
 		logger: logger,
+		delays: delays,
 	}
 }
 
@@ -59,6 +63,9 @@ func (r *Redis) FindDriverIDs(ctx context.Context, location string) []string {
 
 	// simulate RPC delay
 	delay.Sleep(RedisFindDelay, RedisFindDelayStdDev)
+	if err := r.delays.Inject(ctx, "redis"); err != nil {
+		return nil
+	}
 
 	drivers := make([]string, 10)
 	for i := range drivers {
@@ -84,6 +91,9 @@ func (r *Redis) GetDriver(ctx context.Context, driverID string) (Driver, error)
 
 	// simulate RPC delay
 	delay.Sleep(RedisGetDelay, RedisGetDelayStdDev)
+	if err := r.delays.Inject(ctx, "redis"); err != nil {
+		return Driver{}, err
+	}
 
 	if err := r.checkError(); err != nil {
 		if span := opentracing.SpanFromContext(ctx); span != nil {
@@ -96,10 +106,13 @@ func (r *Redis) GetDriver(ctx context.Context, driverID string) (Driver, error)
 	}
 
 	// #nosec
-	return Driver{
+	drv := Driver{
 		DriverID: driverID,
 		Location: fmt.Sprintf("%d,%d", rand.Int()%1000, rand.Int()%1000),
-	}, nil
+	}
+	r.logger.For(ctx).Debug("Fetched driver", zap.String("driver_id", drv.DriverID), zap.String("location", drv.Location))
+
+	return drv, nil
 }
 
 var errTimeout = errors.New("redis timeout")
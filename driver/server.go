@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"io"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 
+	"github.com/superliuwr/jaeger-demo/driver/delay"
 	"github.com/superliuwr/jaeger-demo/driver/log"
+	"github.com/superliuwr/jaeger-demo/driver/tracing"
 )
 
 // Driver describes a driver and the current car location.
@@ -19,32 +27,46 @@ type Driver struct {
 }
 
 type Server struct {
-	hostPort string
-	tracer   opentracing.Tracer
-	logger   log.Factory
-	redis    *Redis
-	server   *grpc.Server
+	hostPort       string
+	healthHostPort string
+	tracer         opentracing.Tracer
+	logger         log.Factory
+	redis          *Redis
+	server         *grpc.Server
 }
 
 var _ DriverServiceServer = (*Server)(nil)
 
-// NewServer creates a new driver.Server
-func NewServer(hostPort string, tracer opentracing.Tracer, logger log.Factory) *Server {
-	server := grpc.NewServer(grpc.UnaryInterceptor(
-		otgrpc.OpenTracingServerInterceptor(tracer)),
+// NewServer creates a new driver.Server. Its health checks are served over
+// HTTP on healthHostPort. delays are added to the calls to redis. opts are
+// added to the options of the gRPC server.
+func NewServer(hostPort string, healthHostPort string, tracer opentracing.Tracer, logger log.Factory, delays delay.Injections, opts ...grpc.ServerOption) *Server {
+	server := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			otgrpc.OpenTracingServerInterceptor(tracer),
+			authenticate,
+			injectErrors),
 		grpc.StreamInterceptor(
-			otgrpc.OpenTracingStreamServerInterceptor(tracer)))
+			otgrpc.OpenTracingStreamServerInterceptor(tracer))},
+		opts...)...)
 
 	return &Server{
-		hostPort: hostPort,
-		tracer:   tracer,
-		logger:   logger,
-		server: server,
-		redis:    newRedis(logger),
+		hostPort:       hostPort,
+		healthHostPort: healthHostPort,
+		tracer:         tracer,
+		logger:         logger,
+		server:         server,
+		redis:          newRedis(logger, delays),
 	}
 }
 
-// Run starts the Driver server
+// shutdownTimeout bounds how long in-flight calls are drained after SIGINT
+// or SIGTERM before they are cancelled.
+const shutdownTimeout = 10 * time.Second
+
+// Run starts the Driver server and serves until SIGINT or SIGTERM. It then
+// stops accepting connections, drains in-flight calls for at most
+// shutdownTimeout and closes the tracers, so buffered spans are flushed.
 func (s *Server) Run() error {
 	s.logger.Bg().Info("Starting", zap.String("address", "http://"+s.hostPort))
 
@@ -54,21 +76,73 @@ func (s *Server) Run() error {
 	}
 
 	RegisterDriverServiceServer(s.server, s)
+	health := &http.Server{Addr: s.healthHostPort}
+	go s.serveHealth(health)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		s.logger.Bg().Info("Shutting down", zap.Duration("timeout", shutdownTimeout))
+		_ = health.Close()
+		drained := make(chan struct{})
+		go func() {
+			s.server.GracefulStop()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-time.After(shutdownTimeout):
+			s.logger.Bg().Error("Cannot drain in-flight calls, cancelling them")
+			s.server.Stop()
+		}
+	}()
 
 	err = s.server.Serve(lis)
 	if err != nil {
 		s.logger.Bg().Fatal("Unable to start gRPC server", zap.Error(err))
 	}
 
-	return err
+	for _, tracer := range []opentracing.Tracer{s.tracer, s.redis.tracer} {
+		if closer, ok := tracer.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				s.logger.Bg().Error("Cannot flush spans", zap.Error(err))
+			}
+		}
+	}
+	return nil
 }
 
 // FindNearest implements gRPC driver interface
 func (s *Server) FindNearest(ctx context.Context, location *DriverLocationRequest) (*DriverLocationResponse, error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		tracing.TagBaggage(span)
+	}
 	s.logger.For(ctx).Info("Searching for nearby drivers", zap.String("location", location.Location))
-	driverIDs := s.redis.FindDriverIDs(ctx, location.Location)
+	drivers, err := s.findDrivers(ctx, location.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	retMe := make([]*DriverLocation, len(drivers))
+	for i, drv := range drivers {
+		retMe[i] = &DriverLocation{
+			DriverID: drv.DriverID,
+			Location: drv.Location,
+		}
+	}
 
-	retMe := make([]*DriverLocation, len(driverIDs))
+	s.logger.For(ctx).Info("Search successful", zap.Int("num_drivers", len(retMe)))
+
+	return &DriverLocationResponse{Locations: retMe}, nil
+}
+
+// findDrivers looks up the drivers near location in Redis.
+func (s *Server) findDrivers(ctx context.Context, location string) ([]Driver, error) {
+	driverIDs := s.redis.FindDriverIDs(ctx, location)
+
+	drivers := make([]Driver, len(driverIDs))
 	for i, driverID := range driverIDs {
 		var drv Driver
 		var err error
@@ -85,13 +159,8 @@ func (s *Server) FindNearest(ctx context.Context, location *DriverLocationReques
 			return nil, err
 		}
 
-		retMe[i] = &DriverLocation{
-			DriverID: drv.DriverID,
-			Location: drv.Location,
-		}
+		drivers[i] = drv
 	}
 
-	s.logger.For(ctx).Info("Search successful", zap.Int("num_drivers", len(retMe)))
-
-	return &DriverLocationResponse{Locations: retMe}, nil
+	return drivers, nil
 }
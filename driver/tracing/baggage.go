@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"expvar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/driver/log"
+)
+
+// DefaultAllowedBaggageKeys are the baggage items the demo itself uses.
+var DefaultAllowedBaggageKeys = []string{"customer", "session", "request", log.LogLevelBaggageKey, "synthetic", "user"}
+
+// DefaultMaxBaggageValueLength is the longest baggage value accepted from callers.
+const DefaultMaxBaggageValueLength = 256
+
+var baggageViolations = expvar.NewMap("baggage_violations")
+
+// BaggageRestrictions limit which baggage items are accepted on ingress.
+type BaggageRestrictions struct {
+	AllowedKeys    []string
+	MaxValueLength int
+}
+
+// baggageRestrictionsFromEnv reads the restrictions from BAGGAGE_ALLOWED_KEYS
+// (comma separated) and BAGGAGE_MAX_VALUE_LENGTH, falling back to the defaults.
+func baggageRestrictionsFromEnv() (BaggageRestrictions, error) {
+	restrictions := BaggageRestrictions{
+		AllowedKeys:    DefaultAllowedBaggageKeys,
+		MaxValueLength: DefaultMaxBaggageValueLength,
+	}
+	if keys := os.Getenv("BAGGAGE_ALLOWED_KEYS"); keys != "" {
+		restrictions.AllowedKeys = strings.Split(keys, ",")
+	}
+	if length := os.Getenv("BAGGAGE_MAX_VALUE_LENGTH"); length != "" {
+		var err error
+		if restrictions.MaxValueLength, err = strconv.Atoi(length); err != nil {
+			return restrictions, err
+		}
+	}
+	return restrictions, nil
+}
+
+// restrictedExtractor drops baggage items that are not allowed and truncates
+// values that are too long before handing the carrier to the real extractor.
+// Baggage values are URL-encoded in the HTTPHeaders format, and kept as they
+// are in the TextMap format.
+type restrictedExtractor struct {
+	extractor      jaeger.Extractor
+	headers        *jaeger.HeadersConfig
+	urlEncoded     bool
+	allowed        map[string]bool
+	maxValueLength int
+	logger         log.Logger
+}
+
+func newRestrictedExtractor(extractor jaeger.Extractor, headers *jaeger.HeadersConfig, urlEncoded bool, restrictions BaggageRestrictions, logger log.Logger) *restrictedExtractor {
+	allowed := make(map[string]bool, len(restrictions.AllowedKeys))
+	for _, key := range restrictions.AllowedKeys {
+		allowed[strings.TrimSpace(key)] = true
+	}
+	return &restrictedExtractor{
+		extractor:      extractor,
+		headers:        headers,
+		urlEncoded:     urlEncoded,
+		allowed:        allowed,
+		maxValueLength: restrictions.MaxValueLength,
+		logger:         logger,
+	}
+}
+
+// Extract implements jaeger.Extractor
+func (e *restrictedExtractor) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return e.extractor.Extract(carrier)
+	}
+	return e.extractor.Extract(restrictedCarrier{TextMapReader: reader, extractor: e})
+}
+
+// restrict returns the value to keep for a baggage item, or false if it must be dropped.
+func (e *restrictedExtractor) restrict(key, value string) (string, bool) {
+	if !e.allowed[key] {
+		e.violation("key_not_allowed", key)
+		return "", false
+	}
+	if len(value) > e.maxValueLength {
+		e.violation("value_too_long", key)
+		return value[:e.maxValueLength], true
+	}
+	return value, true
+}
+
+func (e *restrictedExtractor) violation(reason, key string) {
+	baggageViolations.Add(reason, 1)
+	e.logger.Info("Baggage item restricted", zap.String("reason", reason), zap.String("key", key))
+}
+
+type restrictedCarrier struct {
+	opentracing.TextMapReader
+	extractor *restrictedExtractor
+}
+
+// ForeachKey implements opentracing.TextMapReader
+func (c restrictedCarrier) ForeachKey(handler func(key, val string) error) error {
+	prefix := strings.ToLower(c.extractor.headers.TraceBaggageHeaderPrefix)
+	return c.TextMapReader.ForeachKey(func(key, val string) error {
+		lowerCaseKey := strings.ToLower(key)
+		switch {
+		case strings.HasPrefix(lowerCaseKey, prefix):
+			if !c.extractor.urlEncoded {
+				if value, ok := c.extractor.restrict(lowerCaseKey[len(prefix):], val); ok {
+					return handler(key, value)
+				}
+				return nil
+			}
+			value, err := url.QueryUnescape(val)
+			if err != nil {
+				return handler(key, val)
+			}
+			if value, ok := c.extractor.restrict(lowerCaseKey[len(prefix):], value); ok {
+				return handler(key, url.QueryEscape(value))
+			}
+			return nil
+		case lowerCaseKey == c.extractor.headers.JaegerBaggageHeader:
+			return handler(key, c.restrictList(val))
+		}
+		return handler(key, val)
+	})
+}
+
+// restrictList filters the "key1=value1, key2=value2" list of the jaeger-baggage header.
+func (c restrictedCarrier) restrictList(list string) string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if value, ok := c.extractor.restrict(kv[0], kv[1]); ok {
+			items = append(items, kv[0]+"="+value)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// DemoBaggageKeys are the baggage items every service copies to the tags
+// of its spans, to show baggage crossing service boundaries.
+var DemoBaggageKeys = []string{"customer", "session", "user"}
+
+// TagBaggage tags span with the value of each of DemoBaggageKeys that is
+// set in its baggage.
+func TagBaggage(span opentracing.Span) {
+	for _, key := range DemoBaggageKeys {
+		if value := span.BaggageItem(key); value != "" {
+			span.SetTag(key, value)
+		}
+	}
+}
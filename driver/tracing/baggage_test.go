@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/superliuwr/jaeger-demo/driver/log"
+)
+
+// TestRestrictGRPCBaggage checks that the baggage of a gRPC call, which
+// otgrpc extracts from its metadata, is restricted like that of a request.
+func TestRestrictGRPCBaggage(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	tracer := Init("driver",
+		WithLogger(log.NewFactory(zap.NewNop(), zap.InfoLevel)),
+		WithReporter(reporter))
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(otgrpc.OpenTracingServerInterceptor(tracer)))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	client, closer := jaeger.NewTracer("frontend", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(otgrpc.OpenTracingClientInterceptor(client)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	span := client.StartSpan("dispatch")
+	span.SetBaggageItem("customer", strings.Repeat("x", DefaultMaxBaggageValueLength+1))
+	span.SetBaggageItem("session", "a b")
+	span.SetBaggageItem("evil", "1")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	span.Finish()
+
+	spans := reporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d server spans, want 1", len(spans))
+	}
+	ctxt := spans[0].Context().(jaeger.SpanContext)
+	if ctxt.TraceID() != span.Context().(jaeger.SpanContext).TraceID() {
+		t.Errorf("server span is not in the trace of the call")
+	}
+	baggage := map[string]string{}
+	ctxt.ForeachBaggageItem(func(k, v string) bool {
+		baggage[k] = v
+		return true
+	})
+	if got := len(baggage["customer"]); got != DefaultMaxBaggageValueLength {
+		t.Errorf("customer is %d bytes long, want %d", got, DefaultMaxBaggageValueLength)
+	}
+	if got := baggage["session"]; got != "a b" {
+		t.Errorf("session = %q, want %q", got, "a b")
+	}
+	if _, ok := baggage["evil"]; ok {
+		t.Errorf("evil was not dropped")
+	}
+}
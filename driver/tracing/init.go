@@ -5,29 +5,67 @@ import (
 	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 	"go.uber.org/zap"
 
 	"github.com/superliuwr/jaeger-demo/driver/log"
 )
 
-// Init creates a new instance of Jaeger tracer.
-func Init(serviceName string, logger log.Factory) opentracing.Tracer {
+// Init creates a new instance of Jaeger tracer, configured from the JAEGER_*
+// env vars and opts.
+func Init(serviceName string, opts ...Option) opentracing.Tracer {
+	o := newOptions(opts)
+	logger := o.logger
+
 	cfg, err := config.FromEnv()
 	if err != nil {
 		logger.Bg().Fatal("cannot parse Jaeger env vars", zap.Error(err))
 	}
 
 	cfg.ServiceName = serviceName
+	cfg.Tags = append(cfg.Tags, o.tags...)
 	cfg.Sampler.Type = "const"
 	cfg.Sampler.Param = 1
 
 	time.Sleep(100 * time.Millisecond)
 	jaegerLogger := jaegerLoggerAdapter{logger.Bg()}
 
-	tracer, _, err := cfg.NewTracer(
+	restrictions, err := baggageRestrictionsFromEnv()
+	if err != nil {
+		logger.Bg().Fatal("cannot parse baggage restrictions", zap.Error(err))
+	}
+	sampler := o.sampler
+	if sampler == nil {
+		if sampler, err = samplerFromEnv(); err != nil {
+			logger.Bg().Fatal("cannot parse sampling overrides", zap.Error(err))
+		}
+	}
+	headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	propagator, err := newPropagator(o.propagation, headers)
+	if err != nil {
+		logger.Bg().Fatal("cannot create propagator", zap.Error(err))
+	}
+	// Baggage is restricted in every format the tracer extracts. HTTPHeaders
+	// covers the HTTP requests and the gRPC calls, since otgrpc reads the
+	// metadata of calls in that format.
+	textMap := jaeger.NewTextMapPropagator(headers, *jaeger.NewNullMetrics())
+
+	configOptions := []config.Option{
 		config.Logger(jaegerLogger),
-	)
+		config.Injector(opentracing.HTTPHeaders, propagator),
+		config.Extractor(opentracing.HTTPHeaders, newRestrictedExtractor(propagator, headers, true, restrictions, logger.Bg())),
+		config.Injector(opentracing.TextMap, textMap),
+		config.Extractor(opentracing.TextMap, newRestrictedExtractor(textMap, headers, false, restrictions, logger.Bg())),
+		config.Sampler(sampler),
+	}
+	if o.reporter != nil {
+		configOptions = append(configOptions, config.Reporter(o.reporter))
+	}
+	if o.metrics != nil {
+		configOptions = append(configOptions, config.Metrics(o.metrics))
+	}
+	tracer, _, err := cfg.NewTracer(configOptions...)
 	if err != nil {
 		logger.Bg().Fatal("cannot initialize Jaeger Tracer", zap.Error(err))
 	}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/superliuwr/jaeger-demo/driver/log"
+)
+
+// Option configures the tracer created by Init.
+type Option func(*options)
+
+type options struct {
+	logger      log.Factory
+	propagation string
+	tags        []opentracing.Tag
+	sampler     jaeger.Sampler
+	reporter    jaeger.Reporter
+	metrics     metrics.Factory
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		logger:      log.NewFactory(defaultLogger(), zapcore.InfoLevel),
+		propagation: PropagationJaeger,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// defaultLogger writes to stderr, so that fatal setup errors are not lost
+// when no logger is given.
+func defaultLogger() *zap.Logger {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return zap.NewNop()
+	}
+	return logger
+}
+
+// WithLogger logs the errors of the tracer and fatal setup errors to
+// logger. By default they are logged as JSON to stderr.
+func WithLogger(logger log.Factory) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithPropagation propagates the trace context in the given format, one of
+// the Propagation constants. The default is PropagationJaeger.
+func WithPropagation(format string) Option {
+	return func(o *options) { o.propagation = format }
+}
+
+// WithTags adds tags to every span.
+func WithTags(tags ...opentracing.Tag) Option {
+	return func(o *options) { o.tags = append(o.tags, tags...) }
+}
+
+// WithSampler replaces the sampler, which by default samples every trace
+// unless SAMPLING_OPERATIONS says otherwise.
+func WithSampler(sampler jaeger.Sampler) Option {
+	return func(o *options) { o.sampler = sampler }
+}
+
+// WithReporter replaces the reporter, which by default sends spans where
+// the JAEGER_* env vars say.
+func WithReporter(reporter jaeger.Reporter) Option {
+	return func(o *options) { o.reporter = reporter }
+}
+
+// WithMetrics reports the tracer's own metrics, like spans started and
+// dropped, to factory. By default they are not reported.
+func WithMetrics(factory metrics.Factory) Option {
+	return func(o *options) { o.metrics = factory }
+}
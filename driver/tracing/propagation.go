@@ -0,0 +1,33 @@
+package tracing
+
+//go:generate sh -c "{ echo '// Code generated from frontend/tracing/b3.go by go generate. DO NOT EDIT.'; echo; cat ../../frontend/tracing/b3.go; } > b3.go"
+
+import (
+	"fmt"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+// Formats of the trace context in HTTP headers and gRPC metadata.
+const (
+	PropagationJaeger = "jaeger"
+	PropagationB3     = "b3"
+	// PropagationB3Single writes the single b3 header instead of the
+	// x-b3-* headers of PropagationB3. Both read either.
+	PropagationB3Single = "b3-single"
+)
+
+type propagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+func newPropagator(format string, headers *jaeger.HeadersConfig) (propagator, error) {
+	switch format {
+	case PropagationJaeger:
+		return jaeger.NewHTTPHeaderPropagator(headers, *jaeger.NewNullMetrics()), nil
+	case PropagationB3, PropagationB3Single:
+		return b3Propagator{single: format == PropagationB3Single, baggagePrefix: headers.TraceBaggageHeaderPrefix}, nil
+	}
+	return nil, fmt.Errorf("unknown propagation format %q", format)
+}
@@ -0,0 +1,448 @@
+package addressbook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// maxBodyBytes bounds the size of a saved location in a request.
+const maxBodyBytes = 4 << 10
+
+// The address books live in memory, so their number and size are capped.
+const (
+	// maxBooks is how many customers may have an address book.
+	maxBooks = 1000
+	// maxLocations is how many locations one customer may save.
+	maxLocations = 50
+)
+
+// Location is a place a customer saved to their address book, like home or
+// the office.
+type Location struct {
+	ID   string
+	Name string
+	// Location is "lat,lng", as the customer and route services use it.
+	Location string
+	// Version is incremented with every update; the ETag is derived from it.
+	Version int
+}
+
+// ETag returns the strong entity tag of the location's current version.
+func (l Location) ETag() string {
+	return `"` + l.ID + "-" + strconv.Itoa(l.Version) + `"`
+}
+
+// MarshalJSON renders the location with its ETag, so clients of the list
+// can send If-Match without fetching each location.
+func (l Location) MarshalJSON() ([]byte, error) {
+	type location Location
+	return json.Marshal(struct {
+		location
+		ETag string
+	}{location(l), l.ETag()})
+}
+
+// Op tells how an Event changed an address book.
+type Op string
+
+// Mutations of an address book.
+const (
+	Created Op = "created"
+	Updated Op = "updated"
+	Deleted Op = "deleted"
+)
+
+// Event tells browsers that a customer's address book changed, so they
+// drop what they cached of it.
+type Event struct {
+	Op       Op
+	Customer string
+	ID       string
+	// ETag is the location's new entity tag, if it still exists.
+	ETag string `json:",omitempty"`
+	// TraceID is the trace of the mutation.
+	TraceID string `json:",omitempty"`
+}
+
+// errPrecondition is returned when a mutation's If-Match header does not
+// match the current version of the location.
+var errPrecondition = errors.New("location was changed by someone else")
+
+// errFull is returned when a location cannot be saved because the address
+// book, or the memory set aside for all of them, is full.
+var errFull = errors.New("address book is full")
+
+// book holds the saved locations of one customer. Version is incremented
+// with every mutation and is the entity tag of the list.
+type book struct {
+	version   int
+	locations map[string]*Location
+}
+
+// Book keeps the saved locations of all customers in memory, with
+// ETag-based optimistic concurrency control, and streams an Event for every
+// mutation to subscribed browsers.
+type Book struct {
+	tracer opentracing.Tracer
+	logger log.Factory
+
+	lock        sync.Mutex
+	books       map[string]*book
+	subscribers map[chan Event]struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates an empty Book.
+func New(tracer opentracing.Tracer, logger log.Factory) *Book {
+	return &Book{
+		tracer:      tracer,
+		logger:      logger,
+		books:       make(map[string]*book),
+		subscribers: make(map[chan Event]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// ServeHTTP serves the address book of the customer path value. Without an
+// id path value, GET lists the saved locations and POST saves a new one.
+// With one, GET renders the location, and PUT and DELETE change it; both
+// require an If-Match header with its current ETag, and answer 412
+// Precondition Failed with the current location if it has changed since.
+// GETs answer If-None-Match with 304 Not Modified.
+func (b *Book) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	customer, id := r.PathValue("customer"), r.PathValue("id")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		b.create(w, r, customer)
+	case id == "":
+		b.list(w, r, customer)
+	case r.Method == http.MethodPut, r.Method == http.MethodDelete:
+		b.mutate(w, r, customer, id)
+	default:
+		b.get(w, r, customer, id)
+	}
+}
+
+func (b *Book) list(w http.ResponseWriter, r *http.Request, customer string) {
+	b.lock.Lock()
+	bk := b.books[customer]
+	etag := `"0"`
+	locations := []Location{}
+	if bk != nil {
+		etag = `"` + strconv.Itoa(bk.version) + `"`
+		for _, l := range bk.locations {
+			locations = append(locations, *l)
+		}
+	}
+	b.lock.Unlock()
+
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+	if notModified(w, r, etag) {
+		return
+	}
+	write(w, http.StatusOK, etag, locations)
+}
+
+func (b *Book) get(w http.ResponseWriter, r *http.Request, customer, id string) {
+	location, ok := b.lookup(customer, id)
+	if !ok {
+		http.Error(w, "no such location", http.StatusNotFound)
+		return
+	}
+	if notModified(w, r, location.ETag()) {
+		return
+	}
+	write(w, http.StatusOK, location.ETag(), location)
+}
+
+func (b *Book) lookup(customer, id string) (Location, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if bk := b.books[customer]; bk != nil {
+		if l := bk.locations[id]; l != nil {
+			return *l, true
+		}
+	}
+	return Location{}, false
+}
+
+func (b *Book) create(w http.ResponseWriter, r *http.Request, customer string) {
+	input, err := decode(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, span := b.startMutation(r.Context(), Created, customer)
+	defer span.Finish()
+
+	b.lock.Lock()
+	bk := b.books[customer]
+	if (bk == nil && len(b.books) >= maxBooks) || (bk != nil && len(bk.locations) >= maxLocations) {
+		b.lock.Unlock()
+		span.SetTag("location.outcome", "full")
+		span.LogFields(otlog.Error(errFull))
+		http.Error(w, errFull.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if bk == nil {
+		bk = &book{locations: make(map[string]*Location)}
+		b.books[customer] = bk
+	}
+	location := &Location{ID: newID(), Name: input.Name, Location: input.Location, Version: 1}
+	bk.locations[location.ID] = location
+	bk.version++
+	created := *location
+	b.lock.Unlock()
+
+	b.committed(ctx, span, Created, customer, created)
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+created.ID)
+	write(w, http.StatusCreated, created.ETag(), created)
+}
+
+// mutate updates or deletes a location if the request's If-Match header
+// matches its current version.
+func (b *Book) mutate(w http.ResponseWriter, r *http.Request, customer, id string) {
+	var input Location
+	if r.Method == http.MethodPut {
+		var err error
+		if input, err = decode(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match header with the location's ETag is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	op := Updated
+	if r.Method == http.MethodDelete {
+		op = Deleted
+	}
+	ctx, span := b.startMutation(r.Context(), op, customer)
+	defer span.Finish()
+	span.SetTag("location.id", id)
+
+	b.lock.Lock()
+	bk := b.books[customer]
+	var current *Location
+	if bk != nil {
+		current = bk.locations[id]
+	}
+	if current == nil {
+		b.lock.Unlock()
+		span.SetTag("location.outcome", "not-found")
+		http.Error(w, "no such location", http.StatusNotFound)
+		return
+	}
+	if !matches(ifMatch, current.ETag(), false) {
+		latest := *current
+		b.lock.Unlock()
+		b.conflicted(ctx, span, ifMatch, latest)
+		write(w, http.StatusPreconditionFailed, latest.ETag(), latest)
+		return
+	}
+	switch op {
+	case Updated:
+		current.Name, current.Location = input.Name, input.Location
+		current.Version++
+	case Deleted:
+		delete(bk.locations, id)
+	}
+	bk.version++
+	changed := *current
+	b.lock.Unlock()
+
+	b.committed(ctx, span, op, customer, changed)
+	if op == Deleted {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	write(w, http.StatusOK, changed.ETag(), changed)
+}
+
+// startMutation starts the span of a mutation of the customer's address
+// book, as a child of the request span in ctx.
+func (b *Book) startMutation(ctx context.Context, op Op, customer string) (context.Context, opentracing.Span) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, b.tracer, "addressbook: "+string(op))
+	span.SetTag("customer.id", customer)
+	span.SetTag("location.op", string(op))
+	return ctx, span
+}
+
+func (b *Book) committed(ctx context.Context, span opentracing.Span, op Op, customer string, location Location) {
+	span.SetTag("location.id", location.ID)
+	span.SetTag("location.version", location.Version)
+	span.SetTag("location.outcome", "committed")
+	b.logger.For(ctx).Info("Address book changed",
+		zap.String("customer", customer), zap.String("location", location.ID), zap.String("op", string(op)), zap.Int("version", location.Version))
+
+	event := Event{Op: op, Customer: customer, ID: location.ID, TraceID: tracing.TraceID(ctx)}
+	if op != Deleted {
+		event.ETag = location.ETag()
+	}
+	b.publish(ctx, event)
+}
+
+// conflicted records a lost update: the client changed a version of the
+// location that is no longer current.
+func (b *Book) conflicted(ctx context.Context, span opentracing.Span, ifMatch string, latest Location) {
+	span.SetTag("location.version", latest.Version)
+	span.SetTag("location.outcome", "conflict")
+	span.LogFields(otlog.String("if_match", ifMatch), otlog.String("etag", latest.ETag()), otlog.Error(errPrecondition))
+	b.logger.For(ctx).Info("Address book change rejected",
+		zap.String("location", latest.ID), zap.String("if_match", ifMatch), zap.String("etag", latest.ETag()))
+}
+
+// publish hands event to every subscriber. Subscribers that fall behind
+// miss events; the stream tells them to refetch everything instead.
+func (b *Book) publish(ctx context.Context, event Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	dropped := 0
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			dropped++
+		}
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.LogFields(otlog.String("event", "invalidation published"), otlog.Int("subscribers", len(b.subscribers)), otlog.Int("dropped", dropped))
+	}
+}
+
+// Close ends the open event streams, so they don't hold up a graceful
+// shutdown. Browsers reconnect by themselves once the server is back.
+func (b *Book) Close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// Events streams an invalidation event for every mutation of any address
+// book, as server-sent events.
+func (b *Book) Events() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+
+		subscriber := make(chan Event, 16)
+		b.lock.Lock()
+		b.subscribers[subscriber] = struct{}{}
+		b.lock.Unlock()
+		defer func() {
+			b.lock.Lock()
+			delete(b.subscribers, subscriber)
+			b.lock.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprint(w, ": watching address books\n\n")
+		if err := rc.Flush(); err != nil {
+			b.logger.For(r.Context()).Error("Cannot stream address book events", zap.Error(err))
+			return
+		}
+
+		for {
+			select {
+			case event := <-subscriber:
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(w, "event: invalidate\ndata: %s\n\n", data)
+				_ = rc.Flush()
+			case <-r.Context().Done():
+				return
+			case <-b.done:
+				return
+			}
+		}
+	})
+}
+
+// decode reads the Name and Location of a saved location from the request body.
+func decode(w http.ResponseWriter, r *http.Request) (Location, error) {
+	var input Location
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&input); err != nil {
+		return input, fmt.Errorf("cannot decode location: %w", err)
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return input, errors.New("location needs a Name")
+	}
+	lat, lng, ok := strings.Cut(input.Location, ",")
+	if !ok {
+		return input, errors.New("location needs a Location of the form 'lat,lng'")
+	}
+	for _, coordinate := range []string{lat, lng} {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(coordinate), 64); err != nil {
+			return input, errors.New("location needs a Location of the form 'lat,lng'")
+		}
+	}
+	return input, nil
+}
+
+// matches tells if an If-Match or If-None-Match header value lists etag.
+// If-Match compares strongly, so a weak candidate never matches; weak is
+// set for If-None-Match, which ignores the W/ prefix.
+func matches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified answers 304 Not Modified if the request's If-None-Match
+// header lists etag.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if header := r.Header.Get("If-None-Match"); header == "" || !matches(header, etag, true) {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func write(w http.ResponseWriter, status int, etag string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	// Browsers must revalidate, so a cached list is never shown stale.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
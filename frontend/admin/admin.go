@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+var denials = expvar.NewMap("admin_denials")
+
+// Permissions are the operations granted to a token or network. An
+// operation is "<resource>.read" or "<resource>.write"; "<resource>.*"
+// grants both and "*" grants every operation.
+type Permissions []string
+
+// Allow tells if the permissions grant op.
+func (p Permissions) Allow(op string) bool {
+	resource, _, _ := strings.Cut(op, ".")
+	for _, permission := range p {
+		if permission == "*" || permission == op || permission == resource+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Network grants Permissions to the clients whose address is in Net.
+type Network struct {
+	Net         *net.IPNet
+	Permissions Permissions
+}
+
+// Policy grants admin operations to bearer tokens and client networks. A
+// request is allowed if either its token or its address grants the
+// operation. A Policy with neither tokens nor networks allows everything.
+type Policy struct {
+	Tokens   map[string]Permissions
+	Networks []Network
+}
+
+// Enabled tells if the policy restricts anything.
+func (p Policy) Enabled() bool {
+	return len(p.Tokens) > 0 || len(p.Networks) > 0
+}
+
+// MarshalJSON renders the policy without the tokens themselves, so it can
+// be shown in /debug/config.
+func (p Policy) MarshalJSON() ([]byte, error) {
+	type network struct {
+		Net         string
+		Permissions Permissions
+	}
+	view := struct {
+		Tokens   []Permissions
+		Networks []network
+	}{Tokens: []Permissions{}, Networks: []network{}}
+	for _, permissions := range p.Tokens {
+		view.Tokens = append(view.Tokens, permissions)
+	}
+	for _, n := range p.Networks {
+		view.Networks = append(view.Networks, network{n.Net.String(), n.Permissions})
+	}
+	return json.Marshal(view)
+}
+
+// ParseTokens parses "token=permission|permission,..." as set in ADMIN_TOKENS.
+func ParseTokens(s string) (map[string]Permissions, error) {
+	tokens := make(map[string]Permissions)
+	err := parseGrants(s, "token", func(token string, permissions Permissions) error {
+		tokens[token] = permissions
+		return nil
+	})
+	return tokens, err
+}
+
+// ParseNetworks parses "cidr=permission|permission,..." as set in
+// ADMIN_NETWORKS. A plain IP address stands for just that address.
+func ParseNetworks(s string) ([]Network, error) {
+	var networks []Network
+	err := parseGrants(s, "network", func(cidr string, permissions Permissions) error {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("invalid admin network %q", cidr)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			cidr = fmt.Sprintf("%s/%d", cidr, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid admin network: %w", err)
+		}
+		networks = append(networks, Network{Net: ipNet, Permissions: permissions})
+		return nil
+	})
+	return networks, err
+}
+
+func parseGrants(s, kind string, add func(principal string, permissions Permissions) error) error {
+	if s == "" {
+		return nil
+	}
+	for _, grant := range strings.Split(s, ",") {
+		principal, list, ok := strings.Cut(grant, "=")
+		principal = strings.TrimSpace(principal)
+		if !ok || principal == "" || strings.TrimSpace(list) == "" {
+			return fmt.Errorf("invalid admin %s grant, want %s=permission|permission", kind, kind)
+		}
+		var permissions Permissions
+		for _, permission := range strings.Split(list, "|") {
+			permissions = append(permissions, strings.TrimSpace(permission))
+		}
+		if err := add(principal, permissions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Guard enforces a Policy on admin endpoints.
+type Guard struct {
+	policy Policy
+	logger log.Factory
+}
+
+// NewGuard creates a Guard enforcing policy.
+func NewGuard(policy Policy, logger log.Factory) *Guard {
+	return &Guard{policy: policy, logger: logger}
+}
+
+// Operation returns the operation a request with the given method performs
+// on resource: GET and HEAD read, any other method writes.
+func Operation(resource, method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return resource + ".read"
+	}
+	return resource + ".write"
+}
+
+// Protect passes requests for resource on to next if the policy allows
+// their operation. Others are answered with 401 Unauthorized if they have
+// no valid token, or 403 Forbidden if their token does not grant the
+// operation. Denials are logged, counted per operation in the
+// admin_denials expvar and tagged on the request span, so Protect must run
+// inside the tracing middleware.
+func (g *Guard) Protect(resource string, next http.Handler) http.Handler {
+	if !g.policy.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := Operation(resource, r.Method)
+		ip := remoteIP(r)
+		permissions, hasToken, validToken := g.tokenPermissions(r)
+		if permissions.Allow(op) || g.networkPermissions(ip).Allow(op) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status, reason := http.StatusForbidden, "not permitted"
+		if !validToken {
+			status, reason = http.StatusUnauthorized, "no token"
+			if hasToken {
+				reason = "unknown token"
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="frontend admin"`)
+		}
+		denials.Add(op, 1)
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			span.SetTag("admin.denied", op)
+			span.LogKV("event", "admin request denied", "reason", reason)
+		}
+		g.logger.For(r.Context()).Info("Admin request denied",
+			zap.String("operation", op), zap.String("remote", ip.String()), zap.String("reason", reason))
+		http.Error(w, http.StatusText(status)+": "+reason+" for "+op, status)
+	})
+}
+
+// tokenPermissions returns the permissions of the request's bearer token.
+// Tokens are compared in constant time.
+func (g *Guard) tokenPermissions(r *http.Request) (granted Permissions, hasToken, valid bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, false, false
+	}
+	for candidate, permissions := range g.policy.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			granted, valid = permissions, true
+		}
+	}
+	return granted, true, valid
+}
+
+func (g *Guard) networkPermissions(ip net.IP) Permissions {
+	var granted Permissions
+	for _, n := range g.policy.Networks {
+		if ip != nil && n.Net.Contains(ip) {
+			granted = append(granted, n.Permissions...)
+		}
+	}
+	return granted
+}
+
+// remoteIP is the address of the client the request came from. Forwarded
+// headers are ignored, as anyone can set them.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
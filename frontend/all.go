@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+	"github.com/superliuwr/jaeger-demo/frontend/clients"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// localBackends makes execute start in-process stand-ins of the downstream
+// services and call them instead of the containers.
+var localBackends bool
+
+// all runs the whole demo in one process: the frontend as usual, with the
+// same flags, and stand-ins of customer, driver and route on their usual
+// ports on 127.0.0.1. They all stop together.
+func all() error {
+	// Drop the subcommand, so the frontend's flags that follow it parse.
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	localBackends = true
+	return execute()
+}
+
+// demoCustomers are the customers of the customer service.
+var demoCustomers = map[string]clients.Customer{
+	"123": {ID: "123", Name: "Rachel's Floral Designs", Location: "115,277"},
+	"567": {ID: "567", Name: "Amazing Coffee Roasters", Location: "211,653"},
+	"392": {ID: "392", Name: "Trom Chocolatier", Location: "577,322"},
+	"731": {ID: "731", Name: "Japanese Desserts", Location: "728,326"},
+}
+
+// standIn is a downstream service run in-process by all.
+type standIn struct {
+	name     string
+	hostPort string
+	serve    func(lis net.Listener) error
+	stop     func()
+}
+
+// startLocalBackends starts the stand-ins of customer, driver and route
+// and points options at them. Each reports its spans as its own service,
+// with a tracer created by initTracer with opts. It returns a func that
+// stops them and flushes their tracers.
+func startLocalBackends(options *ConfigOptions, initTracer func(string, ...tracing.Option) opentracing.Tracer, logger log.Factory, opts ...tracing.Option) (func(), error) {
+	var tracers []opentracing.Tracer
+	newTracer := func(service string) opentracing.Tracer {
+		tracer := initTracer(service, opts...)
+		tracers = append(tracers, tracer)
+		return tracer
+	}
+	customer := &http.Server{Handler: customerStandIn(newTracer("customer"), options.Auth)}
+	route := &http.Server{Handler: routeStandIn(newTracer("route"), options.Auth)}
+	interceptors := []grpc.UnaryServerInterceptor{otgrpc.OpenTracingServerInterceptor(newTracer("driver"))}
+	if options.Auth != nil {
+		interceptors = append(interceptors, options.Auth.UnaryServerInterceptor())
+	}
+	driver := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	clients.RegisterDriverServiceServer(driver, &driverStandIn{redis: newTracer("redis")})
+
+	standIns := []standIn{
+		{name: "customer", hostPort: "127.0.0.1:8082", serve: customer.Serve, stop: func() { _ = customer.Close() }},
+		{name: "driver", hostPort: "127.0.0.1:8081", serve: driver.Serve, stop: driver.Stop},
+		{name: "route", hostPort: "127.0.0.1:8083", serve: route.Serve, stop: func() { _ = route.Close() }},
+	}
+	var started []standIn
+	stop := func() {
+		for _, s := range started {
+			s.stop()
+		}
+		// Flush the spans the tracers still buffer.
+		for _, tracer := range tracers {
+			if closer, ok := tracer.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+	for _, s := range standIns {
+		lis, err := net.Listen("tcp", s.hostPort)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("cannot start %s stand-in: %w", s.name, err)
+		}
+		started = append(started, s)
+		go func(s standIn) {
+			if err := s.serve(lis); err != nil && err != http.ErrServerClosed && err != grpc.ErrServerStopped {
+				logger.Bg().Error("Stand-in stopped", zap.String("stand_in", s.name), zap.Error(err))
+			}
+		}(s)
+		logger.Bg().Info("Started stand-in", zap.String("stand_in", s.name), zap.String("address", s.hostPort))
+	}
+
+	options.CustomerHostPort = standIns[0].hostPort
+	options.DriverHostPort = standIns[1].hostPort
+	options.RouteHostPort = standIns[2].hostPort
+	// The route stand-in speaks HTTP only.
+	options.RouteTransport = "http"
+	return stop, nil
+}
+
+// simulate sleeps for about mean, as the real services do while they
+// wait on their databases.
+func simulate(mean time.Duration) {
+	// #nosec
+	time.Sleep(mean/2 + time.Duration(rand.Int63n(int64(mean))))
+}
+
+// customerStandIn answers /customer like the customer service, for the
+// demo customers. Like the other stand-ins, it validates the tokens of
+// requests with authenticator, if not nil.
+func customerStandIn(tracer opentracing.Tracer, authenticator *auth.Authenticator) http.Handler {
+	mux := tracing.NewServeMux(tracer)
+	if authenticator != nil {
+		mux.Use(authenticator.Middleware)
+	}
+	mux.Handle("/customer", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			tracing.TagBaggage(span)
+		}
+		customer, ok := demoCustomers[r.FormValue("customer")]
+		if !ok {
+			customer = demoCustomers["123"]
+		}
+		simulate(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(customer)
+	}))
+	return mux
+}
+
+// routeStandIn answers /route like the route service, with a random ETA.
+func routeStandIn(tracer opentracing.Tracer, authenticator *auth.Authenticator) http.Handler {
+	mux := tracing.NewServeMux(tracer)
+	if authenticator != nil {
+		mux.Use(authenticator.Middleware)
+	}
+	mux.Handle("/route", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			tracing.TagBaggage(span)
+		}
+		simulate(50 * time.Millisecond)
+		// #nosec
+		eta := time.Duration(2+rand.Intn(10)) * time.Minute
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(clients.Route{Pickup: r.FormValue("pickup"), Dropoff: r.FormValue("dropoff"), ETA: int(eta)})
+	}))
+	return mux
+}
+
+// driverStandIn answers FindNearest like the driver service, looking up
+// drivers one by one in a simulated Redis.
+type driverStandIn struct {
+	clients.UnimplementedDriverServiceServer
+	redis opentracing.Tracer
+}
+
+func (d *driverStandIn) FindNearest(ctx context.Context, req *clients.DriverLocationRequest) (*clients.DriverLocationResponse, error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		tracing.TagBaggage(span)
+	}
+	d.redisCall(ctx, "FindDriverIDs", "param.location", req.Location, 20*time.Millisecond)
+	locations := make([]*clients.DriverLocation, 10)
+	for i := range locations {
+		// #nosec
+		id := fmt.Sprintf("T7%05dC", rand.Intn(100000))
+		d.redisCall(ctx, "GetDriver", "param.driverID", id, 10*time.Millisecond)
+		// #nosec
+		locations[i] = &clients.DriverLocation{DriverID: id, Location: fmt.Sprintf("%d,%d", rand.Intn(1000), rand.Intn(1000))}
+	}
+	return &clients.DriverLocationResponse{Locations: locations}, nil
+}
+
+// redisCall records a call to Redis as a client span of the redis service.
+func (d *driverStandIn) redisCall(ctx context.Context, operation, tag, value string, mean time.Duration) {
+	var parent opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		parent = span.Context()
+	}
+	span := d.redis.StartSpan(operation, opentracing.ChildOf(parent), ext.SpanKindRPCClient)
+	defer span.Finish()
+	span.SetTag(tag, value)
+	simulate(mean)
+}
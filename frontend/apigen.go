@@ -0,0 +1,353 @@
+package main
+
+//go:generate go run . apigen --out ../api
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+	"github.com/superliuwr/jaeger-demo/frontend/clients"
+)
+
+// protos are the gRPC contracts of the driver and route services.
+//
+//go:embed clients/driver.proto clients/route.proto
+var protos embed.FS
+
+// apigen writes the contracts of the demo's APIs to a directory, for
+// services in other languages to build against: OpenAPI documents of the
+// frontend, customer and route HTTP APIs, generated from the Go types the
+// frontend encodes and decodes, the protobuf definitions of the gRPC APIs,
+// example payloads of each, and test vectors of the users' tokens.
+// `go generate` writes them to api/ at the root of the repository.
+func apigen() error {
+	err := runAPIGen()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "apigen:", err)
+	}
+	return err
+}
+
+// encodeAPIFile encodes v as indented JSON. gRPC messages are encoded in
+// their proto3 JSON form, with 64-bit integers as strings, as other
+// languages' protobuf libraries read them.
+func encodeAPIFile(v interface{}) ([]byte, error) {
+	if msg, ok := v.(proto.Message); ok {
+		var buf bytes.Buffer
+		err := (&jsonpb.Marshaler{Indent: "  "}).Marshal(&buf, msg)
+		return buf.Bytes(), err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func runAPIGen() error {
+	flags := flag.NewFlagSet("apigen", flag.ExitOnError)
+	out := flags.String("out", "api", "directory to write the contracts to")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+
+	files := map[string]interface{}{
+		"openapi/frontend.json": frontendAPI(),
+		"openapi/customer.json": customerAPI(),
+		"openapi/route.json":    routeAPI(),
+	}
+	for name, example := range apiExamples() {
+		files["examples/"+name+".json"] = example
+	}
+	vectors, err := auth.TestVectors()
+	if err != nil {
+		return err
+	}
+	files["auth/jwt-vectors.json"] = vectors
+	for name, v := range files {
+		data, err := encodeAPIFile(v)
+		if err != nil {
+			return fmt.Errorf("cannot encode %s: %w", name, err)
+		}
+		if err := writeAPIFile(*out, name, append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"driver.proto", "route.proto"} {
+		data, err := protos.ReadFile("clients/" + name)
+		if err != nil {
+			return err
+		}
+		if err := writeAPIFile(*out, "proto/"+name, data); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("wrote %d files to %s\n", len(files)+2, *out)
+	return nil
+}
+
+func writeAPIFile(dir, name string, data []byte) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// openAPI is an OpenAPI 3 document. Its schemas are generated from Go
+// types as they are encoded to JSON.
+type openAPI struct {
+	OpenAPI    string                            `json:"openapi"`
+	Info       map[string]string                 `json:"info"`
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+func newOpenAPI(title, description string) *openAPI {
+	doc := &openAPI{
+		OpenAPI: "3.0.3",
+		Info:    map[string]string{"title": title, "description": description, "version": "1.0.0"},
+		Paths:   make(map[string]map[string]interface{}),
+	}
+	doc.Components.Schemas = make(map[string]interface{})
+	return doc
+}
+
+// schema returns the schema of the JSON encoding of v's type, adding the
+// schemas of the structs it refers to to the components.
+func (doc *openAPI) schema(v interface{}) interface{} {
+	return doc.schemaOf(reflect.TypeOf(v))
+}
+
+func (doc *openAPI) schemaOf(t reflect.Type) interface{} {
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return doc.schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": doc.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": doc.schemaOf(t.Elem())}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := doc.Components.Schemas[t.Name()]; ok {
+			return ref
+		}
+		// Placeholder, in case the struct refers to itself.
+		doc.Components.Schemas[t.Name()] = nil
+		properties := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, omitempty, ok := jsonName(field)
+			if !ok {
+				continue
+			}
+			properties[name] = doc.schemaOf(field.Type)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		doc.Components.Schemas[t.Name()] = schema
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// jsonName returns the name encoding/json gives field, and whether it is
+// left out when empty. Unexported fields and fields tagged "-" are not
+// encoded.
+func jsonName(field reflect.StructField) (string, bool, bool) {
+	if field.PkgPath != "" {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(options, "omitempty"), true
+}
+
+// jsonContent is an OpenAPI content map of JSON described by schema.
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func queryParameter(name, description string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "in": "query", "required": true, "description": description, "schema": map[string]string{"type": "string"}}
+}
+
+func frontendAPI() *openAPI {
+	doc := newOpenAPI("HotROD frontend", "The API the web UI calls. Every response carries the trace of the request in X-Trace-Id and traceresponse, and its X-Request-Id.")
+	doc.Paths["/dispatch"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":    "Dispatch the nearest driver to a customer.",
+			"parameters": []interface{}{queryParameter("customer", "ID of the customer, e.g. 123")},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "The driver dispatched and its ETA. Server-Timing breaks down the time spent on each dependency.",
+					"content":     jsonContent(doc.schema(Response{})),
+				},
+				"401": map[string]interface{}{"description": "The bearer token is invalid or expired, see /login."},
+				"429": map[string]interface{}{"description": "Over the dispatch rate limit. Retry-After says when to try again."},
+				"500": map[string]interface{}{
+					"description": "Some calls to a dependency failed. Other failures are answered as plain text.",
+					"content":     jsonContent(doc.schema(FanOutError{})),
+				},
+				"503": map[string]interface{}{"description": "The frontend is shutting down. Retry-After says when to try again."},
+			},
+		},
+	}
+	doc.Paths["/rating"] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary": "Rate the driver of a dispatch.",
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{"application/x-www-form-urlencoded": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":     "object",
+						"required": []string{"journey", "driver", "rating"},
+						"properties": map[string]interface{}{
+							"journey": map[string]string{"type": "string", "description": "JourneyID of the dispatch"},
+							"driver":  map[string]string{"type": "string"},
+							"rating":  map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 5},
+						},
+					},
+				}},
+			},
+			"responses": map[string]interface{}{
+				"204": map[string]interface{}{"description": "The rating was recorded."},
+				"400": map[string]interface{}{"description": "A parameter is missing or the rating is not from 1 to 5."},
+				"401": map[string]interface{}{"description": "The bearer token is invalid or expired, see /login."},
+			},
+		},
+	}
+	doc.Paths["/login"] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"summary":     "Get a token for a user. Served when the frontend runs with JWT_SECRET.",
+			"description": "Requests with the token as a bearer token in Authorization are made as the user, on every service they reach. Requests with an invalid or expired token are rejected with 401.",
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{"application/x-www-form-urlencoded": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":       "object",
+						"required":   []string{"user"},
+						"properties": map[string]interface{}{"user": map[string]interface{}{"type": "string", "maxLength": 64}},
+					},
+				}},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "A JWT signed with HS256.",
+					"content": jsonContent(map[string]interface{}{
+						"type":     "object",
+						"required": []string{"token", "expires"},
+						"properties": map[string]interface{}{
+							"token":   map[string]string{"type": "string"},
+							"expires": map[string]string{"type": "string", "format": "date-time"},
+						},
+					}),
+				},
+				"400": map[string]interface{}{"description": "The user name is missing or too long."},
+			},
+		},
+	}
+	return doc
+}
+
+func customerAPI() *openAPI {
+	doc := newOpenAPI("Customer service", "The API the frontend calls to look up customers. The customer service also reads the customer and session baggage items.")
+	doc.Paths["/customer"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":    "Look up a customer.",
+			"parameters": []interface{}{queryParameter("customer", "ID of the customer")},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "The customer, with its location as \"x,y\".", "content": jsonContent(doc.schema(clients.Customer{}))},
+			},
+		},
+	}
+	return doc
+}
+
+func routeAPI() *openAPI {
+	doc := newOpenAPI("Route service", "The HTTP API the frontend calls to estimate the time from a driver to a customer. The same call is available over gRPC, see proto/route.proto.")
+	doc.Paths["/route"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Estimate the time to drive from pickup to dropoff.",
+			"parameters": []interface{}{
+				queryParameter("pickup", "location as \"x,y\""),
+				queryParameter("dropoff", "location as \"x,y\""),
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "The route, with its ETA in nanoseconds.", "content": jsonContent(doc.schema(clients.Route{}))},
+			},
+		},
+	}
+	return doc
+}
+
+// apiExamples are payloads of the APIs, by file name.
+func apiExamples() map[string]interface{} {
+	customer := demoCustomers["123"]
+	return map[string]interface{}{
+		"dispatch-response": Response{
+			Driver:    "T751767C",
+			ETA:       int(150 * time.Second),
+			JourneyID: "5f19d1022f8ec0c9",
+			Weather:   &clients.Weather{Condition: "rain", Factor: 1.25, Source: "live"},
+			Dependencies: []DependencyCost{
+				{Name: "customer", Wait: 110 * time.Millisecond, CallTime: 110 * time.Millisecond, Calls: 1},
+				{Name: "driver", Wait: 210 * time.Millisecond, CallTime: 210 * time.Millisecond, Calls: 1},
+				{Name: "route", Wait: 160 * time.Millisecond, CallTime: 540 * time.Millisecond, Calls: 10},
+				{Name: "weather", Wait: 0, CallTime: 90 * time.Millisecond, Calls: 1},
+			},
+		},
+		"dispatch-error": FanOutError{
+			Dependency: "route",
+			Calls:      10,
+			Failures:   []DependencyError{{Dependency: "route", Target: "T751767C", Error: "context deadline exceeded"}},
+		},
+		"customer-response":           customer,
+		"route-response":              clients.Route{Pickup: "728,326", Dropoff: customer.Location, ETA: int(4 * time.Minute)},
+		"driver-find-nearest-request": &clients.DriverLocationRequest{Location: customer.Location},
+		"driver-find-nearest-response": &clients.DriverLocationResponse{Locations: []*clients.DriverLocation{
+			{DriverID: "T751767C", Location: "728,326"},
+			{DriverID: "T712345C", Location: "577,322"},
+		}},
+		"route-find-route-request":  &clients.RouteRequest{Pickup: "728,326", Dropoff: customer.Location},
+		"route-find-route-response": &clients.RouteResponse{Pickup: "728,326", Dropoff: customer.Location, Eta: int64(4 * time.Minute)},
+	}
+}
@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Issuer is the iss claim of the tokens the frontend issues.
+	Issuer = "hotrod"
+	// BaggageKey is the baggage item that carries the user of a request to
+	// every service it reaches. The services also tag their spans with it.
+	BaggageKey = "user"
+	// maxUserLength bounds the user names tokens are issued for.
+	maxUserLength = 64
+)
+
+var failures = expvar.NewMap("auth_failures")
+
+// header is the JOSE header of every token, base64url encoded.
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the claims of the tokens the frontend issues.
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Authenticator issues JSON web tokens signed with HS256, and validates them
+// on incoming requests. Every service validates them with the same secret.
+type Authenticator struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// New creates an Authenticator signing tokens with secret, valid for ttl.
+func New(secret string, ttl time.Duration) *Authenticator {
+	return &Authenticator{secret: []byte(secret), ttl: ttl}
+}
+
+// Issue returns a signed token for user, and its claims.
+func (a *Authenticator) Issue(user string) (string, Claims, error) {
+	now := time.Now()
+	claims := Claims{Issuer: Issuer, Subject: user, IssuedAt: now.Unix(), ExpiresAt: now.Add(a.ttl).Unix()}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", claims, err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + a.sign(signed), claims, nil
+}
+
+func (a *Authenticator) sign(signed string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Errors of Verify, whose text is the reason counted in auth_failures.
+var (
+	ErrMalformed = errors.New("malformed")
+	ErrSignature = errors.New("bad_signature")
+	ErrExpired   = errors.New("expired")
+)
+
+// Verify checks the signature and expiry of token, and returns its claims.
+// The rules are those of "Token format" in the README, which every service
+// follows, and api/auth/jwt-vectors.json tests them.
+func (a *Authenticator) Verify(token string) (Claims, error) {
+	return a.verify(token, time.Now().Unix())
+}
+
+// verify is Verify at the Unix time now.
+func (a *Authenticator) verify(token string, now int64) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return claims, ErrMalformed
+	}
+	if !hmac.Equal([]byte(a.sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return claims, ErrSignature
+	}
+	payload, err := base64.RawURLEncoding.Strict().DecodeString(parts[1])
+	if err != nil || !parseClaims(payload, &claims) || claims.Issuer != Issuer || claims.Subject == "" || claims.ExpiresAt <= 0 {
+		return claims, ErrMalformed
+	}
+	if now >= claims.ExpiresAt {
+		return claims, ErrExpired
+	}
+	return claims, nil
+}
+
+// parseClaims decodes the iss, sub and exp claims of payload, a JSON
+// object, into claims. Unlike json.Unmarshal, it matches their names
+// exactly, as the other services do.
+func parseClaims(payload []byte, claims *Claims) bool {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(payload, &raw) != nil {
+		return false
+	}
+	for name, v := range map[string]interface{}{"iss": &claims.Issuer, "sub": &claims.Subject, "exp": &claims.ExpiresAt} {
+		if value, ok := raw[name]; ok && json.Unmarshal(value, v) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// LoginHandler issues a token for the user in the form of a POST request,
+// answering {"token": ..., "expires": ...}. The demo has no passwords: any
+// user name of up to 64 printable characters gets a token.
+func (a *Authenticator) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.FormValue("user")
+		if !validUser(user) {
+			http.Error(w, "user must be 1 to 64 printable characters", http.StatusBadRequest)
+			return
+		}
+		token, claims, err := a.Issue(user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			span.LogFields(log.String("event", "token issued"), log.String("user", user))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(struct {
+			Token   string    `json:"token"`
+			Expires time.Time `json:"expires"`
+		}{token, time.Unix(claims.ExpiresAt, 0).UTC()})
+	})
+}
+
+func validUser(user string) bool {
+	if user == "" || len(user) > maxUserLength {
+		return false
+	}
+	for i := 0; i < len(user); i++ {
+		if user[i] < 0x20 || user[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware validates the bearer token of each request that has one. A
+// valid token puts the token and its user in the request context, so
+// clients forward the token, and sets the user as a tag and a baggage item
+// of the request span. Requests with an invalid or expired token are
+// rejected with 401 Unauthorized and counted by reason in the
+// auth_failures expvar. Requests without a token are served anonymously,
+// and a user baggage item they carry is cleared, so it cannot be forged.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := opentracing.SpanFromContext(r.Context())
+		token, ok := bearer(r)
+		if !ok {
+			if span != nil && span.BaggageItem(BaggageKey) != "" {
+				span.SetBaggageItem(BaggageKey, "")
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := a.Verify(token)
+		if err != nil {
+			failures.Add(err.Error(), 1)
+			if span != nil {
+				ext.Error.Set(span, true)
+				span.LogFields(log.String("event", "invalid token"), log.Error(err))
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if span != nil {
+			span.SetTag(BaggageKey, claims.Subject)
+			span.SetBaggageItem(BaggageKey, claims.Subject)
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), token, claims.Subject)))
+	})
+}
+
+// bearer returns the token of the Authorization header of r, if it has one.
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+type contextKey struct{}
+
+type principal struct {
+	token string
+	user  string
+}
+
+// NewContext returns a copy of ctx that carries the token of user.
+func NewContext(ctx context.Context, token, user string) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal{token: token, user: user})
+}
+
+// TokenFromContext returns the token in ctx, or "" if there is none.
+func TokenFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(contextKey{}).(principal)
+	return p.token
+}
+
+// UserFromContext returns the user of the token in ctx, or "" if there is
+// none.
+func UserFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(contextKey{}).(principal)
+	return p.user
+}
+
+// UnaryServerInterceptor is Middleware for gRPC servers, with the token in
+// the authorization metadata of calls. Calls with an invalid or expired
+// token fail with Unauthenticated. It must come after the tracing
+// interceptor in the chain, so the server span is in the context.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		span := opentracing.SpanFromContext(ctx)
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+			scheme, t, ok := strings.Cut(md.Get("authorization")[0], " ")
+			if ok && strings.EqualFold(scheme, "Bearer") {
+				token = t
+			}
+		}
+		if token == "" {
+			if span != nil && span.BaggageItem(BaggageKey) != "" {
+				span.SetBaggageItem(BaggageKey, "")
+			}
+			return handler(ctx, req)
+		}
+		claims, err := a.Verify(token)
+		if err != nil {
+			failures.Add(err.Error(), 1)
+			if span != nil {
+				ext.Error.Set(span, true)
+				span.LogFields(log.String("event", "invalid token"), log.Error(err))
+			}
+			return nil, status.Error(codes.Unauthenticated, "invalid token: "+err.Error())
+		}
+		if span != nil {
+			span.SetTag(BaggageKey, claims.Subject)
+			span.SetBaggageItem(BaggageKey, claims.Subject)
+		}
+		return handler(NewContext(ctx, token, claims.Subject), req)
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// Vectors are test vectors of the token format, for the services that
+// verify tokens: each token, verified with Secret at the Unix time Now,
+// must give User, or fail with Error, one of the reasons of auth_failures.
+type Vectors struct {
+	Secret  string   `json:"secret"`
+	Now     int64    `json:"now"`
+	Vectors []Vector `json:"vectors"`
+}
+
+// Vector is a token and how verifying it must turn out.
+type Vector struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	User  string `json:"user,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// TestVectors returns the test vectors of the token format. It fails if
+// Verify does not pass them, so they cannot drift from the frontend.
+func TestVectors() (Vectors, error) {
+	const secret, now = "vectors", 1700000000
+	a, other := New(secret, 0), New("another secret", 0)
+	encode := base64.RawURLEncoding.EncodeToString
+	signed := func(a *Authenticator, header, payload string) string {
+		return header + "." + payload + "." + a.sign(header+"."+payload)
+	}
+	token := func(claims string) string { return signed(a, header, encode([]byte(claims))) }
+	claims := func(sub, exp string) string {
+		return `{"iss":"hotrod","sub":` + sub + `,"iat":1699996400,"exp":` + exp + `}`
+	}
+	valid := claims(`"alice"`, "1700003600")
+	malformed, expired, badSignature := ErrMalformed.Error(), ErrExpired.Error(), ErrSignature.Error()
+	vectors := []Vector{
+		{Name: "valid", Token: token(valid), User: "alice"},
+		{Name: "expires in a second", Token: token(claims(`"alice"`, "1700000001")), User: "alice"},
+		{Name: "expires now", Token: token(claims(`"alice"`, "1700000000")), Error: expired},
+		{Name: "other secret", Token: signed(other, header, encode([]byte(valid))), Error: badSignature},
+		{Name: "claims changed after signing", Token: header + "." + encode([]byte(claims(`"mallory"`, "1700003600"))) + "." + a.sign(header+"."+encode([]byte(valid))), Error: badSignature},
+		{Name: "two segments", Token: header + "." + encode([]byte(valid)), Error: malformed},
+		{Name: "extra segment", Token: token(valid) + ".x", Error: malformed},
+		{Name: "alg none", Token: signed(a, encode([]byte(`{"alg":"none","typ":"JWT"}`)), encode([]byte(valid))), Error: malformed},
+		{Name: "padded claims", Token: signed(a, header, base64.URLEncoding.EncodeToString([]byte(valid+" "))), Error: malformed},
+		{Name: "claims not JSON", Token: token("alice"), Error: malformed},
+		{Name: "other issuer", Token: token(`{"iss":"other","sub":"alice","exp":1700003600}`), Error: malformed},
+		{Name: "issuer claim in upper case", Token: token(`{"ISS":"hotrod","sub":"alice","exp":1700003600}`), Error: malformed},
+		{Name: "no subject", Token: token(`{"iss":"hotrod","exp":1700003600}`), Error: malformed},
+		{Name: "empty subject", Token: token(claims(`""`, "1700003600")), Error: malformed},
+		{Name: "subject not a string", Token: token(claims("42", "1700003600")), Error: malformed},
+		{Name: "no expiry", Token: token(`{"iss":"hotrod","sub":"alice"}`), Error: malformed},
+		{Name: "expiry a string", Token: token(claims(`"alice"`, `"1700003600"`)), Error: malformed},
+	}
+	for _, v := range vectors {
+		claims, err := a.verify(v.Token, now)
+		if err != nil && err.Error() != v.Error || err == nil && claims.Subject != v.User {
+			return Vectors{}, errors.New("token vector " + v.Name + " fails")
+		}
+	}
+	return Vectors{Secret: secret, Now: now, Vectors: vectors}, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+
+	"github.com/superliuwr/jaeger-demo/frontend/clients"
+	"github.com/superliuwr/jaeger-demo/frontend/depgraph"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+// benchmark is a standardized in-process benchmark of one of the demo's key paths.
+type benchmark struct {
+	name string
+	run  func(b *testing.B) error
+}
+
+// bench runs the standardized benchmarks against in-process fakes of the
+// downstream services and prints the results, so that performance changes
+// to the demo itself are measurable.
+func bench() error {
+	backends, err := startBenchBackends()
+	if err != nil {
+		return err
+	}
+	defer backends.stop()
+
+	benchmarks := append([]benchmark{
+		{name: "json-encode", run: benchJSONEncode},
+		{name: "dispatch", run: backends.benchDispatch},
+	}, uiBenchmarks()...)
+	for _, bm := range benchmarks {
+		var err error
+		result := testing.Benchmark(func(b *testing.B) { err = bm.run(b) })
+		if err != nil {
+			return fmt.Errorf("benchmark %s failed: %v", bm.name, err)
+		}
+		fmt.Printf("%-12s %s\t%s\n", bm.name, result.String(), result.MemString())
+	}
+
+	return nil
+}
+
+func benchJSONEncode(b *testing.B) error {
+	b.ReportAllocs()
+	response := &Response{Driver: "T712345C", ETA: 2 * int(time.Minute)}
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchBackends are in-process fakes of the customer, route and driver services.
+type benchBackends struct {
+	customer *httptest.Server
+	route    *httptest.Server
+	driver   *grpc.Server
+	options  ConfigOptions
+}
+
+func startBenchBackends() (*benchBackends, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	driver := grpc.NewServer()
+	clients.RegisterDriverServiceServer(driver, &benchDriverServer{})
+	go func() { _ = driver.Serve(lis) }()
+
+	customer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(clients.Customer{ID: r.FormValue("customer"), Name: "Bench", Location: "1,1"})
+	}))
+	route := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(clients.Route{Pickup: r.FormValue("pickup"), Dropoff: r.FormValue("dropoff"), ETA: int(time.Minute)})
+	}))
+
+	return &benchBackends{
+		customer: customer,
+		route:    route,
+		driver:   driver,
+		options: ConfigOptions{
+			DriverHostPort:   lis.Addr().String(),
+			CustomerHostPort: customer.Listener.Addr().String(),
+			RouteHostPort:    route.Listener.Addr().String(),
+			DepGraphWindow:   time.Minute,
+		},
+	}, nil
+}
+
+func (bb *benchBackends) stop() {
+	bb.customer.Close()
+	bb.route.Close()
+	bb.driver.Stop()
+}
+
+func (bb *benchBackends) benchDispatch(b *testing.B) error {
+	b.ReportAllocs()
+	logger := log.NewFactory(zap.NewNop(), zapcore.InfoLevel)
+	eta := newBestETA(opentracing.NoopTracer{}, logger, depgraph.New("frontend", time.Minute), bb.options)
+	defer eta.pool.Stop()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := eta.Get(context.Background(), "123"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchDriverServer returns a fixed set of drivers without any simulated delay.
+type benchDriverServer struct {
+	clients.UnimplementedDriverServiceServer
+}
+
+func (benchDriverServer) FindNearest(ctx context.Context, req *clients.DriverLocationRequest) (*clients.DriverLocationResponse, error) {
+	locations := make([]*clients.DriverLocation, 10)
+	for i := range locations {
+		locations[i] = &clients.DriverLocation{DriverID: fmt.Sprintf("T7%05dC", i), Location: "2,2"}
+	}
+	return &clients.DriverLocationResponse{Locations: locations}, nil
+}
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"math"
 	"sync"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 
 	"github.com/superliuwr/jaeger-demo/frontend/clients"
+	"github.com/superliuwr/jaeger-demo/frontend/depgraph"
 	"github.com/superliuwr/jaeger-demo/frontend/log"
 	"github.com/superliuwr/jaeger-demo/frontend/pool"
 )
@@ -19,81 +21,183 @@ const RouteWorkerPoolSize = 3
 type bestETA struct {
 	customer *clients.CustomerClient
 	driver   *clients.DriverClient
-	route    *clients.RouteClient
+	route    routeFinder
+	weather  *clients.WeatherClient
 	pool     *pool.Pool
+	depGraph *depgraph.Graph
 	logger   log.Factory
 }
 
 // Response contains ETA for a trip.
 type Response struct {
-	Driver string
-	ETA    int
+	Driver    string
+	ETA       int
+	JourneyID string
+	// Weather is the weather ETA is adjusted for, if the frontend runs with
+	// WEATHER_MODE.
+	Weather *clients.Weather `json:",omitempty"`
+
+	// Dependencies break down where the dispatch spent its time.
+	Dependencies []DependencyCost
 }
 
-func newBestETA(tracer opentracing.Tracer, logger log.Factory, options ConfigOptions) *bestETA {
+// DependencyCost is the cost of the calls a dispatch made to one downstream service.
+//
+// The durations are measured on the wall clock around the client calls,
+// not taken from their spans. They include the retries of a call and the
+// backoffs between them, so a retried call lasts longer here than any of
+// its HTTP spans in Jaeger. The Wait of route also includes the time the
+// calls waited for a worker of the route pool.
+type DependencyCost struct {
+	Name string
+	// Wait is how long the dispatch waited on the dependency. It is
+	// shorter than CallTime when calls run in parallel.
+	Wait time.Duration
+	// CallTime is the sum of the durations of the client calls.
+	CallTime time.Duration
+	Calls    int
+	Errors   int
+}
+
+// routeFinder is implemented by the HTTP and gRPC route clients.
+type routeFinder interface {
+	FindRoute(ctx context.Context, pickup, dropoff string) (*clients.Route, error)
+}
+
+func newBestETA(tracer opentracing.Tracer, logger log.Factory, depGraph *depgraph.Graph, options ConfigOptions) *bestETA {
+	routeLogger := logger.With(zap.String("component", "route_client"))
+	var route routeFinder = clients.NewRouteClient(
+		tracer,
+		routeLogger,
+		options.RouteHostPort,
+		options.MockBackends,
+	)
+	if options.RouteTransport == "grpc" && !options.MockBackends {
+		route = clients.NewRouteGRPCClient(tracer, routeLogger, options.RouteGRPCHostPort)
+	}
+
 	return &bestETA{
 		customer: clients.NewCustomerClient(
 			tracer,
 			logger.With(zap.String("component", "customer_client")),
 			options.CustomerHostPort,
+			options.FieldEncryptionKey,
 		),
 		driver: clients.NewDriverClient(
 			tracer,
 			logger.With(zap.String("component", "driver_client")),
 			options.DriverHostPort,
+			options.DriverGRPC,
 		),
-		route: clients.NewRouteClient(
-			tracer,
-			logger.With(zap.String("component", "route_client")),
-			options.RouteHostPort,
-		),
-		pool:   pool.New(RouteWorkerPoolSize),
-		logger: logger,
+		route:    route,
+		weather:  clients.NewWeatherClient(tracer, logger.With(zap.String("component", "weather_client")), options.Weather),
+		pool:     pool.New(RouteWorkerPoolSize),
+		depGraph: depGraph,
+		logger:   logger,
 	}
 }
 
 func (eta *bestETA) Get(ctx context.Context, customerID string) (*Response, error) {
+	dependencies := make([]DependencyCost, 0, 4)
+
+	eta.depGraph.Record("customer")
+	start := time.Now()
 	customer, err := eta.customer.GetCustomer(ctx, customerID)
+	dependencies = append(dependencies, singleCall("customer", time.Since(start), err))
 	if err != nil {
-		return nil, err
+		return nil, wrapCall("customer", "GetCustomer", err)
 	}
 	eta.logger.For(ctx).Info("Found customer", zap.Any("customer", customer))
 
-	if span := opentracing.SpanFromContext(ctx); span != nil {
-		span.SetBaggageItem("customer", customer.Name)
-	}
-
+	eta.depGraph.Record("driver")
+	start = time.Now()
 	drivers, err := eta.driver.FindNearest(ctx, customer.Location)
+	dependencies = append(dependencies, singleCall("driver", time.Since(start), err))
 	if err != nil {
-		return nil, err
+		return nil, wrapCall("driver", "FindNearest", err)
 	}
 	eta.logger.For(ctx).Info("Found drivers", zap.Any("drivers", drivers))
 
+	// The weather is looked up while the routes are, as it doesn't depend
+	// on them.
+	var weather *clients.Weather
+	var weatherCost DependencyCost
+	weatherDone := make(chan struct{})
+	go func() {
+		defer close(weatherDone)
+		if eta.weather == nil {
+			return
+		}
+		eta.depGraph.Record("weather")
+		start := time.Now()
+		weather = eta.weather.Current(ctx)
+		weatherCost = DependencyCost{Name: "weather", CallTime: time.Since(start), Calls: 1}
+		if weather.Source == "fallback" {
+			weatherCost.Errors = 1
+		}
+	}()
+
+	start = time.Now()
 	results := eta.getRoutes(ctx, customer, drivers)
+	routes := DependencyCost{Name: "route", Wait: time.Since(start)}
+	for _, result := range results {
+		routes.Calls++
+		routes.CallTime += result.duration
+		if result.err != nil {
+			routes.Errors++
+		}
+	}
+	dependencies = append(dependencies, routes)
 	eta.logger.For(ctx).Info("Found routes", zap.Any("routes", results))
+	// The dispatch only waits on the weather if it takes longer than the
+	// routes.
+	start = time.Now()
+	<-weatherDone
+	if weather != nil {
+		weatherCost.Wait = time.Since(start)
+		dependencies = append(dependencies, weatherCost)
+	}
 
-	resp := &Response{ETA: math.MaxInt64}
+	resp := &Response{ETA: math.MaxInt64, Weather: weather, Dependencies: dependencies}
+	fanOutErr := &FanOutError{Dependency: "route", Calls: len(results)}
 	for _, result := range results {
 		if result.err != nil {
-			return nil, err
+			fanOutErr.add(result.driver, wrapCall("route", "FindRoute", result.err))
+			continue
 		}
 		if result.route.ETA < resp.ETA {
 			resp.ETA = result.route.ETA
 			resp.Driver = result.driver
 		}
 	}
+	if len(fanOutErr.Failures) > 0 {
+		fanOutErr.annotate(opentracing.SpanFromContext(ctx))
+		return nil, fanOutErr
+	}
 	if resp.Driver == "" {
 		return nil, errors.New("no routes found")
 	}
+	if weather != nil {
+		resp.ETA = int(float64(resp.ETA) * weather.Factor)
+	}
 
 	eta.logger.For(ctx).Info("Dispatch successful", zap.String("driver", resp.Driver), zap.Int("eta", resp.ETA))
 	return resp, nil
 }
 
+func singleCall(name string, duration time.Duration, err error) DependencyCost {
+	cost := DependencyCost{Name: name, Wait: duration, CallTime: duration, Calls: 1}
+	if err != nil {
+		cost.Errors = 1
+	}
+	return cost
+}
+
 type routeResult struct {
-	driver string
-	route  *clients.Route
-	err    error
+	driver   string
+	route    *clients.Route
+	err      error
+	duration time.Duration
 }
 
 // getRoutes calls Route service for each (customer, driver) pair
@@ -107,12 +211,16 @@ func (eta *bestETA) getRoutes(ctx context.Context, customer *clients.Customer, d
 		driver := dd // capture loop var
 		// Use worker pool to (potentially) execute requests in parallel
 		eta.pool.Execute(func() {
+			eta.depGraph.Record("route")
+			routeCallsByPair.Add(routePairLabel.Value(driver.Location+"->"+customer.Location), 1)
+			start := time.Now()
 			route, err := eta.route.FindRoute(ctx, driver.Location, customer.Location)
 			routesLock.Lock()
 			results = append(results, routeResult{
-				driver: driver.DriverID,
-				route:  route,
-				err:    err,
+				driver:   driver.DriverID,
+				route:    route,
+				err:      err,
+				duration: time.Since(start),
 			})
 			routesLock.Unlock()
 			wg.Done()
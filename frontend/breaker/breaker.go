@@ -0,0 +1,122 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+const (
+	// MaxConsecutiveFailures is how many failed calls in a row open a breaker.
+	MaxConsecutiveFailures = 5
+	// OpenTimeout is how long a breaker stays open before it lets a trial call through.
+	OpenTimeout = 10 * time.Second
+)
+
+var breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "frontend",
+	Name:      "circuit_breaker_state",
+	Help:      "State of the circuit breaker per downstream service: 0 closed, 1 half-open, 2 open.",
+}, []string{"breaker"})
+
+// Breaker is a circuit breaker around the calls to one downstream service.
+// Once calls keep failing it fails fast, without calling the service, until
+// OpenTimeout has passed.
+type Breaker struct {
+	name string
+	cb   *gobreaker.CircuitBreaker
+}
+
+// New creates a closed breaker for the named downstream service.
+func New(name string, logger log.Factory) *Breaker {
+	breakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+	return &Breaker{
+		name: name,
+		cb: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    name,
+			Timeout: OpenTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= MaxConsecutiveFailures
+			},
+			IsSuccessful: func(err error) bool {
+				return err == nil || !Fault(err)
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				breakerState.WithLabelValues(name).Set(float64(to))
+				logger.Bg().Info("Circuit breaker state changed", zap.String("breaker", name), zap.Stringer("from", from), zap.Stringer("to", to))
+			},
+		}),
+	}
+}
+
+// Do runs call through the breaker. If the breaker is open, call is not run
+// and the error is gobreaker.ErrOpenState. State changes seen by the call and
+// fast failures are logged on the span in ctx.
+func (b *Breaker) Do(ctx context.Context, call func() error) error {
+	before := b.cb.State()
+	_, err := b.cb.Execute(func() (interface{}, error) {
+		return nil, call()
+	})
+	after := b.cb.State()
+
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return err
+	}
+	if before != after {
+		span.LogFields(
+			otlog.String("event", "circuit breaker state change"),
+			otlog.String("breaker", b.name),
+			otlog.String("from", before.String()),
+			otlog.String("to", after.String()),
+		)
+	}
+	if Rejected(err) {
+		ext.Error.Set(span, true)
+		span.LogFields(
+			otlog.String("event", "circuit breaker rejected call"),
+			otlog.String("breaker", b.name),
+			otlog.String("state", after.String()),
+		)
+	}
+	return err
+}
+
+// Fault tells if err is a fault of the service, which counts as a failure
+// towards opening the breaker. Errors of the call itself are not: a 4xx
+// response other than 429 Too Many Requests, a gRPC call rejected for its
+// arguments or credentials, and a call its caller gave up on.
+func Fault(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *tracing.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	switch status.Code(err) {
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+		return false
+	}
+	return true
+}
+
+// Rejected tells if err is a call the breaker failed fast, without making it.
+func Rejected(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
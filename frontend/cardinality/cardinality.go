@@ -0,0 +1,53 @@
+package cardinality
+
+import (
+	"expvar"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// overflows counts, per label, the values that were folded into hash buckets.
+var overflows = expvar.NewMap("metric_label_overflows")
+
+// Limiter caps the number of distinct values of one metric label. The first
+// max values are kept as they are; later values are hashed into a fixed
+// number of buckets, so a label fed with IDs cannot grow without bound.
+type Limiter struct {
+	label   string
+	max     int
+	buckets uint32
+
+	lock sync.Mutex
+	seen map[string]struct{}
+}
+
+// New creates a Limiter for label that keeps up to max distinct values and
+// folds the rest into the given number of buckets.
+func New(label string, max int, buckets uint32) *Limiter {
+	return &Limiter{
+		label:   label,
+		max:     max,
+		buckets: buckets,
+		seen:    make(map[string]struct{}, max),
+	}
+}
+
+// Value returns the label value to record for v.
+func (l *Limiter) Value(v string) string {
+	l.lock.Lock()
+	_, ok := l.seen[v]
+	if !ok && len(l.seen) < l.max {
+		l.seen[v] = struct{}{}
+		ok = true
+	}
+	l.lock.Unlock()
+
+	if ok {
+		return v
+	}
+	overflows.Add(l.label, 1)
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(v))
+	return fmt.Sprintf("other-%d", h.Sum32()%l.buckets)
+}
@@ -0,0 +1,284 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/incidents"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+// Clock is the simulated time chaos calendars follow. It reads Start when
+// it is created and then runs Speed times as fast as real time, so that a
+// long-running demo goes through many simulated days.
+type Clock struct {
+	Start  time.Time
+	Speed  float64
+	origin time.Time
+}
+
+// NewClock creates a Clock that starts now at start.
+func NewClock(start time.Time, speed float64) *Clock {
+	return &Clock{Start: start, Speed: speed, origin: time.Now()}
+}
+
+// Now returns the simulated time.
+func (c *Clock) Now() time.Time {
+	return c.Start.Add(time.Duration(float64(time.Since(c.origin)) * c.Speed))
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Rule is chaos that recurs on some days of the week, during a window of
+// the day: delays of the calls to downstream services, like those of
+// Delays, and a rate of dispatches to fail, like ErrorRate.
+type Rule struct {
+	text      string
+	days      [7]bool
+	start     time.Duration
+	end       time.Duration
+	delays    map[string]Delay
+	errorRate float64
+}
+
+// String returns the rule as ParseCalendar reads it.
+func (r Rule) String() string {
+	return r.text
+}
+
+// activeAt tells if t is in the rule's window.
+func (r Rule) activeAt(t time.Time) bool {
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	return r.days[t.Weekday()] && sinceMidnight >= r.start && sinceMidnight < r.end
+}
+
+// ParseCalendar parses rules separated by ";", each
+// "<days> <HH:MM>-<HH:MM> <effect>,...". Days are "daily", "weekdays",
+// "weekends", or a comma-separated list of days and ranges of days, e.g.
+// "mon-fri" or "sat,sun". An effect is "<service>=<delay>" as for
+// --inject-delay, or "errors=<rate>" to fail that fraction of dispatches,
+// e.g. "mon-fri 09:00-10:00 driver=500ms±200ms,errors=0.1". Windows end
+// at the latest at 24:00.
+func ParseCalendar(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, text := range strings.Split(s, ";") {
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			continue
+		}
+		rule, err := parseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chaos rule %q: %w", text, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(text string) (Rule, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return Rule{}, fmt.Errorf("want <days> <HH:MM>-<HH:MM> <effect>,...")
+	}
+	rule := Rule{text: text, delays: make(map[string]Delay)}
+	var err error
+	if rule.days, err = parseDays(fields[0]); err != nil {
+		return Rule{}, err
+	}
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return Rule{}, fmt.Errorf("want a window <HH:MM>-<HH:MM>, got %q", fields[1])
+	}
+	if rule.start, err = parseTimeOfDay(from); err != nil {
+		return Rule{}, err
+	}
+	if rule.end, err = parseTimeOfDay(to); err != nil {
+		return Rule{}, err
+	}
+	if rule.end <= rule.start {
+		return Rule{}, fmt.Errorf("window %s ends before it starts, split windows across midnight in two", fields[1])
+	}
+	for _, effect := range strings.Split(fields[2], ",") {
+		if rate, ok := strings.CutPrefix(effect, "errors="); ok {
+			if rule.errorRate, err = strconv.ParseFloat(rate, 64); err != nil || rule.errorRate < 0 || rule.errorRate > 1 {
+				return Rule{}, fmt.Errorf("error rate must be between 0 and 1, got %q", rate)
+			}
+			continue
+		}
+		delays, err := ParseDelays(effect)
+		if err != nil {
+			return Rule{}, err
+		}
+		for service, d := range delays {
+			rule.delays[service] = d
+		}
+	}
+	return rule, nil
+}
+
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	switch s {
+	case "daily", "*":
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	case "weekdays":
+		s = "mon-fri"
+	case "weekends":
+		s = "sat,sun"
+	}
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+		first, ok := dayNames[strings.ToLower(from)]
+		last, ok2 := dayNames[strings.ToLower(to)]
+		if !ok || !ok2 {
+			return days, fmt.Errorf("invalid days %q", part)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// effects is the chaos of the rules active at the moment.
+type effects struct {
+	delays    map[string]Delay
+	errorRate float64
+}
+
+// scheduled is read by every request the chaos applies to, and replaced
+// by the calendar when rules start or end.
+var scheduled atomic.Pointer[effects]
+
+// Calendar applies its rules while the simulated time is in their window,
+// on top of the chaos set with flags: a rule's delay for a service replaces
+// the delay of --inject-delay, and the higher of the error rates applies.
+type Calendar struct {
+	clock  *Clock
+	rules  []Rule
+	logger log.Factory
+
+	lock   sync.Mutex
+	active []bool
+}
+
+// NewCalendar creates a Calendar of rules following clock.
+func NewCalendar(rules []Rule, clock *Clock, logger log.Factory) *Calendar {
+	return &Calendar{clock: clock, rules: rules, logger: logger, active: make([]bool, len(rules))}
+}
+
+// Run checks every interval which rules are active, until ctx is done.
+// Rules that start are logged and recorded on the incident timeline, so
+// the traces they affect can be related to them.
+func (c *Calendar) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.update()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Calendar) update() {
+	now := c.clock.Now()
+	current := &effects{delays: make(map[string]Delay)}
+	changed := false
+
+	c.lock.Lock()
+	for i, rule := range c.rules {
+		active := rule.activeAt(now)
+		if active != c.active[i] {
+			changed = true
+			c.active[i] = active
+			c.announce(rule, active, now)
+		}
+		if !active {
+			continue
+		}
+		for service, d := range rule.delays {
+			current.delays[service] = d
+		}
+		if rule.errorRate > current.errorRate {
+			current.errorRate = rule.errorRate
+		}
+	}
+	c.lock.Unlock()
+
+	if changed {
+		scheduled.Store(current)
+	}
+}
+
+func (c *Calendar) announce(rule Rule, active bool, now time.Time) {
+	simulated := now.Format("Mon 15:04")
+	if !active {
+		c.logger.Bg().Info("Chaos rule ended", zap.String("rule", rule.text), zap.String("simulated_time", simulated))
+		return
+	}
+	c.logger.Bg().Info("Chaos rule started", zap.String("rule", rule.text), zap.String("simulated_time", simulated))
+	incidents.Record(incidents.ChaosActivated, "chaos calendar at "+simulated+": "+rule.text)
+}
+
+// MarshalJSON renders the simulated time and the rules with their state.
+func (c *Calendar) MarshalJSON() ([]byte, error) {
+	type rule struct {
+		Rule   string
+		Active bool
+	}
+	view := struct {
+		Now   time.Time
+		Speed float64
+		Rules []rule
+	}{Now: c.clock.Now(), Speed: c.clock.Speed, Rules: []rule{}}
+	c.lock.Lock()
+	for i, r := range c.rules {
+		view.Rules = append(view.Rules, rule{Rule: r.text, Active: c.active[i]})
+	}
+	c.lock.Unlock()
+	return json.Marshal(view)
+}
+
+// ServeHTTP renders the calendar as JSON.
+func (c *Calendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
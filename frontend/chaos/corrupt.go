@@ -0,0 +1,86 @@
+package chaos
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/superliuwr/jaeger-demo/frontend/incidents"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// corruptResponseRate holds the bits of the fraction (0..1) of successful
+// downstream responses that get truncated or garbled before the client
+// decodes them. It is read by every call, so it is stored atomically.
+var corruptResponseRate atomic.Uint64
+
+// CorruptResponseRate returns the fraction of responses Transport corrupts.
+func CorruptResponseRate() float64 {
+	return math.Float64frombits(corruptResponseRate.Load())
+}
+
+// SetCorruptResponseRate sets the fraction (0..1) of responses Transport
+// corrupts. It is safe to call while calls are in flight.
+func SetCorruptResponseRate(rate float64) {
+	corruptResponseRate.Store(math.Float64bits(rate))
+}
+
+// Transport wraps next so that responses are corrupted at
+// CorruptResponseRate(). The traces of corrupted responses are related to
+// the latest chaos activation on the incident timeline. It is meant to be
+// used as the RoundTripper of a nethttp.Transport, so that corruptions are
+// logged on the client span.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return corruptingTransport{next: next}
+}
+
+type corruptingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t corruptingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	// #nosec
+	if err != nil || res.StatusCode >= 400 || rand.Float64() >= CorruptResponseRate() {
+		return res, err
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	mode, body := corrupt(body)
+	if tracer := nethttp.TracerFromRequest(req); tracer != nil && tracer.Span() != nil {
+		tracer.Span().LogFields(
+			log.String("event", "chaos: corrupted response"),
+			log.String("mode", mode))
+	}
+	incidents.Relate(incidents.ChaosActivated, tracing.TraceID(req.Context()))
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Del("Content-Length")
+
+	return res, nil
+}
+
+// corrupt either cuts the payload in half or garbles its key/value separators.
+func corrupt(body []byte) (string, []byte) {
+	// #nosec
+	if rand.Intn(2) == 0 {
+		return "truncated", body[:len(body)/2]
+	}
+	return "garbled", bytes.Replace(body, []byte(":"), []byte("="), -1)
+}
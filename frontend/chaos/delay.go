@@ -0,0 +1,155 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+)
+
+// Delay is an artificial latency: Base plus a random jitter of up to
+// Jitter either way, never less than zero.
+type Delay struct {
+	Base   time.Duration
+	Jitter time.Duration
+}
+
+// String formats the delay as ParseDelays reads it.
+func (d Delay) String() string {
+	if d.Jitter == 0 {
+		return d.Base.String()
+	}
+	return d.Base.String() + "±" + d.Jitter.String()
+}
+
+func (d Delay) sample() time.Duration {
+	delay := d.Base
+	if d.Jitter > 0 {
+		// #nosec
+		delay += time.Duration(rand.Int63n(int64(2*d.Jitter)+1)) - d.Jitter
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Delays are the artificial latencies added to the calls to each
+// downstream service, by service name.
+var Delays map[string]Delay
+
+// ParseDelays parses "service=delay,..." as given to --inject-delay, e.g.
+// "route=300ms±100ms,driver=50ms". The jitter may also follow "+-".
+func ParseDelays(s string) (map[string]Delay, error) {
+	delays := make(map[string]Delay)
+	if s == "" {
+		return delays, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		service, value, ok := strings.Cut(pair, "=")
+		service = strings.TrimSpace(service)
+		if !ok || service == "" {
+			return nil, fmt.Errorf("invalid delay %q, want service=delay", pair)
+		}
+		base, jitter, hasJitter := strings.Cut(value, "±")
+		if !hasJitter {
+			base, jitter, hasJitter = strings.Cut(value, "+-")
+		}
+		var d Delay
+		var err error
+		if d.Base, err = time.ParseDuration(strings.TrimSpace(base)); err != nil || d.Base < 0 {
+			return nil, fmt.Errorf("invalid delay %q, want service=delay", pair)
+		}
+		if hasJitter {
+			if d.Jitter, err = time.ParseDuration(strings.TrimSpace(jitter)); err != nil || d.Jitter < 0 {
+				return nil, fmt.Errorf("invalid jitter in delay %q", pair)
+			}
+		}
+		delays[service] = d
+	}
+	return delays, nil
+}
+
+// FormatDelays formats delays as ParseDelays reads them, sorted by service.
+func FormatDelays(delays map[string]Delay) string {
+	pairs := make([]string, 0, len(delays))
+	for service, d := range delays {
+		pairs = append(pairs, service+"="+d.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// wait sleeps for the delay of service, if it has one, and logs it on span.
+// A delay of an active calendar rule takes precedence over Delays. It
+// returns early with the error of ctx if ctx is done first.
+func wait(ctx context.Context, span opentracing.Span, service string) error {
+	d, ok := Delays[service]
+	if e := scheduled.Load(); e != nil {
+		if ruled, found := e.delays[service]; found {
+			d, ok = ruled, true
+		}
+	}
+	if !ok {
+		return nil
+	}
+	delay := d.sample()
+	if span != nil {
+		span.SetTag("chaos.delay", delay.String())
+		span.LogFields(log.String("event", "chaos: injected delay"), log.String("delay", delay.String()))
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DelayTransport wraps next so that requests to service are held back by
+// its delay in Delays. Like Transport, it is meant to be used as the
+// RoundTripper of a nethttp.Transport, so the delay shows on the client span.
+func DelayTransport(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var span opentracing.Span
+		if tracer := nethttp.TracerFromRequest(req); tracer != nil {
+			span = tracer.Span()
+		}
+		if err := wait(req.Context(), span, service); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// UnaryClientDelay holds back gRPC calls to service by its delay in
+// Delays. It must come after tracing.UnaryClientInterceptor, so the delay
+// shows on the client span.
+func UnaryClientDelay(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := wait(ctx, opentracing.SpanFromContext(ctx), service); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
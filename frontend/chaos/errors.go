@@ -0,0 +1,45 @@
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/superliuwr/jaeger-demo/frontend/incidents"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// ErrorRate is the fraction (0..1) of requests that Errors fails. Active
+// calendar rules may raise it.
+var ErrorRate float64
+
+// errInjected is the error of the requests Errors fails.
+var errInjected = errors.New("chaos: injected error")
+
+// Errors fails requests at ErrorRate with 500 Internal Server Error before
+// they reach next. The request span is tagged as an error, and its trace
+// is related to the latest chaos activation on the incident timeline, so
+// Errors must run inside the tracing middleware.
+func Errors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rate := ErrorRate
+		if e := scheduled.Load(); e != nil && e.errorRate > rate {
+			rate = e.errorRate
+		}
+		// #nosec
+		if rate <= 0 || rand.Float64() >= rate {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "chaos: injected error"), log.Error(errInjected))
+		}
+		incidents.Relate(incidents.ChaosActivated, tracing.TraceID(r.Context()))
+		http.Error(w, errInjected.Error(), http.StatusInternalServerError)
+	})
+}
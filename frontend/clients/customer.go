@@ -5,11 +5,18 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"go.uber.org/zap"
 
+	"github.com/superliuwr/jaeger-demo/frontend/breaker"
+	"github.com/superliuwr/jaeger-demo/frontend/chaos"
+	"github.com/superliuwr/jaeger-demo/frontend/fieldcrypt"
 	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/mtls"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/schema"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
 	"github.com/superliuwr/jaeger-demo/frontend/tracing"
 )
 
@@ -25,18 +32,33 @@ type CustomerClient struct {
 	logger   log.Factory
 	client   *tracing.HTTPClient
 	hostPort string
+	cipher   *fieldcrypt.Cipher
+	breaker  *breaker.Breaker
 }
 
-// NewCustomerClient creates a new customer.Client
-func NewCustomerClient(tracer opentracing.Tracer, logger log.Factory, hostPort string) *CustomerClient {
+// NewCustomerClient creates a new customer.Client. If encryptionKey is not empty,
+// the sensitive fields returned by the customer service are decrypted with it.
+func NewCustomerClient(tracer opentracing.Tracer, logger log.Factory, hostPort string, encryptionKey string) *CustomerClient {
+	var cipher *fieldcrypt.Cipher
+	if encryptionKey != "" {
+		var err error
+		if cipher, err = fieldcrypt.New(encryptionKey); err != nil {
+			logger.Bg().Fatal("Cannot create field cipher", zap.Error(err))
+		}
+	}
+
 	return &CustomerClient{
 		tracer: tracer,
 		logger: logger,
 		client: &tracing.HTTPClient{
-			Client: &http.Client{Transport: &nethttp.Transport{}},
-			Tracer: tracer,
+			Client:    &http.Client{Transport: tracing.Transport(tracer, red.Transport("customer", timeouts.ObservedTransport("customer", chaos.DelayTransport("customer", chaos.Transport(mtls.Transport(timeouts.Transport("customer")))))))},
+			Tracer:    tracer,
+			Retry:     tracing.DefaultRetryPolicy,
+			Validator: schema.For("customer", logger),
 		},
 		hostPort: hostPort,
+		cipher:   cipher,
+		breaker:  breaker.New("customer", logger),
 	}
 }
 
@@ -44,13 +66,42 @@ func NewCustomerClient(tracer opentracing.Tracer, logger log.Factory, hostPort s
 func (c *CustomerClient) GetCustomer(ctx context.Context, customerID string) (*Customer, error) {
 	c.logger.For(ctx).Info("Getting customer", zap.String("customer_id", customerID))
 
-	url := fmt.Sprintf("http://"+c.hostPort+"/customer?customer=%s", customerID)
-	fmt.Println(url)
+	url := fmt.Sprintf(mtls.Scheme()+"://"+c.hostPort+"/customer?customer=%s", customerID)
+	c.logger.For(ctx).Debug("Calling customer service", zap.String("url", url))
+
+	ctx, cancel := timeouts.WithRequestTimeout(ctx, "customer")
+	defer cancel()
 
 	var customer Customer
-	if err := c.client.GetJSON(ctx, "/customer", url, &customer); err != nil {
+	err := c.breaker.Do(ctx, func() error {
+		return c.client.GetJSON(ctx, "/customer", url, &customer)
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	if c.cipher != nil {
+		if err := c.decryptFields(ctx, &customer); err != nil {
+			c.logger.For(ctx).Error("Cannot decrypt customer fields", zap.Error(err))
+			return nil, err
+		}
+	}
+
 	return &customer, nil
 }
+
+// decryptFields decrypts the fields the customer service encrypts in transit.
+func (c *CustomerClient) decryptFields(ctx context.Context, customer *Customer) error {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, c.tracer, "decrypt-fields")
+	defer span.Finish()
+	span.SetTag("fields", "location")
+
+	location, err := c.cipher.Decrypt(customer.Location)
+	if err != nil {
+		ext.Error.Set(span, true)
+		return err
+	}
+	customer.Location = location
+
+	return nil
+}
@@ -2,14 +2,18 @@ package clients
 
 import (
 	"context"
-	"time"
 
 	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 
+	"github.com/superliuwr/jaeger-demo/frontend/breaker"
+	"github.com/superliuwr/jaeger-demo/frontend/chaos"
 	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
 )
 
 // Driver describes a driver and the current car location.
@@ -19,42 +23,59 @@ type Driver struct {
 }
 
 type DriverClient struct {
-	tracer opentracing.Tracer
-	logger log.Factory
-	client DriverServiceClient
+	tracer  opentracing.Tracer
+	logger  log.Factory
+	client  DriverServiceClient
+	breaker *breaker.Breaker
 }
 
-// NewDriverClient creates a new driver.Client
-func NewDriverClient(tracer opentracing.Tracer, logger log.Factory, hostPort string) *DriverClient {
-	conn, err := grpc.Dial(hostPort, grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(
-			otgrpc.OpenTracingClientInterceptor(tracer)),
+// NewDriverClient creates a new driver.Client whose connection is tuned by config.
+func NewDriverClient(tracer opentracing.Tracer, logger log.Factory, hostPort string, config GRPCConfig) *DriverClient {
+	opts := append([]grpc.DialOption{grpc.WithInsecure(),
+		grpc.WithContextDialer(timeouts.Dialer("driver")),
+		grpc.WithChainUnaryInterceptor(
+			tracing.UnaryClientInterceptor(tracer),
+			unaryClientChannelState(),
+			red.UnaryClientInterceptor("driver"),
+			timeouts.UnaryClientLatency("driver"),
+			chaos.UnaryClientDelay("driver"),
+			tracing.UnaryClientSizes()),
 		grpc.WithStreamInterceptor(
-			otgrpc.OpenTracingStreamClientInterceptor(tracer)))
+			otgrpc.OpenTracingStreamClientInterceptor(tracer))},
+		config.DialOptions()...)
+	conn, err := grpc.Dial(hostPort, opts...)
 	if err != nil {
 		logger.Bg().Fatal("Cannot create gRPC connection", zap.Error(err))
 	}
+	go watchChannel("driver", conn, logger)
 
 	client := NewDriverServiceClient(conn)
 
 	return &DriverClient{
-		tracer: tracer,
-		logger: logger,
-		client: client,
+		tracer:  tracer,
+		logger:  logger,
+		client:  client,
+		breaker: breaker.New("driver", logger),
 	}
 }
 
 // FindNearest implements driver.Interface#FindNearest as an RPC
 func (c *DriverClient) FindNearest(ctx context.Context, location string) ([]Driver, error) {
 	c.logger.For(ctx).Info("Finding nearest drivers", zap.String("location", location))
-	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	ctx, cancel := timeouts.WithRequestTimeout(ctx, "driver")
 	defer cancel()
 
-	response, err := c.client.FindNearest(ctx, &DriverLocationRequest{Location: location})
+	var response *DriverLocationResponse
+	err := c.breaker.Do(ctx, func() (err error) {
+		response, err = c.client.FindNearest(ctx, &DriverLocationRequest{Location: location})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	c.logger.For(ctx).Debug("Driver service responded", zap.Int("num_drivers", len(response.Locations)))
+
 	return fromProto(response), nil
 }
 
@@ -68,4 +89,4 @@ func fromProto(response *DriverLocationResponse) []Driver {
 	}
 
 	return retMe
-}
\ No newline at end of file
+}
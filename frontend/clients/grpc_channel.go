@@ -0,0 +1,86 @@
+package clients
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+var channelTransitions = expvar.NewMap("grpc_channel_transitions")
+
+// GRPCConfig tunes the lifecycle of a gRPC connection. Zero fields keep
+// gRPC's defaults.
+type GRPCConfig struct {
+	// KeepaliveTime is how long the connection may be idle before the
+	// client pings the server, and KeepaliveTimeout how long it waits for
+	// the ping to be answered before it closes the connection.
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	// BackoffBaseDelay and BackoffMaxDelay bound the wait between
+	// reconnection attempts, which grows exponentially in between.
+	BackoffBaseDelay time.Duration
+	BackoffMaxDelay  time.Duration
+}
+
+// DialOptions returns the dial options that apply the config.
+func (c GRPCConfig) DialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if c.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    c.KeepaliveTime,
+			Timeout: c.KeepaliveTimeout,
+		}))
+	}
+	if c.BackoffBaseDelay > 0 || c.BackoffMaxDelay > 0 {
+		config := backoff.DefaultConfig
+		if c.BackoffBaseDelay > 0 {
+			config.BaseDelay = c.BackoffBaseDelay
+		}
+		if c.BackoffMaxDelay > 0 {
+			config.MaxDelay = c.BackoffMaxDelay
+		}
+		opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{Backoff: config}))
+	}
+	return opts
+}
+
+// unaryClientChannelState annotates the span of calls made while the
+// channel is not ready, e.g. because the connection was lost and is being
+// re-established, with the state of the channel. Such calls wait for the
+// connection, or fail if it cannot be made. It must come after
+// tracing.UnaryClientInterceptor, so the client span is in the context.
+func unaryClientChannelState() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if state := cc.GetState(); state != connectivity.Ready {
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				span.SetTag("grpc.channel.state", state.String())
+				span.LogFields(otlog.String("event", "channel not ready"), otlog.String("state", state.String()))
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// watchChannel logs every state change of the channel to the named
+// service and counts it in the grpc_channel_transitions expvar, until the
+// channel is closed.
+func watchChannel(service string, conn *grpc.ClientConn, logger log.Factory) {
+	state := conn.GetState()
+	for state != connectivity.Shutdown && conn.WaitForStateChange(context.Background(), state) {
+		previous := state
+		state = conn.GetState()
+		channelTransitions.Add(service+":"+state.String(), 1)
+		logger.Bg().Info("gRPC channel state changed",
+			zap.String("target", service), zap.Stringer("from", previous), zap.Stringer("to", state))
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"time"
 
-	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 
+	"github.com/superliuwr/jaeger-demo/frontend/breaker"
+	"github.com/superliuwr/jaeger-demo/frontend/chaos"
 	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/mtls"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/schema"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
 	"github.com/superliuwr/jaeger-demo/frontend/tracing"
 )
 
@@ -25,18 +31,25 @@ type RouteClient struct {
 	logger   log.Factory
 	client   *tracing.HTTPClient
 	hostPort string
+	mock     bool
+	breaker  *breaker.Breaker
 }
 
-// NewRouteClient creates a new route.Client
-func NewRouteClient(tracer opentracing.Tracer, logger log.Factory, hostPort string) *RouteClient {
+// NewRouteClient creates a new route.Client. If mock is true, FindRoute
+// returns a canned route instead of calling the route service.
+func NewRouteClient(tracer opentracing.Tracer, logger log.Factory, hostPort string, mock bool) *RouteClient {
 	return &RouteClient{
 		tracer: tracer,
 		logger: logger,
 		client: &tracing.HTTPClient{
-			Client: &http.Client{Transport: &nethttp.Transport{}},
-			Tracer: tracer,
+			Client:    &http.Client{Transport: tracing.Transport(tracer, red.Transport("route", timeouts.ObservedTransport("route", chaos.DelayTransport("route", chaos.Transport(mtls.Transport(timeouts.Transport("route")))))))},
+			Tracer:    tracer,
+			Retry:     tracing.DefaultRetryPolicy,
+			Validator: schema.For("route", logger),
 		},
 		hostPort: hostPort,
+		mock:     mock,
+		breaker:  breaker.New("route", logger),
 	}
 }
 
@@ -44,14 +57,26 @@ func NewRouteClient(tracer opentracing.Tracer, logger log.Factory, hostPort stri
 func (c *RouteClient) FindRoute(ctx context.Context, pickup, dropoff string) (*Route, error) {
 	c.logger.For(ctx).Info("Finding route", zap.String("pickup", pickup), zap.String("dropoff", dropoff))
 
+	if c.mock {
+		return &Route{Pickup: "Sydney", Dropoff: "Brisbane", ETA: int(10 * time.Minute)}, nil
+	}
+
 	v := url.Values{}
 	v.Set("pickup", pickup)
 	v.Set("dropoff", dropoff)
-	url := "http://" + c.hostPort + "/route?" + v.Encode()
+	url := mtls.Scheme() + "://" + c.hostPort + "/route?" + v.Encode()
+
+	c.logger.For(ctx).Debug("Calling route service", zap.String("url", url))
+
+	ctx, cancel := timeouts.WithRequestTimeout(ctx, "route")
+	defer cancel()
 
 	var route Route
 
-	if err := c.client.GetJSON(ctx, "/route", url, &route); err != nil {
+	err := c.breaker.Do(ctx, func() error {
+		return c.client.GetJSON(ctx, "/route", url, &route)
+	})
+	if err != nil {
 		c.logger.For(ctx).Error("Error getting route", zap.Error(err))
 
 		return nil, err
@@ -0,0 +1,227 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: pkg/clients/route.proto
+
+package clients
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type RouteRequest struct {
+	Pickup               string   `protobuf:"bytes,1,opt,name=pickup,proto3" json:"pickup,omitempty"`
+	Dropoff              string   `protobuf:"bytes,2,opt,name=dropoff,proto3" json:"dropoff,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RouteRequest) Reset()         { *m = RouteRequest{} }
+func (m *RouteRequest) String() string { return proto.CompactTextString(m) }
+func (*RouteRequest) ProtoMessage()    {}
+func (*RouteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d50dacc16228090, []int{0}
+}
+func (m *RouteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RouteRequest.Unmarshal(m, b)
+}
+func (m *RouteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RouteRequest.Marshal(b, m, deterministic)
+}
+func (m *RouteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RouteRequest.Merge(m, src)
+}
+func (m *RouteRequest) XXX_Size() int {
+	return xxx_messageInfo_RouteRequest.Size(m)
+}
+func (m *RouteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RouteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RouteRequest proto.InternalMessageInfo
+
+func (m *RouteRequest) GetPickup() string {
+	if m != nil {
+		return m.Pickup
+	}
+	return ""
+}
+
+func (m *RouteRequest) GetDropoff() string {
+	if m != nil {
+		return m.Dropoff
+	}
+	return ""
+}
+
+type RouteResponse struct {
+	Pickup               string   `protobuf:"bytes,1,opt,name=pickup,proto3" json:"pickup,omitempty"`
+	Dropoff              string   `protobuf:"bytes,2,opt,name=dropoff,proto3" json:"dropoff,omitempty"`
+	Eta                  int64    `protobuf:"varint,3,opt,name=eta,proto3" json:"eta,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RouteResponse) Reset()         { *m = RouteResponse{} }
+func (m *RouteResponse) String() string { return proto.CompactTextString(m) }
+func (*RouteResponse) ProtoMessage()    {}
+func (*RouteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d50dacc16228090, []int{1}
+}
+func (m *RouteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RouteResponse.Unmarshal(m, b)
+}
+func (m *RouteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RouteResponse.Marshal(b, m, deterministic)
+}
+func (m *RouteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RouteResponse.Merge(m, src)
+}
+func (m *RouteResponse) XXX_Size() int {
+	return xxx_messageInfo_RouteResponse.Size(m)
+}
+func (m *RouteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RouteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RouteResponse proto.InternalMessageInfo
+
+func (m *RouteResponse) GetPickup() string {
+	if m != nil {
+		return m.Pickup
+	}
+	return ""
+}
+
+func (m *RouteResponse) GetDropoff() string {
+	if m != nil {
+		return m.Dropoff
+	}
+	return ""
+}
+
+func (m *RouteResponse) GetEta() int64 {
+	if m != nil {
+		return m.Eta
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*RouteRequest)(nil), "route.RouteRequest")
+	proto.RegisterType((*RouteResponse)(nil), "route.RouteResponse")
+}
+
+func init() { proto.RegisterFile("pkg/clients/route.proto", fileDescriptor_2d50dacc16228090) }
+
+var fileDescriptor_2d50dacc16228090 = []byte{
+	// 175 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2f, 0xc8, 0x4e, 0xd7,
+	0x4f, 0xce, 0xc9, 0x4c, 0xcd, 0x2b, 0x29, 0xd6, 0x2f, 0xca, 0x2f, 0x2d, 0x49, 0xd5, 0x2b, 0x28,
+	0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x73, 0x94, 0x1c, 0xb8, 0x78, 0x82, 0x40, 0x8c, 0xa0, 0xd4,
+	0xc2, 0xd2, 0xd4, 0xe2, 0x12, 0x21, 0x31, 0x2e, 0xb6, 0x82, 0xcc, 0xe4, 0xec, 0xd2, 0x02, 0x09,
+	0x46, 0x05, 0x46, 0x0d, 0xce, 0x20, 0x28, 0x4f, 0x48, 0x82, 0x8b, 0x3d, 0xa5, 0x28, 0xbf, 0x20,
+	0x3f, 0x2d, 0x4d, 0x82, 0x09, 0x2c, 0x01, 0xe3, 0x2a, 0x05, 0x73, 0xf1, 0x42, 0x4d, 0x28, 0x2e,
+	0xc8, 0xcf, 0x2b, 0x4e, 0x25, 0xdd, 0x08, 0x21, 0x01, 0x2e, 0xe6, 0xd4, 0x92, 0x44, 0x09, 0x66,
+	0x05, 0x46, 0x0d, 0xe6, 0x20, 0x10, 0xd3, 0xc8, 0x0d, 0xea, 0xac, 0xe0, 0xd4, 0xa2, 0xb2, 0xcc,
+	0xe4, 0x54, 0x21, 0x33, 0x2e, 0x4e, 0xb7, 0xcc, 0xbc, 0x14, 0xb0, 0x98, 0x90, 0xb0, 0x1e, 0xc4,
+	0x23, 0xc8, 0x0e, 0x97, 0x12, 0x41, 0x15, 0x84, 0xb8, 0xc5, 0x89, 0x3d, 0x0a, 0xe2, 0xcf, 0x24,
+	0x36, 0xb0, 0xaf, 0x8d, 0x01, 0x03, 0x00, 0x6e, 0x9a, 0x7a, 0xeb, 0x10, 0x01, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// RouteServiceClient is the client API for RouteService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RouteServiceClient interface {
+	FindRoute(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (*RouteResponse, error)
+}
+
+type routeServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRouteServiceClient(cc *grpc.ClientConn) RouteServiceClient {
+	return &routeServiceClient{cc}
+}
+
+func (c *routeServiceClient) FindRoute(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (*RouteResponse, error) {
+	out := new(RouteResponse)
+	err := c.cc.Invoke(ctx, "/route.RouteService/FindRoute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RouteServiceServer is the server API for RouteService service.
+type RouteServiceServer interface {
+	FindRoute(context.Context, *RouteRequest) (*RouteResponse, error)
+}
+
+// UnimplementedRouteServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedRouteServiceServer struct {
+}
+
+func (*UnimplementedRouteServiceServer) FindRoute(ctx context.Context, req *RouteRequest) (*RouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindRoute not implemented")
+}
+
+func RegisterRouteServiceServer(s *grpc.Server, srv RouteServiceServer) {
+	s.RegisterService(&_RouteService_serviceDesc, srv)
+}
+
+func _RouteService_FindRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouteServiceServer).FindRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/route.RouteService/FindRoute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouteServiceServer).FindRoute(ctx, req.(*RouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _RouteService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "route.RouteService",
+	HandlerType: (*RouteServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "FindRoute",
+			Handler:    _RouteService_FindRoute_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/clients/route.proto",
+}
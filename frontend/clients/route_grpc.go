@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"context"
+
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/superliuwr/jaeger-demo/frontend/breaker"
+	"github.com/superliuwr/jaeger-demo/frontend/chaos"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+type RouteGRPCClient struct {
+	tracer  opentracing.Tracer
+	logger  log.Factory
+	client  RouteServiceClient
+	breaker *breaker.Breaker
+}
+
+// NewRouteGRPCClient creates a new route.Client that talks gRPC to the route service
+func NewRouteGRPCClient(tracer opentracing.Tracer, logger log.Factory, hostPort string) *RouteGRPCClient {
+	conn, err := grpc.Dial(hostPort, grpc.WithInsecure(),
+		grpc.WithContextDialer(timeouts.Dialer("route")),
+		grpc.WithChainUnaryInterceptor(
+			tracing.UnaryClientInterceptor(tracer),
+			red.UnaryClientInterceptor("route"),
+			timeouts.UnaryClientLatency("route"),
+			chaos.UnaryClientDelay("route"),
+			tracing.UnaryClientSizes()),
+		grpc.WithStreamInterceptor(
+			otgrpc.OpenTracingStreamClientInterceptor(tracer)))
+	if err != nil {
+		logger.Bg().Fatal("Cannot create gRPC connection", zap.Error(err))
+	}
+
+	return &RouteGRPCClient{
+		tracer:  tracer,
+		logger:  logger,
+		client:  NewRouteServiceClient(conn),
+		breaker: breaker.New("route", logger),
+	}
+}
+
+// FindRoute implements route.Interface#FindRoute as a gRPC call
+func (c *RouteGRPCClient) FindRoute(ctx context.Context, pickup, dropoff string) (*Route, error) {
+	c.logger.For(ctx).Info("Finding route", zap.String("pickup", pickup), zap.String("dropoff", dropoff))
+	ctx, cancel := timeouts.WithRequestTimeout(ctx, "route")
+	defer cancel()
+
+	var response *RouteResponse
+	err := c.breaker.Do(ctx, func() (err error) {
+		response, err = c.client.FindRoute(ctx, &RouteRequest{Pickup: pickup, Dropoff: dropoff})
+		return err
+	})
+	if err != nil {
+		c.logger.For(ctx).Error("Error getting route", zap.Error(err))
+
+		return nil, err
+	}
+
+	return &Route{
+		Pickup:  response.Pickup,
+		Dropoff: response.Dropoff,
+		ETA:     int(response.Eta),
+	}, nil
+}
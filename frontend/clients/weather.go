@@ -0,0 +1,224 @@
+package clients
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/breaker"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// Modes of the weather client.
+const (
+	// WeatherOff skips the weather: ETAs are not adjusted.
+	WeatherOff = "off"
+	// WeatherStub uses the stub's weather, without network access.
+	WeatherStub = "stub"
+	// WeatherLive calls the weather API, and falls back to the stub when
+	// it fails.
+	WeatherLive = "live"
+)
+
+// DefaultWeatherURL is Open-Meteo's forecast API, which needs no API key.
+const DefaultWeatherURL = "https://api.open-meteo.com/v1/forecast"
+
+var weatherFallbacks = expvar.NewMap("weather_fallbacks")
+
+// WeatherConfig configures the weather client.
+type WeatherConfig struct {
+	// Mode is WeatherOff, WeatherStub or WeatherLive.
+	Mode string
+	// URL is the forecast endpoint of an Open-Meteo compatible API.
+	URL string
+	// Latitude and Longitude locate the city the cars drive in.
+	Latitude  float64
+	Longitude float64
+	// Stub is the condition the stub reports, one of WeatherConditions.
+	Stub string
+	// CacheTTL is how long a reading of the API is used for, so that
+	// dispatches don't each call it.
+	CacheTTL time.Duration
+}
+
+// Weather is the weather the ETA of a dispatch was adjusted for.
+type Weather struct {
+	Condition string
+	// Factor multiplies the ETA: cars drive slower in rain or snow.
+	Factor float64
+	// Source is "live", "cache", "stub", or "fallback" if the API failed
+	// and the stub stood in for it.
+	Source string
+}
+
+// WeatherConditions are the conditions the weather client reports, and
+// their factor of the ETA.
+var WeatherConditions = map[string]float64{
+	"clear":        1,
+	"cloudy":       1,
+	"fog":          1.15,
+	"drizzle":      1.1,
+	"rain":         1.25,
+	"snow":         1.5,
+	"thunderstorm": 1.4,
+}
+
+// ParseWeatherLocation parses "latitude,longitude".
+func ParseWeatherLocation(s string) (float64, float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 2 {
+		latitude, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		longitude, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err1 == nil && err2 == nil && latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180 {
+			return latitude, longitude, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid weather location %q, want latitude,longitude", s)
+}
+
+// WeatherClient gets the current weather from a third-party API. Unlike the
+// other clients, its failures don't fail dispatches: the stub's weather is
+// used instead, and the span of the call is tagged weather.fallback.
+type WeatherClient struct {
+	tracer  opentracing.Tracer
+	logger  log.Factory
+	client  *tracing.HTTPClient
+	config  WeatherConfig
+	breaker *breaker.Breaker
+
+	lock    sync.Mutex
+	cached  *Weather
+	expires time.Time
+}
+
+// NewWeatherClient creates a new WeatherClient, or returns nil if config's
+// Mode is WeatherOff.
+func NewWeatherClient(tracer opentracing.Tracer, logger log.Factory, config WeatherConfig) *WeatherClient {
+	if config.Mode == WeatherOff || config.Mode == "" {
+		return nil
+	}
+	return &WeatherClient{
+		tracer: tracer,
+		logger: logger,
+		client: &tracing.HTTPClient{
+			// nethttp rather than tracing.Transport, whose otelhttp transport
+			// would send the trace context to the third party.
+			Client:   &http.Client{Transport: &nethttp.Transport{RoundTripper: red.Transport("weather", timeouts.ObservedTransport("weather", timeouts.Transport("weather")))}},
+			Tracer:   tracer,
+			External: true,
+		},
+		config:  config,
+		breaker: breaker.New("weather", logger),
+	}
+}
+
+// Current returns the current weather. It never fails: if the API cannot
+// be reached, the stub's weather is returned with Source "fallback".
+func (c *WeatherClient) Current(ctx context.Context) *Weather {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, c.tracer, "weather")
+	defer span.Finish()
+	span.SetTag("weather.mode", c.config.Mode)
+
+	weather := c.current(ctx, span)
+	span.SetTag("weather.condition", weather.Condition)
+	span.SetTag("weather.source", weather.Source)
+	span.SetTag("weather.factor", weather.Factor)
+	return weather
+}
+
+func (c *WeatherClient) current(ctx context.Context, span opentracing.Span) *Weather {
+	if c.config.Mode == WeatherStub {
+		return c.stub("stub")
+	}
+
+	c.lock.Lock()
+	if c.cached != nil && time.Now().Before(c.expires) {
+		weather := *c.cached
+		c.lock.Unlock()
+		weather.Source = "cache"
+		return &weather
+	}
+	c.lock.Unlock()
+
+	weather, err := c.fetch(ctx)
+	if err != nil {
+		weatherFallbacks.Add("error", 1)
+		span.SetTag("weather.fallback", true)
+		span.LogFields(otlog.String("event", "weather API failed, using the stub"), otlog.Error(err))
+		c.logger.For(ctx).Error("Cannot get the weather, using the stub", zap.Error(err))
+		return c.stub("fallback")
+	}
+	c.lock.Lock()
+	c.cached, c.expires = weather, time.Now().Add(c.config.CacheTTL)
+	c.lock.Unlock()
+	return weather
+}
+
+// fetch calls the weather API.
+func (c *WeatherClient) fetch(ctx context.Context) (*Weather, error) {
+	v := url.Values{}
+	v.Set("latitude", strconv.FormatFloat(c.config.Latitude, 'f', -1, 64))
+	v.Set("longitude", strconv.FormatFloat(c.config.Longitude, 'f', -1, 64))
+	v.Set("current_weather", "true")
+
+	ctx, cancel := timeouts.WithRequestTimeout(ctx, "weather")
+	defer cancel()
+
+	var forecast struct {
+		CurrentWeather *struct {
+			WeatherCode int `json:"weathercode"`
+		} `json:"current_weather"`
+	}
+	err := c.breaker.Do(ctx, func() error {
+		return c.client.GetJSON(ctx, "/forecast", c.config.URL+"?"+v.Encode(), &forecast)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if forecast.CurrentWeather == nil {
+		return nil, fmt.Errorf("no current_weather in the response")
+	}
+	condition := wmoCondition(forecast.CurrentWeather.WeatherCode)
+	return &Weather{Condition: condition, Factor: WeatherConditions[condition], Source: "live"}, nil
+}
+
+// stub returns the weather the stub reports.
+func (c *WeatherClient) stub(source string) *Weather {
+	return &Weather{Condition: c.config.Stub, Factor: WeatherConditions[c.config.Stub], Source: source}
+}
+
+// wmoCondition maps a WMO weather interpretation code, which Open-Meteo
+// reports, to one of WeatherConditions.
+func wmoCondition(code int) string {
+	switch {
+	case code <= 1:
+		return "clear"
+	case code <= 3:
+		return "cloudy"
+	case code == 45 || code == 48:
+		return "fog"
+	case code >= 51 && code <= 57:
+		return "drizzle"
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return "rain"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "snow"
+	case code >= 95:
+		return "thunderstorm"
+	}
+	return "cloudy"
+}
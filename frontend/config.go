@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/superliuwr/jaeger-demo/frontend/resources"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
+)
+
+// configEnvPrefixes select the env vars that configure the frontend and its tracer.
+var configEnvPrefixes = []string{
+	"ADMIN_", "BAGGAGE_", "CHAOS_", "CUSTOMER_", "FIELD_", "JAEGER_", "JWT_", "OTEL_", "OUTBOX_", "RESPONSE_", "ROUTE_",
+	"RUNTIME_", "SAMPLING_", "SELFTEST_", "SLO_", "SYNTHETIC_", "TIMEOUTS_", "WEATHER_",
+}
+
+// secretWords mark settings whose values are masked.
+var secretWords = []string{"KEY", "SECRET", "PASSWORD", "TOKEN"}
+
+// maskedValue replaces the value of a secret that is set.
+const maskedValue = "****"
+
+// effectiveConfig is the configuration the frontend runs with, after
+// flags, env vars and the timeouts file are applied.
+type effectiveConfig struct {
+	Flags     map[string]string
+	Env       map[string]string
+	Options   ConfigOptions
+	Timeouts  *timeouts.Config
+	Resources resources.Effective
+}
+
+// configHandler renders the effective configuration as JSON, with secrets masked.
+func configHandler(options ConfigOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := effectiveConfig{
+			Flags:     make(map[string]string),
+			Env:       make(map[string]string),
+			Options:   options,
+			Timeouts:  timeouts.Get(),
+			Resources: resources.Current(),
+		}
+		flag.VisitAll(func(f *flag.Flag) {
+			config.Flags[f.Name] = mask(f.Name, f.Value.String())
+		})
+		for _, kv := range os.Environ() {
+			pair := strings.SplitN(kv, "=", 2)
+			if len(pair) == 2 && hasAnyPrefix(pair[0], configEnvPrefixes) {
+				config.Env[pair[0]] = mask(pair[0], pair[1])
+			}
+		}
+		config.Options.FieldEncryptionKey = mask("FieldEncryptionKey", options.FieldEncryptionKey)
+		config.Options.SyntheticToken = mask("SyntheticToken", options.SyntheticToken)
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(config)
+	})
+}
+
+// mask hides the value of a setting whose name looks like a secret. The
+// header lists of the OTLP exporters, like OTEL_EXPORTER_OTLP_HEADERS,
+// often carry credentials, so their names are kept but their values are
+// hidden.
+func mask(name, value string) string {
+	if value == "" {
+		return value
+	}
+	upper := strings.ToUpper(name)
+	if strings.HasPrefix(upper, "OTEL_EXPORTER_OTLP_") && strings.HasSuffix(upper, "HEADERS") {
+		return maskHeaders(value)
+	}
+	for _, word := range secretWords {
+		if strings.Contains(upper, word) {
+			return maskedValue
+		}
+	}
+	return value
+}
+
+// maskHeaders hides the values of a "name=value,..." header list, as the
+// OTLP exporters read it.
+func maskHeaders(list string) string {
+	headers := strings.Split(list, ",")
+	for i, header := range headers {
+		name := strings.SplitN(header, "=", 2)[0]
+		headers[i] = strings.TrimSpace(name) + "=" + maskedValue
+	}
+	return strings.Join(headers, ",")
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
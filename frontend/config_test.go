@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"cors-allowed-headers", "Content-Type,jaeger-baggage", "Content-Type,jaeger-baggage"},
+		{"OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer abc, x-tenant=t1", "Authorization=****,x-tenant=****"},
+		{"OTEL_EXPORTER_OTLP_TRACES_HEADERS", "Authorization=Bearer abc", "Authorization=****"},
+		{"FIELD_ENCRYPTION_KEY", "secret", maskedValue},
+		{"JWT_SECRET", "", ""},
+	}
+	for _, tt := range tests {
+		if got := mask(tt.name, tt.value); got != tt.want {
+			t.Errorf("mask(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
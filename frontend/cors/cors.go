@@ -0,0 +1,137 @@
+package cors
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var denials = expvar.NewMap("cors_denials")
+
+// Policy lets pages of other origins call the frontend from a browser. The
+// zero Policy allows no other origin, and sends no CORS headers at all.
+type Policy struct {
+	// AllowedOrigins are the origins allowed, e.g. https://ui.example.com,
+	// or "*" for any.
+	AllowedOrigins []string
+	// AllowedMethods and AllowedHeaders are what a preflight request may
+	// ask for.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts of other origins
+	// can read.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache the answer to a preflight.
+	MaxAge time.Duration
+}
+
+// ParseList splits a comma-separated list, dropping empty items.
+func ParseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Enabled tells if the policy allows any other origin.
+func (p Policy) Enabled() bool {
+	return len(p.AllowedOrigins) > 0
+}
+
+// Handler answers preflight requests from allowed origins itself, and adds
+// the CORS headers to the responses of next to their other requests.
+// Preflight requests asking for an origin, a method or headers that are not
+// allowed are rejected with 403 Forbidden, and counted by reason in the
+// cors_denials expvar. Requests from origins that are not allowed are
+// still served, without CORS headers, so browsers keep their responses
+// from the page. Handler must wrap the mux, which answers OPTIONS requests
+// itself.
+func (p Policy) Handler(next http.Handler) http.Handler {
+	if !p.Enabled() {
+		return next
+	}
+	allowMethods := strings.Join(p.AllowedMethods, ", ")
+	allowHeaders := strings.Join(p.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(p.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(p.MaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The answer depends on the origin, so caches must not share it.
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		allowOrigin, ok := p.allowOrigin(origin)
+		if !ok {
+			denials.Add("origin", 1)
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		if !preflight {
+			if exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !contains(p.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+			denials.Add("method", 1)
+			http.Error(w, "method not allowed", http.StatusForbidden)
+			return
+		}
+		for _, header := range ParseList(r.Header.Get("Access-Control-Request-Headers")) {
+			if !contains(p.AllowedHeaders, header) {
+				denials.Add("header", 1)
+				http.Error(w, "header "+header+" not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if p.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin to answer origin
+// with, if it is allowed.
+func (p Policy) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// contains tells if list has s, ignoring case.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
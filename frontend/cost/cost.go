@@ -0,0 +1,196 @@
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// capacity is the number of dispatches kept for the summary.
+const capacity = 1000
+
+// maxTraceIDs is the number of example traces kept per group.
+const maxTraceIDs = 5
+
+// TierTag is the span tag with the tier of the customer a request is for.
+// Only traces whose local root span has it are attributed.
+const TierTag = "customer.tier"
+
+// DefaultTier is the tier of customers without one of their own.
+const DefaultTier = "standard"
+
+// Cost is the synthetic cost of a request, estimated from its spans in the
+// frontend.
+type Cost struct {
+	// CPUMillis is the time the frontend's own spans spent outside of
+	// their child spans, as a stand-in for the CPU they used.
+	CPUMillis float64
+	// Bytes is the size of the request and response bodies exchanged with
+	// downstream services.
+	Bytes int64
+	// Calls is the number of downstream calls, retries included.
+	Calls int
+}
+
+func (c *Cost) add(other Cost) {
+	c.CPUMillis += other.CPUMillis
+	c.Bytes += other.Bytes
+	c.Calls += other.Calls
+}
+
+// Dispatch is the cost of one request.
+type Dispatch struct {
+	Time     time.Time
+	TraceID  string
+	Tier     string
+	Endpoint string
+	Cost
+}
+
+// Group sums up the cost of the requests of one customer tier to one
+// endpoint.
+type Group struct {
+	Tier       string
+	Endpoint   string
+	Dispatches int
+	Total      Cost
+	// Mean is the cost of an average request of the group.
+	Mean Cost
+	// TraceIDs are the traces of the most expensive requests by CPU,
+	// costliest first.
+	TraceIDs []string
+}
+
+// Summary is the breakdown of the cost of the recent requests.
+type Summary struct {
+	Since      time.Time
+	Dispatches int
+	Total      Cost
+	Groups     []Group
+}
+
+var (
+	lock       sync.Mutex
+	dispatches []Dispatch
+)
+
+// Record adds the cost of a request.
+func Record(d Dispatch) {
+	lock.Lock()
+	defer lock.Unlock()
+	if len(dispatches) == capacity {
+		dispatches = dispatches[1:]
+	}
+	dispatches = append(dispatches, d)
+}
+
+// Summarize groups the requests of the last window by customer tier and
+// endpoint, the costliest in CPU first. A window of 0 covers all the
+// requests kept.
+func Summarize(window time.Duration) Summary {
+	lock.Lock()
+	recent := make([]Dispatch, 0, len(dispatches))
+	var summary Summary
+	if window > 0 {
+		summary.Since = time.Now().Add(-window)
+	}
+	for i := len(dispatches) - 1; i >= 0 && !dispatches[i].Time.Before(summary.Since); i-- {
+		recent = append(recent, dispatches[i])
+	}
+	lock.Unlock()
+
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].CPUMillis > recent[j].CPUMillis })
+	type key struct{ tier, endpoint string }
+	groups := make(map[key]*Group)
+	for _, d := range recent {
+		summary.Dispatches++
+		summary.Total.add(d.Cost)
+		g, ok := groups[key{d.Tier, d.Endpoint}]
+		if !ok {
+			g = &Group{Tier: d.Tier, Endpoint: d.Endpoint}
+			groups[key{d.Tier, d.Endpoint}] = g
+		}
+		g.Dispatches++
+		g.Total.add(d.Cost)
+		if d.TraceID != "" && len(g.TraceIDs) < maxTraceIDs {
+			g.TraceIDs = append(g.TraceIDs, d.TraceID)
+		}
+	}
+
+	summary.Groups = make([]Group, 0, len(groups))
+	for _, g := range groups {
+		n := float64(g.Dispatches)
+		g.Mean = Cost{
+			CPUMillis: g.Total.CPUMillis / n,
+			Bytes:     int64(float64(g.Total.Bytes)/n + 0.5),
+			Calls:     int(float64(g.Total.Calls)/n + 0.5),
+		}
+		summary.Groups = append(summary.Groups, *g)
+	}
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		a, b := summary.Groups[i], summary.Groups[j]
+		if a.Total.CPUMillis != b.Total.CPUMillis {
+			return a.Total.CPUMillis > b.Total.CPUMillis
+		}
+		return a.Tier+a.Endpoint < b.Tier+b.Endpoint
+	})
+	return summary
+}
+
+// Handler renders the summary as JSON. The window query parameter, e.g.
+// ?window=5m, limits it to the most recent requests.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var window time.Duration
+		if v := r.URL.Query().Get("window"); v != "" {
+			var err error
+			if window, err = time.ParseDuration(v); err != nil || window < 0 {
+				http.Error(w, "invalid window "+v, http.StatusBadRequest)
+				return
+			}
+		}
+		data, err := json.Marshal(Summarize(window))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+// Tiers maps customer IDs to their tier.
+type Tiers map[string]string
+
+// ParseTiers reads tiers formatted as "customer=tier" pairs separated by
+// commas, for example "123=premium,392=premium,731=business".
+func ParseTiers(s string) (Tiers, error) {
+	tiers := make(Tiers)
+	if s == "" {
+		return tiers, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid customer tier %q, want customer=tier", pair)
+		}
+		customer, tier := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		if customer == "" || tier == "" {
+			return nil, fmt.Errorf("invalid customer tier %q, want customer=tier", pair)
+		}
+		tiers[customer] = tier
+	}
+	return tiers, nil
+}
+
+// Of returns the tier of customer, DefaultTier if it has none.
+func (t Tiers) Of(customer string) string {
+	if tier, ok := t[customer]; ok {
+		return tier
+	}
+	return DefaultTier
+}
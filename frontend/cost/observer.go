@@ -0,0 +1,177 @@
+package cost
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+
+	"github.com/superliuwr/jaeger-demo/frontend/synthetic"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// Observer follows the spans of the Jaeger tracer it is registered with,
+// and records the cost of every trace whose local root span is tagged
+// with TierTag once that span finishes. The endpoint of the cost is the
+// operation name of the root span. Synthetic canaries are left out.
+type Observer struct {
+	lock sync.Mutex
+	// traces holds the trace of every span that started and whose local
+	// root has not finished yet, by span ID.
+	traces map[jaeger.SpanID]*trace
+}
+
+// trace holds the spans of a local root span and of its descendants in
+// this process.
+type trace struct {
+	id    jaeger.TraceID
+	root  jaeger.SpanID
+	spans map[jaeger.SpanID]*span
+}
+
+type span struct {
+	parent    jaeger.SpanID
+	operation string
+	start     time.Time
+	finish    time.Time
+	client    bool
+	bytes     int64
+	tier      string
+	synthetic bool
+}
+
+// NewObserver creates an Observer.
+func NewObserver() *Observer {
+	return &Observer{traces: make(map[jaeger.SpanID]*trace)}
+}
+
+// OnStartSpan implements jaeger.ContribObserver
+func (o *Observer) OnStartSpan(sp opentracing.Span, operationName string, options opentracing.StartSpanOptions) (jaeger.ContribSpanObserver, bool) {
+	js, ok := sp.(*jaeger.Span)
+	if !ok {
+		return nil, false
+	}
+	ctx := js.SpanContext()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	t, ok := o.traces[ctx.ParentID()]
+	if !ok {
+		// A span without a parent in this process is a local root, even
+		// if it shares the trace ID of an earlier one.
+		t = &trace{id: ctx.TraceID(), root: ctx.SpanID(), spans: make(map[jaeger.SpanID]*span)}
+	}
+	s := &span{parent: ctx.ParentID(), operation: operationName, start: js.StartTime()}
+	t.spans[ctx.SpanID()] = s
+	o.traces[ctx.SpanID()] = t
+	return &spanObserver{observer: o, trace: t, spanID: ctx.SpanID(), span: s}, true
+}
+
+type spanObserver struct {
+	observer *Observer
+	trace    *trace
+	spanID   jaeger.SpanID
+	span     *span
+}
+
+func (so *spanObserver) OnSetOperationName(operationName string) {
+	so.observer.lock.Lock()
+	defer so.observer.lock.Unlock()
+	so.span.operation = operationName
+}
+
+func (so *spanObserver) OnSetTag(key string, value interface{}) {
+	so.observer.lock.Lock()
+	defer so.observer.lock.Unlock()
+	switch key {
+	case string(ext.SpanKind):
+		so.span.client = value == ext.SpanKindRPCClientEnum || value == string(ext.SpanKindRPCClientEnum)
+	case tracing.RequestSizeTag, tracing.ResponseSizeTag:
+		switch size := value.(type) {
+		case int:
+			so.span.bytes += int64(size)
+		case int64:
+			so.span.bytes += size
+		}
+	case TierTag:
+		so.span.tier, _ = value.(string)
+	case synthetic.Tag:
+		so.span.synthetic = value == true
+	}
+}
+
+func (so *spanObserver) OnFinish(options opentracing.FinishOptions) {
+	o, t := so.observer, so.trace
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	so.span.finish = options.FinishTime
+	if t.root != so.spanID {
+		return
+	}
+	// Spans that outlive their local root are not followed anymore.
+	for id := range t.spans {
+		delete(o.traces, id)
+	}
+
+	root := so.span
+	if root.tier == "" || root.synthetic {
+		return
+	}
+	Record(Dispatch{
+		Time:     root.finish,
+		TraceID:  t.id.String(),
+		Tier:     root.tier,
+		Endpoint: root.operation,
+		Cost:     t.cost(),
+	})
+}
+
+// cost estimates the cost of the finished spans of t.
+func (t *trace) cost() Cost {
+	children := make(map[jaeger.SpanID][]*span, len(t.spans))
+	for _, s := range t.spans {
+		if !s.finish.IsZero() {
+			children[s.parent] = append(children[s.parent], s)
+		}
+	}
+	var c Cost
+	for id, s := range t.spans {
+		if s.finish.IsZero() {
+			continue
+		}
+		c.Bytes += s.bytes
+		if s.client {
+			c.Calls++
+			continue
+		}
+		c.CPUMillis += float64(selfTime(s, children[id])) / float64(time.Millisecond)
+	}
+	return c
+}
+
+// selfTime is the time of s not covered by any of its children.
+func selfTime(s *span, children []*span) time.Duration {
+	sort.Slice(children, func(i, j int) bool { return children[i].start.Before(children[j].start) })
+	self := s.finish.Sub(s.start)
+	covered := s.start
+	for _, child := range children {
+		start, finish := child.start, child.finish
+		if start.Before(covered) {
+			start = covered
+		}
+		if finish.After(s.finish) {
+			finish = s.finish
+		}
+		if finish.After(start) {
+			self -= finish.Sub(start)
+			covered = finish
+		}
+	}
+	if self < 0 {
+		return 0
+	}
+	return self
+}
@@ -0,0 +1,148 @@
+package depgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Edge describes calls from one service to a downstream service.
+type Edge struct {
+	From string
+	To   string
+	// Calls is the number of calls made within the window, counted in
+	// slots of a sixtieth of the window.
+	Calls    int64
+	LastSeen time.Time
+}
+
+// windowSlots is the number of slots the window of a Graph is divided in.
+const windowSlots = 60
+
+type slot struct {
+	index int64
+	calls int64
+}
+
+// edge counts the calls of an Edge per slot, in a ring buffer.
+type edge struct {
+	Edge
+	slots [windowSlots]slot
+}
+
+// Graph tracks which downstream services a service actually called
+// within a sliding time window.
+type Graph struct {
+	service string
+	window  time.Duration
+	slot    time.Duration
+
+	lock  sync.Mutex
+	edges map[string]*edge
+}
+
+// New creates a new Graph for the given service. Edges that have not been
+// seen for longer than window are dropped from snapshots, and calls older
+// than window are not counted.
+func New(service string, window time.Duration) *Graph {
+	slot := window / windowSlots
+	if slot <= 0 {
+		slot = 1
+	}
+	return &Graph{
+		service: service,
+		window:  window,
+		slot:    slot,
+		edges:   make(map[string]*edge),
+	}
+}
+
+// Record registers a call to the downstream service.
+func (g *Graph) Record(downstream string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	e, ok := g.edges[downstream]
+	if !ok {
+		e = &edge{Edge: Edge{From: g.service, To: downstream}}
+		g.edges[downstream] = e
+	}
+	now := time.Now()
+	index := now.UnixNano() / int64(g.slot)
+	s := &e.slots[index%windowSlots]
+	if s.index != index {
+		*s = slot{index: index}
+	}
+	s.calls++
+	e.LastSeen = now
+}
+
+// Snapshot returns the edges seen within the window, sorted by downstream name.
+func (g *Graph) Snapshot() []Edge {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-g.window)
+	last := now.UnixNano() / int64(g.slot)
+	edges := make([]Edge, 0, len(g.edges))
+	for name, e := range g.edges {
+		if e.LastSeen.Before(cutoff) {
+			delete(g.edges, name)
+			continue
+		}
+		snapshot := e.Edge
+		for _, s := range e.slots {
+			if s.index > last-windowSlots {
+				snapshot.Calls += s.calls
+			}
+		}
+		edges = append(edges, snapshot)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].To < edges[j].To })
+
+	return edges
+}
+
+// ServeHTTP renders the graph as JSON, or as DOT if ?format=dot is given.
+func (g *Graph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	edges := g.Snapshot()
+
+	if r.URL.Query().Get("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		_, _ = w.Write([]byte(toDOT(edges)))
+		return
+	}
+
+	data, err := json.Marshal(struct {
+		Service string
+		Window  string
+		Edges   []Edge
+	}{
+		Service: g.service,
+		Window:  g.window.String(),
+		Edges:   edges,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func toDOT(edges []Edge) string {
+	var b strings.Builder
+	b.WriteString("digraph depgraph {\n")
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=\"%d\"];\n", e.From, e.To, e.Calls)
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
@@ -0,0 +1,204 @@
+package dispatchlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// chunkSize is the number of records streamed between span events.
+const chunkSize = 100
+
+// Record describes one completed dispatch.
+type Record struct {
+	Time     time.Time
+	Customer string
+	Driver   string
+	ETA      int
+}
+
+// Event is a row of the outbox: a dispatch waiting to be published.
+type Event struct {
+	ID     uint64
+	Record Record
+	// Attempts counts the failed attempts to publish the event so far.
+	Attempts int
+	// Span is the context of the dispatch, for the publishing span to follow from.
+	Span opentracing.SpanContext `json:"-"`
+}
+
+// Log keeps the most recent dispatches in memory.
+type Log struct {
+	lock    sync.Mutex
+	records []Record
+	next    int
+	full    bool
+
+	// outbox holds the events not published yet, oldest first. It is
+	// nil unless EnableOutbox was called.
+	outbox      []Event
+	outboxSize  int
+	lastEventID uint64
+}
+
+// New creates a new Log holding up to capacity records.
+func New(capacity int) *Log {
+	return &Log{records: make([]Record, capacity)}
+}
+
+// EnableOutbox makes Add write an outbox event with every record, keeping
+// up to size unpublished events. When the outbox is full, the oldest event
+// is dropped.
+//
+// Event IDs count up from the current time in microseconds, so a restarted
+// frontend does not reuse the IDs of the events it published before,
+// unless it averaged more than one event a microsecond. Microseconds also
+// keep IDs below 2^53, so receivers in JavaScript read them exactly.
+func (l *Log) EnableOutbox(size int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.outbox = make([]Event, 0, size)
+	l.outboxSize = size
+	l.lastEventID = uint64(time.Now().UnixMicro())
+}
+
+// Add appends a record, dropping the oldest one if the log is full. If the
+// outbox is enabled, an event for the record is written to it atomically
+// with the record, tied to the span in ctx.
+func (l *Log) Add(ctx context.Context, record Record) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.records) == 0 {
+		return
+	}
+	l.append(record)
+
+	if l.outbox == nil {
+		return
+	}
+	l.lastEventID++
+	event := Event{ID: l.lastEventID, Record: record}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		event.Span = span.Context()
+	}
+	if len(l.outbox) == l.outboxSize {
+		l.outbox = l.outbox[1:]
+	}
+	l.outbox = append(l.outbox, event)
+}
+
+func (l *Log) append(record Record) {
+	l.records[l.next] = record
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// restore appends a record without writing an outbox event, as it was
+// published when first added.
+func (l *Log) restore(record Record) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.records) > 0 {
+		l.append(record)
+	}
+}
+
+// reset removes every record. The outbox is left as it is.
+func (l *Log) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.records = make([]Record, len(l.records))
+	l.next = 0
+	l.full = false
+}
+
+// Pending returns the outbox events not published yet, oldest first.
+func (l *Log) Pending() []Event {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return append([]Event(nil), l.outbox...)
+}
+
+// Published removes the event with the given ID from the outbox.
+func (l *Log) Published(id uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i, event := range l.outbox {
+		if event.ID == id {
+			l.outbox = append(l.outbox[:i], l.outbox[i+1:]...)
+			return
+		}
+	}
+}
+
+// Failed counts a failed attempt to publish the event with the given ID.
+func (l *Log) Failed(id uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i := range l.outbox {
+		if l.outbox[i].ID == id {
+			l.outbox[i].Attempts++
+			return
+		}
+	}
+}
+
+// Snapshot returns the records in the log, oldest first.
+func (l *Log) Snapshot() []Record {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.full {
+		return append([]Record(nil), l.records[:l.next]...)
+	}
+	return append(append([]Record(nil), l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// ServeHTTP streams the records as NDJSON, flushing after every record so
+// large exports are never buffered. Every chunk of records is logged as an
+// event on the request span.
+func (l *Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	records := l.Snapshot()
+	span := opentracing.SpanFromContext(r.Context())
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	for i, record := range records {
+		if err := r.Context().Err(); err != nil {
+			logChunk(span, "stream aborted", i, otlog.Error(err))
+			return
+		}
+		if err := encoder.Encode(record); err != nil {
+			logChunk(span, "stream aborted", i, otlog.Error(err))
+			return
+		}
+		// Flush fails if the writer cannot stream, the records then go out together.
+		_ = rc.Flush()
+		if (i+1)%chunkSize == 0 {
+			logChunk(span, "chunk sent", i+1)
+		}
+	}
+	logChunk(span, "stream complete", len(records))
+}
+
+func logChunk(span opentracing.Span, event string, sent int, fields ...otlog.Field) {
+	if span == nil {
+		return
+	}
+	span.LogFields(append([]otlog.Field{otlog.String("event", event), otlog.Int("records", sent)}, fields...)...)
+}
@@ -0,0 +1,128 @@
+package dispatchlog
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+var duplicateEvents = expvar.NewInt("dispatch_events_duplicate")
+
+// EventType is a step in the lifecycle of a dispatch.
+type EventType string
+
+// The lifecycle of a dispatch: the customer requests it, a driver is
+// assigned, and the customer is answered.
+const (
+	Requested EventType = "requested"
+	Assigned  EventType = "assigned"
+	Completed EventType = "completed"
+)
+
+// LifecycleEvent is an entry of the event log. Dispatch identifies the
+// dispatch, and with Type the event, so it is only recorded once.
+type LifecycleEvent struct {
+	Seq      uint64
+	Dispatch string
+	Type     EventType
+	Time     time.Time
+	Customer string `json:",omitempty"`
+	Driver   string `json:",omitempty"`
+	ETA      int    `json:",omitempty"`
+	// Span is the context of the dispatch, for the projection span to
+	// be a child of.
+	Span opentracing.SpanContext `json:"-"`
+}
+
+func (e LifecycleEvent) key() string {
+	return e.Dispatch + "/" + string(e.Type)
+}
+
+// EventStore is an append-only log of lifecycle events, holding up to
+// capacity events. When it is full, the oldest event is dropped.
+type EventStore struct {
+	lock        sync.Mutex
+	events      []LifecycleEvent
+	capacity    int
+	keys        map[string]struct{}
+	lastSeq     uint64
+	subscribers []func(context.Context, LifecycleEvent)
+}
+
+// NewEventStore creates an EventStore holding up to capacity events.
+func NewEventStore(capacity int) *EventStore {
+	return &EventStore{capacity: capacity, keys: make(map[string]struct{})}
+}
+
+// Subscribe calls apply with every event appended from now on, in order.
+func (s *EventStore) Subscribe(apply func(context.Context, LifecycleEvent)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.subscribers = append(s.subscribers, apply)
+}
+
+// Append numbers the event and adds it to the log, unless an event of the
+// same type was already recorded for the dispatch, e.g. by a request
+// retried within its trace. Duplicates are counted in the
+// dispatch_events_duplicate expvar. The subscribers are called with
+// appended events before Append returns.
+func (s *EventStore) Append(ctx context.Context, event LifecycleEvent) bool {
+	if span := opentracing.SpanFromContext(ctx); span != nil && event.Span == nil {
+		event.Span = span.Context()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.capacity == 0 {
+		return false
+	}
+	key := event.key()
+	if _, ok := s.keys[key]; ok {
+		duplicateEvents.Add(1)
+		return false
+	}
+	if len(s.events) == s.capacity {
+		delete(s.keys, s.events[0].key())
+		s.events = s.events[1:]
+	}
+	s.lastSeq++
+	event.Seq = s.lastSeq
+	s.events = append(s.events, event)
+	s.keys[key] = struct{}{}
+	// Subscribers run under the lock, so they see events in order.
+	for _, apply := range s.subscribers {
+		apply(ctx, event)
+	}
+	return true
+}
+
+// replay calls f with the events in the log, oldest first. Nothing is
+// appended until f returns.
+func (s *EventStore) replay(f func(events []LifecycleEvent)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f(s.events)
+}
+
+// Events returns the events in the log, oldest first.
+func (s *EventStore) Events() []LifecycleEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]LifecycleEvent(nil), s.events...)
+}
+
+// ServeHTTP renders the event log as JSON.
+func (s *EventStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(s.Events())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,147 @@
+package dispatchlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// maxPending bounds the dispatches the projection tracks between their
+// first event and completion. Dispatches that fail never complete, and
+// the oldest of them are forgotten beyond it.
+const maxPending = 1000
+
+// Projection builds the dispatch history in a Log from the events of an
+// EventStore: a dispatch enters the history once it is completed, with
+// the customer it was requested for and the driver assigned to it.
+type Projection struct {
+	tracer opentracing.Tracer
+	store  *EventStore
+	log    *Log
+
+	lock    sync.Mutex
+	pending map[string]*Record
+	order   []string
+	applied uint64
+}
+
+// RebuildResult summarizes a rebuild of the projection.
+type RebuildResult struct {
+	Events   int
+	Records  int
+	Duration time.Duration
+}
+
+// NewProjection creates a Projection of store into log, and applies the
+// events appended to store from now on.
+func NewProjection(tracer opentracing.Tracer, store *EventStore, log *Log) *Projection {
+	p := &Projection{tracer: tracer, store: store, log: log, pending: make(map[string]*Record)}
+	store.Subscribe(p.apply)
+	return p
+}
+
+// apply projects an event in a span of its own, a child of the dispatch.
+// Events already applied are skipped, so applying is idempotent.
+func (p *Projection) apply(ctx context.Context, event LifecycleEvent) {
+	span := p.tracer.StartSpan("projection: apply "+string(event.Type), opentracing.ChildOf(event.Span))
+	defer span.Finish()
+	span.SetTag("dispatch.id", event.Dispatch)
+	span.SetTag("event.seq", event.Seq)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if event.Seq <= p.applied {
+		span.SetTag("projection.skipped", true)
+		return
+	}
+	if record := p.project(event); record != nil {
+		p.log.Add(ctx, *record)
+		span.LogFields(otlog.String("event", "dispatch added to history"))
+	}
+}
+
+// project applies event to the pending dispatches, and returns the
+// record of the dispatch it completes, if it does.
+func (p *Projection) project(event LifecycleEvent) *Record {
+	p.applied = event.Seq
+	record, ok := p.pending[event.Dispatch]
+	if !ok {
+		record = &Record{}
+		p.pending[event.Dispatch] = record
+		p.order = append(p.order, event.Dispatch)
+		if len(p.order) > maxPending {
+			delete(p.pending, p.order[0])
+			p.order = p.order[1:]
+		}
+	}
+	switch event.Type {
+	case Requested:
+		record.Customer = event.Customer
+	case Assigned:
+		record.Driver = event.Driver
+		record.ETA = event.ETA
+	case Completed:
+		record.Time = event.Time
+		delete(p.pending, event.Dispatch)
+		return record
+	}
+	return nil
+}
+
+// Rebuild clears the history and replays every event in the store into
+// it, in a "projection: rebuild" span. Rebuilt records are not written to
+// the outbox again. Events appended meanwhile wait for the rebuild.
+func (p *Projection) Rebuild(ctx context.Context) RebuildResult {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, p.tracer, "projection: rebuild")
+	defer span.Finish()
+
+	var result RebuildResult
+	start := time.Now()
+	p.store.replay(func(events []LifecycleEvent) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		p.log.reset()
+		p.pending = make(map[string]*Record)
+		p.order = nil
+		p.applied = 0
+		for i, event := range events {
+			if record := p.project(event); record != nil {
+				p.log.restore(*record)
+				result.Records++
+			}
+			if (i+1)%chunkSize == 0 {
+				span.LogFields(otlog.String("event", "events replayed"), otlog.Int("events", i+1))
+			}
+		}
+		result.Events = len(events)
+	})
+	result.Duration = time.Since(start)
+	span.SetTag("projection.events", result.Events)
+	span.SetTag("projection.records", result.Records)
+	return result
+}
+
+// RebuildHandler rebuilds the projection on POST and answers with the
+// RebuildResult.
+func (p *Projection) RebuildHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := p.Rebuild(r.Context())
+		data, err := json.Marshal(result)
+		if err != nil {
+			if span := opentracing.SpanFromContext(r.Context()); span != nil {
+				ext.Error.Set(span, true)
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// drainer keeps track of the dispatches in flight, so a shutdown can let
+// them finish while it turns new ones away.
+type drainer struct {
+	lock     sync.Mutex
+	draining bool
+	inFlight int
+	// idle is closed once draining and no dispatch is in flight.
+	idle chan struct{}
+}
+
+// enter counts a dispatch in, unless the server is draining.
+func (d *drainer) enter() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inFlight++
+	return true
+}
+
+// leave counts a dispatch out, and tells if the server started draining
+// while it ran.
+func (d *drainer) leave() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.inFlight--
+	if d.draining && d.inFlight == 0 {
+		close(d.idle)
+	}
+	return d.draining
+}
+
+// start stops new dispatches, and returns a channel closed once those in
+// flight have finished.
+func (d *drainer) start() <-chan struct{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.draining {
+		d.draining = true
+		d.idle = make(chan struct{})
+		if d.inFlight == 0 {
+			close(d.idle)
+		}
+	}
+	return d.idle
+}
+
+// isDraining tells if the server is shutting down.
+func (d *drainer) isDraining() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.draining
+}
+
+// handler answers 503 with Retry-After to requests that come in while the
+// server is draining, and lets the others run to completion. The spans of
+// both are tagged draining=true. It must run inside the tracing middleware.
+func (d *drainer) handler(retryAfter time.Duration, next http.Handler) http.Handler {
+	seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := opentracing.SpanFromContext(r.Context())
+		if !d.enter() {
+			if span != nil {
+				span.SetTag("draining", true)
+			}
+			w.Header().Set("Retry-After", seconds)
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer func() {
+			if d.leave() && span != nil {
+				span.SetTag("draining", true)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readiness fails readiness probes while the server is draining, so load
+// balancers stop sending it traffic, and passes the others on to next.
+func (d *drainer) readiness(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.isDraining() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(struct {
+			Ready    bool
+			Draining bool
+		}{false, true})
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// DependencyError is the failure of one call to a downstream service.
+type DependencyError struct {
+	Dependency string
+	// Target identifies the call among its siblings, e.g. the driver a route was requested for.
+	Target string
+	Error  string
+}
+
+// FanOutError aggregates the failures of parallel calls to a downstream service.
+type FanOutError struct {
+	Dependency string
+	Calls      int
+	Failures   []DependencyError
+
+	// err joins the errors of the failed calls, so errors.Is and
+	// errors.As see through the fan-out.
+	err error
+}
+
+// Error implements error
+func (e *FanOutError) Error() string {
+	return fmt.Sprintf("%d of %d %s calls failed, first: %s", len(e.Failures), e.Calls, e.Dependency, e.Failures[0].Error)
+}
+
+// Unwrap returns the joined errors of the failed calls.
+func (e *FanOutError) Unwrap() error {
+	return e.err
+}
+
+// add records the failure of the call to target.
+func (e *FanOutError) add(target string, err error) {
+	e.Failures = append(e.Failures, DependencyError{
+		Dependency: e.Dependency,
+		Target:     target,
+		Error:      err.Error(),
+	})
+	e.err = errors.Join(e.err, err)
+}
+
+// annotate marks span as failed and logs every failure on it.
+func (e *FanOutError) annotate(span opentracing.Span) {
+	if span == nil {
+		return
+	}
+	ext.Error.Set(span, true)
+	for _, f := range e.Failures {
+		span.LogFields(
+			otlog.String("event", "error"),
+			otlog.String("dependency", f.Dependency),
+			otlog.String("target", f.Target),
+			otlog.String("message", f.Error),
+		)
+	}
+}
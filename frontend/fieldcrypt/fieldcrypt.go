@@ -0,0 +1,55 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+)
+
+// Cipher decrypts individual response fields that another service encrypted
+// with AES-GCM using a shared key. Encrypted fields are base64 encoded and
+// carry the nonce in front of the ciphertext.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a Cipher from a base64 encoded 16, 24 or 32 byte AES key.
+func New(base64Key string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(base64Key)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Decrypt returns the plaintext of an encrypted field.
+func (c *Cipher) Decrypt(field string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(field)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", errors.New("encrypted field is too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Check probes one dependency the service needs to be ready.
+type Check struct {
+	Name   string
+	Target string
+	Probe  func(ctx context.Context) error
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Name     string
+	Target   string
+	OK       bool
+	Error    string `json:",omitempty"`
+	Duration time.Duration
+}
+
+// TCP checks that a TCP connection to hostPort can be opened.
+func TCP(name, hostPort string) Check {
+	return Check{Name: name, Target: hostPort, Probe: func(ctx context.Context) error {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", hostPort)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}}
+}
+
+// Resolve checks that host resolves. It stands in for dependencies reached
+// over UDP, such as the Jaeger agent, that cannot be probed with a connection.
+func Resolve(name, host string) Check {
+	return Check{Name: name, Target: host, Probe: func(ctx context.Context) error {
+		_, err := net.DefaultResolver.LookupHost(ctx, host)
+		return err
+	}}
+}
+
+// Liveness answers 200 OK as long as the server can handle requests.
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// Readiness runs all checks in parallel, each within timeout, and answers
+// 200 OK if they all pass or 503 Service Unavailable otherwise, with the
+// results as JSON. Failed checks are tagged on the request span.
+func Readiness(timeout time.Duration, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		results := make([]Result, len(checks))
+		done := make(chan struct{})
+		for i, check := range checks {
+			go func(i int, check Check) {
+				start := time.Now()
+				err := check.Probe(ctx)
+				results[i] = Result{Name: check.Name, Target: check.Target, OK: err == nil, Duration: time.Since(start)}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+				done <- struct{}{}
+			}(i, check)
+		}
+		for range checks {
+			<-done
+		}
+
+		status := http.StatusOK
+		span := opentracing.SpanFromContext(r.Context())
+		for _, result := range results {
+			if result.OK {
+				continue
+			}
+			status = http.StatusServiceUnavailable
+			if span != nil {
+				span.SetTag("health."+result.Name, "failed")
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(struct {
+			Ready  bool
+			Checks []Result
+		}{status == http.StatusOK, results})
+	})
+}
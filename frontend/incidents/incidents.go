@@ -0,0 +1,161 @@
+package incidents
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Kind tells what happened in an Event.
+type Kind string
+
+// Kinds of events on the timeline.
+const (
+	ChaosActivated Kind = "chaos"
+	SLOBreach      Kind = "slo-breach"
+	Recovery       Kind = "recovery"
+)
+
+// capacity is the number of events kept on the timeline.
+const capacity = 100
+
+// maxTraceIDs is the number of traces kept per event. Later traces of the
+// same event are only counted.
+const maxTraceIDs = 10
+
+// Event is one entry of the incident timeline.
+type Event struct {
+	Time    time.Time
+	Kind    Kind
+	Summary string
+	// TraceIDs are the first traces related to the event, and Traces is
+	// the number of all of them.
+	TraceIDs []string
+	Traces   int
+}
+
+// Objective is the SLO of dispatches: a dispatch that fails or takes
+// longer than Latency breaches it.
+type Objective struct {
+	Latency time.Duration
+}
+
+var (
+	lock      sync.Mutex
+	events    []Event
+	objective = Objective{Latency: 2 * time.Second}
+	// breaching tells if an SLO breach is ongoing, and breachStart when it
+	// began.
+	breaching   bool
+	breachStart time.Time
+	// breach is the index in events of the ongoing SLO breach, or -1 if
+	// there is none or its event was evicted from the timeline.
+	breach = -1
+)
+
+// SetObjective replaces the SLO that Observe checks dispatches against.
+func SetObjective(o Objective) {
+	lock.Lock()
+	defer lock.Unlock()
+	objective = o
+}
+
+// Record adds an event to the timeline.
+func Record(kind Kind, summary string, traceIDs ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+	record(kind, summary, traceIDs)
+}
+
+// Relate adds traceID to the latest event of the given kind, if there is one.
+func Relate(kind Kind, traceID string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Kind == kind {
+			events[i].relate(traceID)
+			return
+		}
+	}
+}
+
+// Observe checks a dispatch against the SLO. The first dispatch to breach
+// it opens an SLO breach event, the following ones are related to it, and
+// the first one to meet it again records the recovery.
+func Observe(traceID string, latency time.Duration, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	breached := err != nil || latency > objective.Latency
+	switch {
+	case breached && !breaching:
+		summary := "dispatch took " + latency.Round(time.Millisecond).String() + ", objective is " + objective.Latency.String()
+		if err != nil {
+			summary = "dispatch failed: " + err.Error()
+		}
+		record(SLOBreach, summary, []string{traceID})
+		breaching, breachStart = true, time.Now()
+		breach = len(events) - 1
+	case breached:
+		if breach >= 0 && breach < len(events) {
+			events[breach].relate(traceID)
+		}
+	case breaching:
+		since := time.Since(breachStart).Round(time.Second)
+		record(Recovery, "dispatches meet the objective again after "+since.String(), []string{traceID})
+		breaching, breach = false, -1
+	}
+}
+
+// Events returns the events on the timeline, oldest first.
+func Events() []Event {
+	lock.Lock()
+	defer lock.Unlock()
+
+	snapshot := make([]Event, len(events))
+	for i, e := range events {
+		snapshot[i] = e
+		snapshot[i].TraceIDs = append([]string(nil), e.TraceIDs...)
+	}
+	return snapshot
+}
+
+// Handler renders the timeline as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(Events())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+// record must be called with lock held.
+func record(kind Kind, summary string, traceIDs []string) {
+	event := Event{Time: time.Now(), Kind: kind, Summary: summary}
+	for _, id := range traceIDs {
+		event.relate(id)
+	}
+	if len(events) == capacity {
+		events = events[1:]
+		if breach >= 0 {
+			breach--
+		}
+	}
+	events = append(events, event)
+}
+
+func (e *Event) relate(traceID string) {
+	if traceID == "" {
+		return
+	}
+	e.Traces++
+	if len(e.TraceIDs) < maxTraceIDs {
+		e.TraceIDs = append(e.TraceIDs, traceID)
+	}
+}
@@ -0,0 +1,159 @@
+//go:build !lite
+// +build !lite
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/depgraph"
+	"github.com/superliuwr/jaeger-demo/frontend/dispatchlog"
+	"github.com/superliuwr/jaeger-demo/frontend/httperr"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/ui"
+)
+
+// recentDispatches is the number of dispatches shown on the index page.
+const recentDispatches = 5
+
+var indexFuncs = template.FuncMap{
+	"minutes": func(eta int) int64 {
+		return int64(math.Round(time.Duration(eta).Minutes()))
+	},
+}
+
+// indexPage renders the index page server-side. The template is parsed
+// while the data it shows is fetched, and both are merged at render time.
+type indexPage struct {
+	tracer   opentracing.Tracer
+	logger   log.Factory
+	depGraph *depgraph.Graph
+	history  *dispatchlog.Log
+	// local reads the template from disk on every request and has the
+	// page reload itself when the assets change.
+	local bool
+	// basePath prefixes the API calls of the page. It is "" at the root.
+	basePath string
+}
+
+type indexData struct {
+	Services   []depgraph.Edge
+	Dispatches []dispatchlog.Record
+	LiveReload bool
+	BasePath   string
+}
+
+// Handler serves the index page and hands every other path to next.
+func (p *indexPage) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "" && r.URL.Path != "/" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.ServeHTTP(w, r)
+	})
+}
+
+func (p *indexPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	type parsed struct {
+		tmpl *template.Template
+		err  error
+	}
+	templates := make(chan parsed, 1)
+	go func() {
+		tmpl, err := p.parse(ctx)
+		templates <- parsed{tmpl, err}
+	}()
+
+	services := make(chan []depgraph.Edge, 1)
+	go func() { services <- p.services(ctx) }()
+	dispatches := make(chan []dispatchlog.Record, 1)
+	go func() { dispatches <- p.dispatches(ctx) }()
+
+	data := indexData{Services: <-services, Dispatches: <-dispatches, LiveReload: p.local, BasePath: p.basePath}
+	t := <-templates
+	if httperr.HandleError(w, t.err, http.StatusInternalServerError) {
+		p.logger.For(ctx).Error("cannot parse index page", zap.Error(t.err))
+		return
+	}
+
+	page, err := p.render(ctx, t.tmpl, data)
+	if httperr.HandleError(w, err, http.StatusInternalServerError) {
+		p.logger.For(ctx).Error("cannot render index page", zap.Error(err))
+		return
+	}
+	// The page changes with every dispatch, so browsers must revalidate it;
+	// ServeContent answers with 304 Not Modified while the ETag matches,
+	// and compresses it for browsers that accept gzip.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	ui.ServeContent(w, r, page, ui.ETag(page))
+}
+
+func (p *indexPage) parse(ctx context.Context) (*template.Template, error) {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, p.tracer, "index: parse template")
+	defer span.Finish()
+
+	source, err := ui.FSString(p.local, "/index.html")
+	if err == nil {
+		var tmpl *template.Template
+		tmpl, err = template.New("index").Funcs(indexFuncs).Parse(source)
+		if err == nil {
+			return tmpl, nil
+		}
+	}
+	ext.Error.Set(span, true)
+	span.LogFields(otlog.Error(err))
+	return nil, err
+}
+
+func (p *indexPage) services(ctx context.Context) []depgraph.Edge {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, p.tracer, "index: fetch service status")
+	defer span.Finish()
+
+	edges := p.depGraph.Snapshot()
+	span.SetTag("services", len(edges))
+	return edges
+}
+
+// dispatches returns the most recent dispatches, newest first.
+func (p *indexPage) dispatches(ctx context.Context) []dispatchlog.Record {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, p.tracer, "index: fetch recent dispatches")
+	defer span.Finish()
+
+	records := p.history.Snapshot()
+	if len(records) > recentDispatches {
+		records = records[len(records)-recentDispatches:]
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	span.SetTag("dispatches", len(records))
+	return records
+}
+
+func (p *indexPage) render(ctx context.Context, tmpl *template.Template, data indexData) ([]byte, error) {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, p.tracer, "index: render")
+	defer span.Finish()
+
+	var page bytes.Buffer
+	if err := tmpl.Execute(&page, data); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return nil, err
+	}
+	span.SetTag("bytes", page.Len())
+	return page.Bytes(), nil
+}
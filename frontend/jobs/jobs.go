@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// capacity is the number of jobs kept, finished or not. The oldest job is
+// forgotten when a new one is submitted.
+const capacity = 100
+
+// State tells how far a Job got.
+type State string
+
+// States of a job.
+const (
+	Pending State = "pending"
+	Running State = "running"
+	Done    State = "done"
+	Failed  State = "failed"
+)
+
+// Job is one asynchronous task and its outcome.
+type Job struct {
+	ID       string
+	Kind     string
+	State    State
+	Created  time.Time
+	Started  *time.Time  `json:",omitempty"`
+	Finished *time.Time  `json:",omitempty"`
+	Result   interface{} `json:",omitempty"`
+	Error    string      `json:",omitempty"`
+	// TraceID is the trace of the job's own span, which follows from the
+	// request that submitted it.
+	TraceID string `json:",omitempty"`
+}
+
+// Func does the work of a job and returns its result.
+type Func func(ctx context.Context) (interface{}, error)
+
+// Runner runs jobs in the background and keeps their state for polling.
+type Runner struct {
+	tracer opentracing.Tracer
+	logger log.Factory
+
+	lock  sync.Mutex
+	jobs  map[string]*Job
+	order []string
+}
+
+// NewRunner creates a Runner.
+func NewRunner(tracer opentracing.Tracer, logger log.Factory) *Runner {
+	return &Runner{tracer: tracer, logger: logger, jobs: make(map[string]*Job)}
+}
+
+// Submit starts a job of the given kind and returns it right away, still
+// pending. The job runs in a span of its own that follows from the span in
+// ctx, so the request that submitted it is not held up by it, and is not
+// canceled with ctx.
+func (r *Runner) Submit(ctx context.Context, kind string, run Func) Job {
+	job := &Job{ID: newID(), Kind: kind, State: Pending, Created: time.Now()}
+
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.FollowsFrom(parent.Context()))
+		parent.SetTag("job.id", job.ID)
+	}
+	span := r.tracer.StartSpan("job: "+kind, opts...)
+	span.SetTag("job.id", job.ID)
+	span.SetTag("job.kind", kind)
+	jobCtx := opentracing.ContextWithSpan(context.Background(), span)
+	job.TraceID = tracing.TraceID(jobCtx)
+
+	r.lock.Lock()
+	if len(r.order) == capacity {
+		delete(r.jobs, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.jobs[job.ID] = job
+	r.order = append(r.order, job.ID)
+	submitted := *job
+	r.lock.Unlock()
+
+	go r.run(jobCtx, span, job, run)
+	return submitted
+}
+
+func (r *Runner) run(ctx context.Context, span opentracing.Span, job *Job, run Func) {
+	defer span.Finish()
+	r.update(job, func() {
+		now := time.Now()
+		job.State = Running
+		job.Started = &now
+	})
+	r.logger.For(ctx).Info("Job started", zap.String("job", job.ID), zap.String("kind", job.Kind))
+
+	result, err := run(ctx)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		r.logger.For(ctx).Error("Job failed", zap.String("job", job.ID), zap.Error(err))
+	} else {
+		r.logger.For(ctx).Info("Job done", zap.String("job", job.ID))
+	}
+	r.update(job, func() {
+		now := time.Now()
+		job.Finished = &now
+		if err != nil {
+			job.State = Failed
+			job.Error = err.Error()
+			return
+		}
+		job.State = Done
+		job.Result = result
+	})
+	span.SetTag("job.state", string(job.State))
+}
+
+func (r *Runner) update(job *Job, f func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	f()
+}
+
+// Get returns the job with the given ID, if it is still kept.
+func (r *Runner) Get(id string) (Job, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// ServeHTTP renders the job whose ID is the id path value as JSON, or
+// answers 404 Not Found if there is none.
+func (r *Runner) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	job, ok := r.Get(req.PathValue("id"))
+	if !ok {
+		http.Error(w, "no such job", http.StatusNotFound)
+		return
+	}
+	Write(w, http.StatusOK, job)
+}
+
+// Write renders job as JSON with the given status.
+func Write(w http.ResponseWriter, status int, job Job) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,128 @@
+package limits
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+var rejections = expvar.NewMap("request_limit_rejections")
+
+// defaultMaxFormBytes bounds form bodies when MaxFormBytes is not set, as
+// ParseForm itself does.
+const defaultMaxFormBytes = 10 << 20
+
+// Limits bound the size of requests accepted by a server exposed to the internet.
+// Zero values disable the corresponding check.
+type Limits struct {
+	MaxHeaderBytes int
+	MaxQueryLength int
+	// MaxFormBytes bounds url-encoded form bodies, whose parameters are
+	// checked like those of the query.
+	MaxFormBytes   int
+	MaxParamLength map[string]int
+}
+
+// Handler rejects requests over the limits with 431 Request Header Fields
+// Too Large, 414 URI Too Long, or 413 Request Entity Too Large for form
+// bodies. It parses the form of the request, so handlers find it in
+// r.Form, and rejects a form that doesn't parse with 400 Bad Request. A
+// form body is buffered, so handlers can still read r.Body.
+// Rejections are counted in the request_limit_rejections expvar and
+// tagged on the request span, so it must run inside the tracing
+// middleware.
+//
+// net/http itself answers requests far beyond http.Server.MaxHeaderBytes
+// before any handler runs, so those are neither counted nor traced.
+func (l Limits) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.MaxHeaderBytes > 0 && headerBytes(r.Header) > l.MaxHeaderBytes {
+			reject(w, r, http.StatusRequestHeaderFieldsTooLarge, "headers", fmt.Sprintf("headers over %d bytes", l.MaxHeaderBytes))
+			return
+		}
+		if l.MaxQueryLength > 0 && len(r.URL.RawQuery) > l.MaxQueryLength {
+			reject(w, r, http.StatusRequestURITooLong, "query", fmt.Sprintf("query over %d bytes", l.MaxQueryLength))
+			return
+		}
+		var body []byte
+		if r.Body != nil && isForm(r) {
+			max := int64(l.MaxFormBytes)
+			if max <= 0 {
+				max = defaultMaxFormBytes
+			}
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, max)); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					reject(w, r, http.StatusRequestEntityTooLarge, "form", fmt.Sprintf("form over %d bytes", max))
+					return
+				}
+				reject(w, r, http.StatusBadRequest, "form", err.Error())
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if err := r.ParseForm(); err != nil {
+			reject(w, r, http.StatusBadRequest, "form", err.Error())
+			return
+		}
+		if body != nil {
+			// ParseForm drained the body; handlers that decode it
+			// themselves get it back.
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		for name, max := range l.MaxParamLength {
+			// r.Form has the values of the body and of the query.
+			status := http.StatusRequestEntityTooLarge
+			if !anyLonger(r.PostForm[name], max) {
+				status = http.StatusRequestURITooLong
+			}
+			if anyLonger(r.Form[name], max) {
+				reject(w, r, status, "param_"+name, fmt.Sprintf("parameter '%s' over %d bytes", name, max))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isForm tells if the body of r is a url-encoded form, the only kind
+// ParseForm reads.
+func isForm(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+func anyLonger(values []string, max int) bool {
+	for _, value := range values {
+		if len(value) > max {
+			return true
+		}
+	}
+	return false
+}
+
+func headerBytes(header http.Header) int {
+	n := 0
+	for name, values := range header {
+		for _, value := range values {
+			n += len(name) + len(value) + len(": \r\n")
+		}
+	}
+	return n
+}
+
+func reject(w http.ResponseWriter, r *http.Request, status int, limit, reason string) {
+	rejections.Add(limit, 1)
+	if span := opentracing.SpanFromContext(r.Context()); span != nil {
+		span.SetTag("limit.rejected", limit)
+		span.LogKV("event", "request rejected", "reason", reason)
+	}
+	http.Error(w, http.StatusText(status)+": "+reason, status)
+}
@@ -0,0 +1,76 @@
+package limits
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var throttled = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "frontend",
+	Name:      "throttled_requests_total",
+	Help:      "Requests rejected with 429 Too Many Requests by the rate limiter, by endpoint.",
+}, []string{"endpoint"})
+
+// RateLimiter is a token bucket: it holds up to Burst tokens, refilled at
+// Rate tokens per second, and every request takes one.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter that starts full.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// take takes a token if there is one. Otherwise it returns how long until
+// there is.
+func (l *RateLimiter) take() (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(float64(l.Burst), l.tokens+now.Sub(l.last).Seconds()*l.Rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - l.tokens) / l.Rate * float64(time.Second))
+}
+
+// Handler rejects the requests to endpoint beyond the rate with 429 Too
+// Many Requests and a Retry-After header. Rejections are counted in the
+// frontend_throttled_requests_total metric and tagged on the request
+// span, so it must run inside the tracing middleware. A nil RateLimiter
+// lets every request through.
+func (l *RateLimiter) Handler(endpoint string, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.take()
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		throttled.WithLabelValues(endpoint).Inc()
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			span.SetTag("ratelimit.throttled", true)
+			span.LogKV("event", "request throttled", "rate", l.Rate, "burst", l.Burst, "retry_after", wait.String())
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/synthetic"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// loadgenResult is the outcome of one generated dispatch.
+type loadgenResult struct {
+	duration time.Duration
+	err      error
+}
+
+// loadgen fires dispatch requests at a frontend at a steady rate, each in
+// a trace of its own marked as synthetic, and prints a summary when done.
+// It stops after --duration, or on SIGINT or SIGTERM.
+func loadgen() error {
+	err := runLoadgen()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+	}
+	return err
+}
+
+// maxLoadgenRPS bounds --rps. Far beyond what one frontend serves, it
+// keeps the tick interval well above the 1ns below which time.NewTicker
+// panics.
+const maxLoadgenRPS = 10000
+
+func runLoadgen() error {
+	flags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	target := flags.String("url", "http://localhost:8080", "base URL of the frontend")
+	rps := flags.Float64("rps", 5, "dispatches started per second")
+	concurrency := flags.Int("concurrency", 10, "most dispatches in flight at once; ticks that find them all busy are skipped")
+	duration := flags.Duration("duration", time.Minute, "how long to generate load (0 runs until interrupted)")
+	customers := flags.String("customers", "123,392,731,567", "comma-separated customers to dispatch, in turn")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of each dispatch")
+	propagation := flags.String("propagation", tracing.PropagationJaeger, "trace context format the frontend expects, jaeger, w3c, b3 or b3-single")
+	token := flags.String("synthetic-token", os.Getenv("SYNTHETIC_TOKEN"), "SYNTHETIC_TOKEN of the frontend, without which it takes the dispatches as real traffic")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		return err
+	}
+	// NaN fails both comparisons, so it is rejected too.
+	if !(*rps > 0 && *rps <= maxLoadgenRPS) {
+		return fmt.Errorf("rps must be above 0 and at most %d, got %v", maxLoadgenRPS, *rps)
+	}
+	if *concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", *concurrency)
+	}
+	if *duration < 0 {
+		return fmt.Errorf("negative duration %s", *duration)
+	}
+	switch *propagation {
+	case tracing.PropagationJaeger, tracing.PropagationW3C, tracing.PropagationB3, tracing.PropagationB3Single:
+	default:
+		return fmt.Errorf("unknown propagation format %q", *propagation)
+	}
+	ids := strings.Split(*customers, ",")
+	for i := range ids {
+		if ids[i] = strings.TrimSpace(ids[i]); ids[i] == "" {
+			return fmt.Errorf("invalid customers %q", *customers)
+		}
+	}
+	dispatchURL := strings.TrimSuffix(*target, "/") + "/dispatch"
+
+	logger := log.NewFactory(zap.NewNop(), zapcore.InfoLevel)
+	tracer := tracing.Init("loadgen", tracing.WithLogger(logger), tracing.WithPropagation(*propagation))
+	if closer, ok := tracer.(io.Closer); ok {
+		defer closer.Close()
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if *token != "" {
+		transport = synthetic.Transport(*token, transport)
+	} else {
+		fmt.Println("no synthetic token: the frontend counts these dispatches as real traffic")
+	}
+	client := &tracing.HTTPClient{
+		Client: &http.Client{Transport: tracing.Transport(tracer, transport)},
+		Tracer: tracer,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	fmt.Printf("dispatching to %s at %v/s, at most %d at once, for %v\n", dispatchURL, *rps, *concurrency, *duration)
+	var (
+		lock    sync.Mutex
+		results []loadgenResult
+		skipped int
+		wg      sync.WaitGroup
+	)
+	slots := make(chan struct{}, *concurrency)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rps))
+	defer ticker.Stop()
+	start := time.Now()
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			printLoadgenSummary(results, skipped, time.Since(start))
+			return nil
+		case <-ticker.C:
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+		wg.Add(1)
+		go func(customer string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			// In-flight dispatches finish even when the run is over.
+			result := loadgenDispatch(context.Background(), tracer, client, dispatchURL, customer, *timeout)
+			lock.Lock()
+			results = append(results, result)
+			lock.Unlock()
+		}(ids[i%len(ids)])
+	}
+}
+
+// loadgenDispatch runs one dispatch for customer in a trace of its own. The
+// synthetic baggage item, with the synthetic token, makes the frontend tag
+// its spans synthetic=true and keep the dispatch out of the history of
+// real traffic.
+func loadgenDispatch(ctx context.Context, tracer opentracing.Tracer, client *tracing.HTTPClient, dispatchURL, customer string, timeout time.Duration) loadgenResult {
+	span := tracer.StartSpan("loadgen: dispatch")
+	defer span.Finish()
+	span.SetTag(synthetic.Tag, true)
+	span.SetTag("customer.id", customer)
+	span.SetBaggageItem(tracing.SyntheticBaggageKey, "true")
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var response struct{ Driver string }
+	err := client.GetJSON(ctx, "/dispatch", dispatchURL+"?"+url.Values{"customer": {customer}}.Encode(), &response)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+	}
+	return loadgenResult{duration: time.Since(start), err: err}
+}
+
+// printLoadgenSummary prints the counts, throughput and latency percentiles
+// of a run, and its errors, most frequent first.
+func printLoadgenSummary(results []loadgenResult, skipped int, elapsed time.Duration) {
+	var durations []time.Duration
+	errors := make(map[string]int)
+	for _, r := range results {
+		if r.err != nil {
+			errors[strings.TrimSpace(r.err.Error())]++
+			continue
+		}
+		durations = append(durations, r.duration)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	messages := make([]string, 0, len(errors))
+	for message := range errors {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool { return errors[messages[i]] > errors[messages[j]] })
+	percentile := func(p float64) time.Duration {
+		if len(durations) == 0 {
+			return 0
+		}
+		return durations[int(p*float64(len(durations)-1))].Round(time.Millisecond)
+	}
+
+	fmt.Printf("sent %d in %v (%.1f/s): %d ok, %d failed, %d skipped at the concurrency limit\n",
+		len(results), elapsed.Round(time.Millisecond), float64(len(results))/elapsed.Seconds(),
+		len(durations), len(results)-len(durations), skipped)
+	fmt.Printf("latency of ok dispatches: p50 %v, p95 %v, p99 %v, max %v\n",
+		percentile(0.5), percentile(0.95), percentile(0.99), percentile(1))
+	for _, message := range messages {
+		fmt.Printf("%6d × %s\n", errors[message], message)
+	}
+}
@@ -6,35 +6,63 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+	"github.com/superliuwr/jaeger-demo/frontend/requestid"
 )
 
+// LogLevelBaggageKey is the baggage item that, when set to "debug",
+// enables debug logging for a single request across all services.
+const LogLevelBaggageKey = "log-level"
+
 // Factory is the default logging wrapper that can create
 // logger instances either for a given Context or context-less.
 type Factory struct {
-	logger *zap.Logger
+	logger      *zap.Logger
+	debugLogger *zap.Logger
 }
 
-// NewFactory creates a new Factory.
-func NewFactory(logger *zap.Logger) Factory {
-	return Factory{logger: logger}
+// NewFactory creates a new Factory. Messages below level are dropped,
+// unless the request asks for debug logs via the log-level baggage item,
+// so logger itself should be built with debug level enabled.
+func NewFactory(logger *zap.Logger, level zapcore.LevelEnabler) Factory {
+	return Factory{
+		logger:      logger.WithOptions(zap.IncreaseLevel(level)),
+		debugLogger: logger,
+	}
 }
 
 // Bg creates a context-unaware logger.
 func (b Factory) Bg() Logger {
-	return logger(b)
+	return logger{logger: b.logger}
 }
 
 // For returns a context-aware Logger. If the context
 // contains an OpenTracing span, all logging calls are also
-// echo-ed into the span.
+// echo-ed into the span, and log lines carry its trace_id and span_id.
+// Log lines also carry the request_id and the user of the context, if any.
 func (b Factory) For(ctx context.Context) Logger {
+	var fields []zapcore.Field
+	if id := requestid.FromContext(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if user := auth.UserFromContext(ctx); user != "" {
+		fields = append(fields, zap.String("user", user))
+	}
 	if span := opentracing.SpanFromContext(ctx); span != nil {
-		return spanLogger{span: span, logger: b.logger}
+		logger := b.logger
+		if span.BaggageItem(LogLevelBaggageKey) == "debug" {
+			logger = b.debugLogger
+		}
+		return spanLogger{span: span, logger: logger.With(append(fields, traceFields(ctx, span)...)...)}
 	}
-	return b.Bg()
+	return logger{logger: b.logger.With(fields...)}
 }
 
 // With creates a child logger, and optionally adds some context fields to that logger.
 func (b Factory) With(fields ...zapcore.Field) Factory {
-	return Factory{logger: b.logger.With(fields...)}
+	return Factory{
+		logger:      b.logger.With(fields...),
+		debugLogger: b.debugLogger.With(fields...),
+	}
 }
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log formats accepted by New.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// New builds a zap logger writing to stderr in the given format: console
+// for human-readable lines, or json for one structured object per line.
+// It logs at debug level, for use with NewFactory.
+func New(format string, options ...zap.Option) (*zap.Logger, error) {
+	switch format {
+	case FormatConsole:
+		return zap.NewDevelopment(options...)
+	case FormatJSON:
+		config := zap.NewProductionConfig()
+		config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		config.Sampling = nil
+		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		return config.Build(options...)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// traceFields returns the trace_id and span_id of span, with either the
+// Jaeger or the OpenTelemetry tracer, so log lines can be matched with spans.
+func traceFields(ctx context.Context, span opentracing.Span) []zapcore.Field {
+	if sc, ok := span.Context().(jaeger.SpanContext); ok {
+		return []zapcore.Field{zap.Stringer("trace_id", sc.TraceID()), zap.Stringer("span_id", sc.SpanID())}
+	}
+	// Spans from the OpenTelemetry pipeline carry their context here.
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return []zapcore.Field{zap.Stringer("trace_id", sc.TraceID()), zap.Stringer("span_id", sc.SpanID())}
+	}
+	return nil
+}
@@ -1,19 +1,54 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"net"
 	"os"
 	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/superliuwr/jaeger-demo/frontend/admin"
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+	"github.com/superliuwr/jaeger-demo/frontend/chaos"
+	"github.com/superliuwr/jaeger-demo/frontend/clients"
+	"github.com/superliuwr/jaeger-demo/frontend/cors"
+	"github.com/superliuwr/jaeger-demo/frontend/cost"
+	"github.com/superliuwr/jaeger-demo/frontend/incidents"
+	"github.com/superliuwr/jaeger-demo/frontend/limits"
 	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/mtls"
+	"github.com/superliuwr/jaeger-demo/frontend/requestid"
+	"github.com/superliuwr/jaeger-demo/frontend/resources"
+	"github.com/superliuwr/jaeger-demo/frontend/schema"
+	"github.com/superliuwr/jaeger-demo/frontend/synthetic"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
 	"github.com/superliuwr/jaeger-demo/frontend/tracing"
 )
 
 func main() {
-	if err := execute(); err != nil {
+	run := execute
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			run = bench
+		case "selftest":
+			run = selftest
+		case "loadgen":
+			run = loadgen
+		case "all":
+			run = all
+		case "apigen":
+			run = apigen
+		}
+	}
+
+	if err := run(); err != nil {
 		os.Exit(-1)
 	}
 }
@@ -21,28 +56,347 @@ func main() {
 func execute() error {
 	var options ConfigOptions
 
+	flag.BoolVar(&options.MockBackends, "mock-backends", false, "return a canned route instead of calling the route service")
+	flag.BoolVar(&options.LocalAssets, "local-assets", false, "serve the web UI from ui/web_assets and reload pages when it changes")
+	flag.BoolVar(&options.SPAFallback, "spa-fallback", false, "serve the index page for unknown non-API paths, for client-side routes")
+	flag.StringVar(&options.RouteTransport, "route-transport", "http", "transport to the route service, http or grpc")
+	flag.DurationVar(&options.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "how long to drain in-flight requests on SIGINT or SIGTERM")
+	pprof := flag.Bool("pprof", false, "serve net/http/pprof profiles on --pprof-address")
+	pprofHostPort := flag.String("pprof-address", "0.0.0.0:6060", "address of the pprof endpoints")
+	logFormat := flag.String("log-format", log.FormatConsole, "log format, console or json")
+	tracer := flag.String("tracer", "jaeger", "tracing pipeline, jaeger or otel")
+	propagation := flag.String("propagation", tracing.PropagationJaeger, "trace context format, jaeger, w3c, b3 or b3-single")
+	samplerType := flag.String("sampler-type", "const", "sampler of the jaeger tracer, const or remote")
+	samplingServerURL := flag.String("sampling-server-url", "http://jaeger:5778/sampling", "where the remote sampler fetches sampling strategies")
+	samplingRefresh := flag.Duration("sampling-refresh-interval", time.Minute, "how often the remote sampler fetches sampling strategies")
+	flag.Float64Var(&chaos.ErrorRate, "error-rate", 0, "fraction (0..1) of dispatches to fail with 500 Internal Server Error")
+	injectDelay := flag.String("inject-delay", "", "artificial latency of the calls to each service, e.g. route=300ms±100ms,driver=50ms")
+	flag.DurationVar(&options.DriverGRPC.KeepaliveTime, "driver-keepalive-time", 0, "ping the driver service after the connection is idle this long (0 disables pings)")
+	flag.DurationVar(&options.DriverGRPC.KeepaliveTimeout, "driver-keepalive-timeout", 20*time.Second, "close the connection to the driver service if a ping is not answered within this long")
+	flag.DurationVar(&options.DriverGRPC.BackoffBaseDelay, "driver-backoff-base-delay", time.Second, "wait before the first attempt to reconnect to the driver service")
+	flag.DurationVar(&options.DriverGRPC.BackoffMaxDelay, "driver-backoff-max-delay", 2*time.Minute, "longest wait between attempts to reconnect to the driver service")
+	flag.Float64Var(&options.DispatchRate, "dispatch-rate-limit", 0, "dispatches allowed per second, beyond which they are rejected with 429 Too Many Requests (0 disables the limit)")
+	flag.IntVar(&options.DispatchBurst, "dispatch-burst", 10, "dispatches allowed at once above --dispatch-rate-limit")
+	corsOrigins := flag.String("cors-allowed-origins", "", "comma-separated origins allowed to call the API from a browser, or * for any (empty disables CORS)")
+	corsMethods := flag.String("cors-allowed-methods", "GET,POST,PUT,DELETE", "comma-separated methods allowed from other origins")
+	corsHeaders := flag.String("cors-allowed-headers", "Content-Type,jaeger-baggage,baggage,traceparent,jaeger-debug-id,X-Request-Id,Authorization", "comma-separated request headers allowed from other origins")
+	flag.StringVar(&options.TLSCert, "tls-cert", "", "PEM certificate file to serve HTTPS with, together with --tls-key")
+	flag.StringVar(&options.TLSKey, "tls-key", "", "PEM private key file of --tls-cert")
+	var backendTLS mtls.Config
+	flag.StringVar(&backendTLS.Cert, "mtls-cert", "", "PEM client certificate file to present to the customer and route services, together with --mtls-key")
+	flag.StringVar(&backendTLS.Key, "mtls-key", "", "PEM private key file of --mtls-cert")
+	flag.StringVar(&backendTLS.CA, "mtls-ca", "", "PEM bundle of the CAs to verify the customer and route services against (empty uses the system roots)")
+	serverSANs := flag.String("mtls-server-sans", "", "comma-separated SANs the certificates of the customer and route services must have one of, instead of their host name, e.g. spiffe://hotrod/route")
+	flag.DurationVar(&options.CORS.MaxAge, "cors-max-age", 10*time.Minute, "how long browsers may cache the answer to a CORS preflight request")
+	requestTimeouts := map[string]*time.Duration{}
+	for _, client := range []string{"customer", "driver", "route", "weather"} {
+		requestTimeouts[client] = flag.Duration(client+"-timeout", 0, "timeout of each call to the "+client+" service, overriding TIMEOUTS_CONFIG (0 keeps the configured one)")
+	}
+	flag.Parse()
+
+	rootLogger, err := log.New(*logFormat,
+		zap.AddStacktrace(zapcore.FatalLevel),
+		zap.AddCallerSkip(1),
+	)
+	if err != nil {
+		// There is no logger to report this with yet.
+		fmt.Fprintln(os.Stderr, "frontend:", err)
+		return err
+	}
+	appLogger := rootLogger.With(zap.String("service", "frontend"))
+	logLevel := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	options.LogLevel = &logLevel
+	loggerFactory := log.NewFactory(appLogger, logLevel)
+
+	if options.RouteTransport != "http" && options.RouteTransport != "grpc" {
+		return logError(appLogger, fmt.Errorf("unknown route transport %q", options.RouteTransport))
+	}
+	if (options.TLSCert == "") != (options.TLSKey == "") {
+		return logError(appLogger, fmt.Errorf("--tls-cert and --tls-key must be set together"))
+	}
+	if options.TLSCert != "" {
+		// Fail at startup rather than when the server starts listening.
+		if _, err := tls.LoadX509KeyPair(options.TLSCert, options.TLSKey); err != nil {
+			return logError(appLogger, fmt.Errorf("cannot load TLS certificate: %w", err))
+		}
+	}
+	backendTLS.ServerSANs = cors.ParseList(*serverSANs)
+	if len(backendTLS.ServerSANs) > 0 && !backendTLS.Enabled() {
+		return logError(appLogger, fmt.Errorf("--mtls-server-sans needs --mtls-ca or --mtls-cert"))
+	}
+	if backendTLS.Enabled() && localBackends {
+		return logError(appLogger, fmt.Errorf("the stand-ins of all serve plain HTTP, so --mtls-cert and --mtls-ca cannot be used with it"))
+	}
+	if err := mtls.Configure(backendTLS); err != nil {
+		return logError(appLogger, fmt.Errorf("cannot configure mTLS to the backends: %w", err))
+	}
+	switch *propagation {
+	case tracing.PropagationJaeger, tracing.PropagationW3C, tracing.PropagationB3, tracing.PropagationB3Single:
+	default:
+		return logError(appLogger, fmt.Errorf("unknown propagation format %q", *propagation))
+	}
+	initTracer := tracing.Init
+	switch *tracer {
+	case "jaeger":
+	case "otel":
+		initTracer = tracing.InitOTel
+	default:
+		return logError(appLogger, fmt.Errorf("unknown tracer %q", *tracer))
+	}
+	switch *samplerType {
+	case "const", "remote":
+	default:
+		return logError(appLogger, fmt.Errorf("unknown sampler type %q", *samplerType))
+	}
+	if *samplingRefresh <= 0 {
+		return logError(appLogger, fmt.Errorf("sampling refresh interval must be positive, got %s", *samplingRefresh))
+	}
+
+	for client, timeout := range requestTimeouts {
+		if *timeout < 0 {
+			return logError(appLogger, fmt.Errorf("negative %s timeout %s", client, *timeout))
+		}
+		if *timeout > 0 {
+			timeouts.SetRequestTimeout(client, *timeout)
+		}
+	}
+
 	options.FrontendHostPort = net.JoinHostPort("0.0.0.0", strconv.Itoa(8080))
 	options.DriverHostPort = net.JoinHostPort("driver", strconv.Itoa(8081))
 	options.CustomerHostPort = net.JoinHostPort("customer", strconv.Itoa(8082))
 	options.RouteHostPort = net.JoinHostPort("route", strconv.Itoa(8083))
+	if hostPort := os.Getenv("ROUTE_HOST_PORT"); hostPort != "" {
+		options.RouteHostPort = hostPort
+	}
+	options.RouteGRPCHostPort = net.JoinHostPort("route", strconv.Itoa(8086))
 	options.BasePath = `/`
+	options.DepGraphWindow = 5 * time.Minute
+	options.DispatchHistory = 10000
+	options.FieldEncryptionKey = os.Getenv("FIELD_ENCRYPTION_KEY")
+	options.OutboxURL = os.Getenv("OUTBOX_WEBHOOK_URL")
+	if *pprof {
+		options.PprofHostPort = *pprofHostPort
+	}
+	options.Limits = limits.Limits{
+		MaxHeaderBytes: 8 << 10,
+		MaxQueryLength: 1024,
+		MaxFormBytes:   8 << 10,
+		MaxParamLength: map[string]int{"customer": 64, "journey": 64, "driver": 64},
+	}
 
-	rootLogger, _ := zap.NewDevelopment(
-		zap.AddStacktrace(zapcore.FatalLevel),
-		zap.AddCallerSkip(1),
-	)
-	appLogger := rootLogger.With(zap.String("service", "frontend"))
-	loggerFactory := log.NewFactory(appLogger)
+	effective, err := resources.Apply()
+	if err != nil {
+		return logError(appLogger, err)
+	}
+	appLogger.Info("Runtime resources", zap.Int("gomaxprocs", effective.GOMAXPROCS), zap.Int64("memlimit", effective.MemoryLimit))
 
-	server := NewServer(
-		options,
-		tracing.Init("frontend", loggerFactory),
-		loggerFactory,
-	)
+	if backendTLS.Enabled() {
+		appLogger.Info("Calling the customer and route services over TLS", zap.Bool("client_certificate", backendTLS.Cert != ""),
+			zap.Strings("server_sans", backendTLS.ServerSANs))
+	}
+	if path := os.Getenv("TIMEOUTS_CONFIG"); path != "" {
+		if err := timeouts.Watch(path, 5*time.Second, loggerFactory.Bg()); err != nil {
+			return logError(appLogger, err)
+		}
+		appLogger.Info("Loaded timeouts", zap.String("path", path))
+	}
+
+	if slos := os.Getenv("TIMEOUT_SLOS"); slos != "" {
+		parsed, err := timeouts.ParseSLOs(slos)
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		interval, err := time.ParseDuration(getenv("TIMEOUT_ADAPT_INTERVAL", "30s"))
+		if err != nil || interval <= 0 {
+			return logError(appLogger, fmt.Errorf("invalid TIMEOUT_ADAPT_INTERVAL %q", os.Getenv("TIMEOUT_ADAPT_INTERVAL")))
+		}
+		timeouts.EnableAdaptive(parsed)
+		go timeouts.Adapt(context.Background(), interval, loggerFactory.With(zap.String("component", "timeouts")).Bg())
+		appLogger.Info("Deriving request timeouts from SLOs", zap.String("slos", slos), zap.Duration("interval", interval))
+	}
+
+	if audit := os.Getenv("BAGGAGE_AUDIT"); audit != "" {
+		if options.BaggageAudit, err = strconv.ParseBool(audit); err != nil {
+			return logError(appLogger, fmt.Errorf("invalid BAGGAGE_AUDIT %q", audit))
+		}
+	}
+
+	options.Weather = clients.WeatherConfig{
+		Mode: getenv("WEATHER_MODE", clients.WeatherOff),
+		URL:  getenv("WEATHER_API_URL", clients.DefaultWeatherURL),
+		Stub: getenv("WEATHER_STUB", "rain"),
+	}
+	switch options.Weather.Mode {
+	case clients.WeatherOff, clients.WeatherStub, clients.WeatherLive:
+	default:
+		return logError(appLogger, fmt.Errorf("unknown WEATHER_MODE %q, want off, stub or live", options.Weather.Mode))
+	}
+	if _, ok := clients.WeatherConditions[options.Weather.Stub]; !ok {
+		return logError(appLogger, fmt.Errorf("unknown WEATHER_STUB condition %q", options.Weather.Stub))
+	}
+	if options.Weather.Latitude, options.Weather.Longitude, err = clients.ParseWeatherLocation(getenv("WEATHER_LOCATION", "-33.87,151.21")); err != nil {
+		return logError(appLogger, err)
+	}
+	if options.Weather.CacheTTL, err = time.ParseDuration(getenv("WEATHER_CACHE_TTL", "10m")); err != nil || options.Weather.CacheTTL < 0 {
+		return logError(appLogger, fmt.Errorf("invalid WEATHER_CACHE_TTL %q", os.Getenv("WEATHER_CACHE_TTL")))
+	}
+	if options.Weather.Mode != clients.WeatherOff {
+		appLogger.Info("Adjusting ETAs for the weather", zap.String("mode", options.Weather.Mode), zap.String("stub", options.Weather.Stub))
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		ttl, err := time.ParseDuration(getenv("JWT_TTL", "1h"))
+		if err != nil || ttl <= 0 {
+			return logError(appLogger, fmt.Errorf("invalid JWT_TTL %q", os.Getenv("JWT_TTL")))
+		}
+		options.Auth = auth.New(secret, ttl)
+		// The services downstream get the user of a request as baggage.
+		// The frontend clears it on requests without a valid token.
+		tracing.DefaultAllowedBaggageKeys = append(tracing.DefaultAllowedBaggageKeys, auth.BaggageKey)
+		appLogger.Info("Authenticating requests with JWTs", zap.Duration("ttl", ttl))
+	}
+
+	if dir := os.Getenv("RESPONSE_SCHEMAS"); dir != "" {
+		clients, err := schema.Load(dir)
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		appLogger.Info("Validating responses against schemas", zap.String("dir", dir), zap.Strings("clients", clients))
+	}
+
+	if rate := os.Getenv("CHAOS_CORRUPT_RESPONSE_RATE"); rate != "" {
+		corruptRate, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		chaos.SetCorruptResponseRate(corruptRate)
+		appLogger.Info("Corrupting downstream responses", zap.Float64("rate", corruptRate))
+		incidents.Record(incidents.ChaosActivated, fmt.Sprintf("corrupting %g%% of downstream responses", corruptRate*100))
+	}
+
+	if options.DispatchRate < 0 {
+		return logError(appLogger, fmt.Errorf("negative --dispatch-rate-limit %g", options.DispatchRate))
+	}
+	if options.DispatchRate > 0 {
+		if options.DispatchBurst < 1 {
+			return logError(appLogger, fmt.Errorf("--dispatch-burst must be at least 1, got %d", options.DispatchBurst))
+		}
+		appLogger.Info("Limiting dispatches", zap.Float64("rate", options.DispatchRate), zap.Int("burst", options.DispatchBurst))
+	}
+
+	options.CORS.AllowedOrigins = cors.ParseList(*corsOrigins)
+	options.CORS.AllowedMethods = cors.ParseList(*corsMethods)
+	options.CORS.AllowedHeaders = cors.ParseList(*corsHeaders)
+	// Let pages of other origins read the trace of their requests.
+	options.CORS.ExposedHeaders = []string{tracing.TraceIDHeader, "traceresponse", requestid.Header, "Retry-After"}
+	if options.CORS.Enabled() {
+		appLogger.Info("Allowing cross-origin requests", zap.Strings("origins", options.CORS.AllowedOrigins))
+	}
+
+	if chaos.ErrorRate < 0 || chaos.ErrorRate > 1 {
+		return logError(appLogger, fmt.Errorf("--error-rate must be between 0 and 1, got %g", chaos.ErrorRate))
+	}
+	if chaos.ErrorRate > 0 {
+		appLogger.Info("Failing dispatches", zap.Float64("rate", chaos.ErrorRate))
+		incidents.Record(incidents.ChaosActivated, fmt.Sprintf("failing %g%% of dispatches", chaos.ErrorRate*100))
+	}
+
+	if chaos.Delays, err = chaos.ParseDelays(*injectDelay); err != nil {
+		return logError(appLogger, err)
+	}
+	if len(chaos.Delays) > 0 {
+		delays := chaos.FormatDelays(chaos.Delays)
+		appLogger.Info("Delaying downstream calls", zap.String("delays", delays))
+		incidents.Record(incidents.ChaosActivated, "delaying downstream calls: "+delays)
+	}
+
+	if calendar := os.Getenv("CHAOS_CALENDAR"); calendar != "" {
+		rules, err := chaos.ParseCalendar(calendar)
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		clock, err := chaosClock(os.Getenv("CHAOS_CLOCK_START"), getenv("CHAOS_CLOCK_SPEED", "1"))
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		options.ChaosCalendar = chaos.NewCalendar(rules, clock, loggerFactory.With(zap.String("component", "chaos")))
+		appLogger.Info("Scheduling chaos", zap.Int("rules", len(rules)), zap.Time("simulated_start", clock.Start), zap.Float64("speed", clock.Speed))
+	}
+
+	if interval := os.Getenv("SYNTHETIC_INTERVAL"); interval != "" {
+		if options.SyntheticInterval, err = time.ParseDuration(interval); err != nil {
+			return logError(appLogger, err)
+		}
+		options.SyntheticCustomer = getenv("SYNTHETIC_CUSTOMER", "synthetic-canary")
+		appLogger.Info("Running canary dispatches", zap.Duration("interval", options.SyntheticInterval), zap.String("customer", options.SyntheticCustomer))
+	}
+	if options.SyntheticToken = os.Getenv("SYNTHETIC_TOKEN"); options.SyntheticToken == "" {
+		options.SyntheticToken = synthetic.NewToken()
+	}
+
+	if options.CustomerTiers, err = cost.ParseTiers(os.Getenv("CUSTOMER_TIERS")); err != nil {
+		return logError(appLogger, err)
+	}
+
+	if options.Admin.Tokens, err = admin.ParseTokens(os.Getenv("ADMIN_TOKENS")); err != nil {
+		return logError(appLogger, err)
+	}
+	if options.Admin.Networks, err = admin.ParseNetworks(os.Getenv("ADMIN_NETWORKS")); err != nil {
+		return logError(appLogger, err)
+	}
+	if options.Admin.Enabled() {
+		appLogger.Info("Restricting admin endpoints", zap.Int("tokens", len(options.Admin.Tokens)), zap.Int("networks", len(options.Admin.Networks)))
+	}
+
+	if latency := os.Getenv("SLO_DISPATCH_LATENCY"); latency != "" {
+		objective, err := time.ParseDuration(latency)
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		incidents.SetObjective(incidents.Objective{Latency: objective})
+	}
+
+	tracerOptions := []tracing.Option{
+		tracing.WithLogger(loggerFactory),
+		tracing.WithPropagation(*propagation),
+		tracing.WithTags(effective.Tags()...),
+		tracing.WithObserver(cost.NewObserver()),
+	}
+	if *samplerType == "remote" {
+		tracerOptions = append(tracerOptions, tracing.WithRemoteSampling(*samplingServerURL, *samplingRefresh))
+	}
+	if localBackends {
+		stop, err := startLocalBackends(&options, initTracer, loggerFactory.With(zap.String("component", "stand-in")),
+			tracing.WithLogger(loggerFactory), tracing.WithPropagation(*propagation))
+		if err != nil {
+			return logError(appLogger, err)
+		}
+		defer stop()
+	}
+	server := NewServer(options, initTracer("frontend", tracerOptions...), loggerFactory)
 
 	return logError(appLogger, server.Run())
 }
 
+// chaosClock creates the simulated clock of the chaos calendar. It starts
+// at start, "2006-01-02T15:04" in local time or RFC 3339, or now if start
+// is empty, and runs speed times as fast as real time.
+func chaosClock(start, speed string) (*chaos.Clock, error) {
+	factor, err := strconv.ParseFloat(speed, 64)
+	if err != nil || factor <= 0 {
+		return nil, fmt.Errorf("CHAOS_CLOCK_SPEED must be a positive number, got %q", speed)
+	}
+	t := time.Now()
+	if start != "" {
+		if t, err = time.ParseInLocation("2006-01-02T15:04", start, time.Local); err != nil {
+			if t, err = time.Parse(time.RFC3339, start); err != nil {
+				return nil, fmt.Errorf("CHAOS_CLOCK_START must be 2006-01-02T15:04 or RFC 3339, got %q", start)
+			}
+		}
+	}
+	return chaos.NewClock(t, factor), nil
+}
+
 func logError(logger *zap.Logger, err error) error {
 	if err != nil {
 		logger.Error("Error running command", zap.Error(err))
@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+)
+
+// Asset is one file produced by the UI bundler.
+type Asset struct {
+	// Path is the URL path of the file, relative to the base path.
+	Path string
+	// EntryPoint is the source file the bundle was built from, if any.
+	EntryPoint string `json:",omitempty"`
+	Bytes      int64
+	// Integrity is the Subresource Integrity hash of the embedded file.
+	Integrity string
+}
+
+// Manifest lists the bundled files embedded in the binary.
+type Manifest struct {
+	Assets []Asset
+
+	byPath  map[string]Asset
+	byEntry map[string]Asset
+}
+
+// metafile is the part of the esbuild --metafile output we use.
+type metafile struct {
+	Outputs map[string]struct {
+		Bytes      int64  `json:"bytes"`
+		EntryPoint string `json:"entryPoint"`
+	} `json:"outputs"`
+}
+
+// Empty returns a manifest without assets, for builds without a bundle.
+func Empty() *Manifest {
+	return &Manifest{byPath: map[string]Asset{}, byEntry: map[string]Asset{}}
+}
+
+// Load reads the esbuild metafile at name from fs and builds a manifest of
+// its outputs. Output paths in the metafile are taken as relative to the
+// root of fs. It fails if an output is missing from fs or its size differs
+// from the metafile, which means the assets were embedded from a stale build.
+func Load(fs http.FileSystem, name string) (*Manifest, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var meta metafile
+	if err := json.NewDecoder(f).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", name, err)
+	}
+
+	m := Empty()
+	for output, info := range meta.Outputs {
+		asset := Asset{
+			Path:       path.Clean("/" + output),
+			EntryPoint: info.EntryPoint,
+			Bytes:      info.Bytes,
+		}
+		data, err := readFile(fs, asset.Path)
+		if err != nil {
+			return nil, fmt.Errorf("bundled file %s: %v", asset.Path, err)
+		}
+		if int64(len(data)) != asset.Bytes {
+			return nil, fmt.Errorf("bundled file %s is %d bytes, %s says %d: re-run go generate", asset.Path, len(data), name, asset.Bytes)
+		}
+		sum := sha256.Sum256(data)
+		asset.Integrity = "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+
+		m.Assets = append(m.Assets, asset)
+		m.byPath[asset.Path] = asset
+		if asset.EntryPoint != "" {
+			m.byEntry[asset.EntryPoint] = asset
+		}
+	}
+	sort.Slice(m.Assets, func(i, j int) bool { return m.Assets[i].Path < m.Assets[j].Path })
+
+	return m, nil
+}
+
+func readFile(fs http.FileSystem, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// Lookup returns the bundled file built from the given entry point, e.g. "src/app.js".
+func (m *Manifest) Lookup(entryPoint string) (Asset, bool) {
+	asset, ok := m.byEntry[entryPoint]
+	return asset, ok
+}
+
+// Immutable marks bundled files as cacheable forever, since their names
+// change whenever their content does. Request paths must already have the
+// base path stripped.
+func (m *Manifest) Immutable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := m.byPath[path.Clean("/"+r.URL.Path)]; ok {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP renders the manifest as JSON.
+func (m *Manifest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"expvar"
+
+	"github.com/superliuwr/jaeger-demo/frontend/cardinality"
+)
+
+var (
+	// dispatchesByCustomer counts dispatches per customer ID.
+	dispatchesByCustomer = expvar.NewMap("dispatches_by_customer")
+	customerLabel        = cardinality.New("customer", 100, 10)
+
+	// routeCallsByPair counts route calls per "pickup->dropoff" pair.
+	routeCallsByPair = expvar.NewMap("route_calls_by_pair")
+	routePairLabel   = cardinality.New("route_pair", 100, 10)
+)
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/dispatchlog"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// Message is what the relay posts to the webhook for every event. ID
+// stays the same across redeliveries, so receivers can drop duplicates.
+type Message struct {
+	ID       uint64
+	Type     string
+	Dispatch dispatchlog.Record
+}
+
+// Relay publishes the outbox events of a dispatch log to a webhook. Events
+// stay in the outbox until the webhook accepts them, so each is delivered
+// at least once.
+type Relay struct {
+	tracer opentracing.Tracer
+	logger log.Factory
+	client *tracing.HTTPClient
+	source *dispatchlog.Log
+	url    string
+}
+
+// NewRelay creates a Relay posting the events of source to url.
+func NewRelay(tracer opentracing.Tracer, logger log.Factory, source *dispatchlog.Log, url string) *Relay {
+	return &Relay{
+		tracer: tracer,
+		logger: logger,
+		client: &tracing.HTTPClient{
+			Client: &http.Client{Transport: tracing.Transport(tracer, red.Transport("outbox", http.DefaultTransport)), Timeout: 5 * time.Second},
+			Tracer: tracer,
+			Retry:  tracing.DefaultRetryPolicy,
+		},
+		source: source,
+		url:    url,
+	}
+}
+
+// Run publishes the pending events every interval, until ctx is done.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, event := range r.source.Pending() {
+				r.publish(ctx, event)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publish posts one event in a span that follows from the dispatch that
+// wrote it, so the delivery shows up as a separate trace linked to it.
+func (r *Relay) publish(ctx context.Context, event dispatchlog.Event) {
+	var opts []opentracing.StartSpanOption
+	if event.Span != nil {
+		opts = append(opts, opentracing.FollowsFrom(event.Span))
+	}
+	span := r.tracer.StartSpan("outbox: publish", opts...)
+	defer span.Finish()
+	span.SetTag("outbox.event_id", strconv.FormatUint(event.ID, 10))
+	span.SetTag("outbox.delivery", event.Attempts+1)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	message := Message{ID: event.ID, Type: "dispatch.completed", Dispatch: event.Record}
+	if err := r.client.PostJSON(ctx, "/webhook", r.url, message); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		r.logger.For(ctx).Error("Cannot publish outbox event", zap.Uint64("event", event.ID), zap.Int("attempts", event.Attempts+1), zap.Error(err))
+		r.source.Failed(event.ID)
+		return
+	}
+	r.source.Published(event.ID)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"go.uber.org/zap"
+)
+
+// pprofServer serves the net/http/pprof handlers on hostPort. They get a
+// listener of their own, so profiles are neither traced nor exposed with
+// the frontend API.
+func (s *Server) pprofServer(hostPort string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	server := &http.Server{Addr: hostPort, Handler: mux}
+	go func() {
+		s.logger.Bg().Info("Serving pprof", zap.String("address", "http://"+hostPort+"/debug/pprof/"))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Bg().Error("Cannot serve pprof", zap.Error(err))
+		}
+	}()
+	return server
+}
@@ -0,0 +1,53 @@
+//go:build !lite
+// +build !lite
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	scriptTag     = regexp.MustCompile(`<script[^>]*\ssrc="([^"]+)"[^>]*>`)
+	stylesheetTag = regexp.MustCompile(`<link[^>]*\srel="stylesheet"[^>]*>`)
+	hrefAttr      = regexp.MustCompile(`\shref="([^"]+)"`)
+)
+
+// preloadLinks finds the scripts and stylesheets the page depends on and
+// returns them as Link header values with rel=preload.
+func preloadLinks(page string) []string {
+	var links []string
+	for _, m := range scriptTag.FindAllStringSubmatch(page, -1) {
+		links = append(links, preloadLink(m[1], "script", m[0]))
+	}
+	for _, tag := range stylesheetTag.FindAllString(page, -1) {
+		if m := hrefAttr.FindStringSubmatch(tag); m != nil {
+			links = append(links, preloadLink(m[1], "style", tag))
+		}
+	}
+	return links
+}
+
+func preloadLink(url, as, tag string) string {
+	link := fmt.Sprintf("<%s>; rel=preload; as=%s", url, as)
+	if strings.Contains(tag, `crossorigin="anonymous"`) {
+		link += "; crossorigin=anonymous"
+	}
+	return link
+}
+
+// withPreloadLinks adds the Link header to responses for the index page, so
+// browsers start fetching critical assets before parsing the HTML.
+func withPreloadLinks(links []string, next http.Handler) http.Handler {
+	header := strings.Join(links, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := path.Clean("/" + r.URL.Path); header != "" && (p == "/" || p == "/index.html") {
+			w.Header().Set("Link", header)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
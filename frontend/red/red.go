@@ -0,0 +1,138 @@
+package red
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+var (
+	serverRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frontend",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	serverErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frontend",
+		Name:      "http_request_errors_total",
+		Help:      "HTTP requests answered with a 5xx status, by route.",
+	}, []string{"route"})
+	serverDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "frontend",
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests served, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	clientRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frontend",
+		Name:      "client_requests_total",
+		Help:      "Calls to downstream services, by client and operation.",
+	}, []string{"client", "operation"})
+	clientErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frontend",
+		Name:      "client_request_errors_total",
+		Help:      "Failed calls to downstream services, by client and operation.",
+	}, []string{"client", "operation"})
+	clientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "frontend",
+		Name:      "client_request_duration_seconds",
+		Help:      "Latency of calls to downstream services, by client and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"client", "operation"})
+)
+
+// Handler serves the metrics in the Prometheus text format, or in
+// OpenMetrics with trace exemplars if the scraper asks for it.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Middleware records the rate, errors and duration of the requests served
+// by next under the route it is registered with. It must run inside the
+// tracing middleware, so latencies can carry the trace ID as an exemplar.
+func Middleware(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		serverRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Inc()
+		if sw.status >= http.StatusInternalServerError {
+			serverErrors.WithLabelValues(route).Inc()
+		}
+		observe(r.Context(), serverDuration.WithLabelValues(route), time.Since(start))
+	})
+}
+
+// Transport records the calls made through next as operations of the
+// named client. Transport errors and 5xx responses count as errors.
+func Transport(client string, next http.RoundTripper) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := next.RoundTrip(req)
+		recordCall(req.Context(), client, req.URL.Path, time.Since(start), err != nil || res.StatusCode >= http.StatusInternalServerError)
+		return res, err
+	})
+}
+
+// UnaryClientInterceptor records the gRPC calls of the named client, with
+// the full method name as the operation.
+func UnaryClientInterceptor(client string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		recordCall(ctx, client, method, time.Since(start), err != nil)
+		return err
+	}
+}
+
+func recordCall(ctx context.Context, client, operation string, duration time.Duration, failed bool) {
+	clientRequests.WithLabelValues(client, operation).Inc()
+	if failed {
+		clientErrors.WithLabelValues(client, operation).Inc()
+	}
+	observe(ctx, clientDuration.WithLabelValues(client, operation), duration)
+}
+
+// observe records duration, with the trace ID of ctx as an exemplar so the
+// metric links to a trace in Jaeger.
+func observe(ctx context.Context, observer prometheus.Observer, duration time.Duration) {
+	if exemplar, ok := observer.(prometheus.ExemplarObserver); ok {
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			exemplar.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": traceID})
+			return
+		}
+	}
+	observer.Observe(duration.Seconds())
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the wrapped writer, for
+// handlers that stream their response.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/superliuwr/jaeger-demo/frontend/dispatchlog"
+	"github.com/superliuwr/jaeger-demo/frontend/httperr"
+	"github.com/superliuwr/jaeger-demo/frontend/jobs"
+)
+
+// A report aggregates reportChunk dispatches at a time, logging an event on
+// the job span after each chunk. reportChunkDelay stands in for the I/O of
+// a real report, so jobs take long enough to poll.
+const (
+	reportChunk      = 100
+	reportChunkDelay = 20 * time.Millisecond
+)
+
+// Report aggregates the dispatch history from the start of the window, or
+// the oldest dispatch kept, to the time the report started.
+type Report struct {
+	From       time.Time
+	To         time.Time
+	Dispatches int
+	Drivers    int
+	MeanETA    time.Duration
+	// Customers are sorted by number of dispatches, most first.
+	Customers []CustomerReport
+}
+
+// CustomerReport aggregates the dispatches of one customer.
+type CustomerReport struct {
+	Customer   string
+	Dispatches int
+	MeanETA    time.Duration
+}
+
+// report starts a report job over the dispatch history and answers
+// 202 Accepted with the job, to be polled at its Location. The optional
+// window form value, e.g. 1h, limits the report to recent dispatches.
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); httperr.HandleError(w, err, http.StatusBadRequest) {
+		return
+	}
+	var since time.Time
+	if v := r.Form.Get("window"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			http.Error(w, "invalid window "+v, http.StatusBadRequest)
+			return
+		}
+		since = time.Now().Add(-window)
+	}
+
+	records := s.history.Snapshot()
+	job := s.jobs.Submit(r.Context(), "report", func(ctx context.Context) (interface{}, error) {
+		return buildReport(ctx, records, since)
+	})
+	w.Header().Set("Location", path.Join("/", s.basePath, "/api/v1/jobs", job.ID))
+	jobs.Write(w, http.StatusAccepted, job)
+}
+
+// buildReport aggregates the records since the given time.
+func buildReport(ctx context.Context, records []dispatchlog.Record, since time.Time) (*Report, error) {
+	span := opentracing.SpanFromContext(ctx)
+	report := &Report{From: since, To: time.Now()}
+	if since.IsZero() && len(records) > 0 {
+		report.From = records[0].Time
+	}
+	customers := make(map[string]*CustomerReport)
+	drivers := make(map[string]bool)
+	var eta time.Duration
+
+	for start := 0; start < len(records); start += reportChunk {
+		select {
+		case <-time.After(reportChunkDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		end := start + reportChunk
+		if end > len(records) {
+			end = len(records)
+		}
+		for _, record := range records[start:end] {
+			if record.Time.Before(since) {
+				continue
+			}
+			report.Dispatches++
+			eta += time.Duration(record.ETA)
+			drivers[record.Driver] = true
+			c, ok := customers[record.Customer]
+			if !ok {
+				c = &CustomerReport{Customer: record.Customer}
+				customers[record.Customer] = c
+			}
+			c.Dispatches++
+			c.MeanETA += time.Duration(record.ETA)
+		}
+		if span != nil {
+			span.LogFields(otlog.String("event", "chunk aggregated"), otlog.Int("records", end))
+		}
+	}
+
+	report.Drivers = len(drivers)
+	if report.Dispatches > 0 {
+		report.MeanETA = eta / time.Duration(report.Dispatches)
+	}
+	report.Customers = make([]CustomerReport, 0, len(customers))
+	for _, c := range customers {
+		c.MeanETA /= time.Duration(c.Dispatches)
+		report.Customers = append(report.Customers, *c)
+	}
+	sort.Slice(report.Customers, func(i, j int) bool {
+		a, b := report.Customers[i], report.Customers[j]
+		if a.Dispatches != b.Dispatches {
+			return a.Dispatches > b.Dispatches
+		}
+		return a.Customer < b.Customer
+	})
+	if span != nil {
+		span.SetTag("report.dispatches", report.Dispatches)
+	}
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Effective describes the resource settings the Go runtime is actually using.
+type Effective struct {
+	GOMAXPROCS        int
+	NumCPU            int
+	MemoryLimit       int64
+	CgroupMemoryLimit int64 `json:",omitempty"`
+}
+
+// Apply sets GOMAXPROCS and the soft memory limit from the environment:
+//
+//	RUNTIME_MAXPROCS        positive number of procs, or "cgroup" to use the container CPU quota
+//	RUNTIME_MEMLIMIT_RATIO  fraction in (0, 1] of the container memory limit to use as soft limit
+//
+// The standard GOMAXPROCS and GOMEMLIMIT variables are still honored by the
+// runtime itself and take precedence.
+func Apply() (Effective, error) {
+	if procs := os.Getenv("RUNTIME_MAXPROCS"); procs != "" && os.Getenv("GOMAXPROCS") == "" {
+		n, err := maxProcs(procs)
+		if err != nil {
+			return Current(), err
+		}
+		runtime.GOMAXPROCS(n)
+	}
+
+	if ratio := os.Getenv("RUNTIME_MEMLIMIT_RATIO"); ratio != "" && os.Getenv("GOMEMLIMIT") == "" {
+		r, err := strconv.ParseFloat(ratio, 64)
+		if err != nil {
+			return Current(), err
+		}
+		if !(r > 0 && r <= 1) {
+			return Current(), fmt.Errorf("RUNTIME_MEMLIMIT_RATIO must be in (0, 1], got %v", ratio)
+		}
+		if limit, ok := cgroupMemoryLimit(); ok {
+			debug.SetMemoryLimit(int64(float64(limit) * r))
+		}
+	}
+
+	return Current(), nil
+}
+
+// Current returns the effective resource settings.
+func Current() Effective {
+	effective := Effective{
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		NumCPU:      runtime.NumCPU(),
+		MemoryLimit: debug.SetMemoryLimit(-1),
+	}
+	if limit, ok := cgroupMemoryLimit(); ok {
+		effective.CgroupMemoryLimit = limit
+	}
+	return effective
+}
+
+// Tags returns the effective settings as tracer tags.
+func (e Effective) Tags() []opentracing.Tag {
+	return []opentracing.Tag{
+		{Key: "runtime.gomaxprocs", Value: e.GOMAXPROCS},
+		{Key: "runtime.memlimit", Value: e.MemoryLimit},
+	}
+}
+
+// Handler serves the effective settings as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(Current())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+func maxProcs(value string) (int, error) {
+	if value != "cgroup" {
+		n, err := strconv.Atoi(value)
+		if err == nil && n <= 0 {
+			err = fmt.Errorf("RUNTIME_MAXPROCS must be positive or \"cgroup\", got %d", n)
+		}
+		return n, err
+	}
+	quota, ok := cgroupCPUQuota()
+	if !ok {
+		return runtime.NumCPU(), nil
+	}
+	return int(math.Max(1, math.Ceil(quota))), nil
+}
+
+// cgroupCPUQuota returns the number of CPUs the container may use (cgroup v2 or v1).
+func cgroupCPUQuota() (float64, bool) {
+	if data, err := ioutil.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		var quota, period string
+		if _, err := fmt.Sscan(string(data), &quota, &period); err != nil || quota == "max" {
+			return 0, false
+		}
+		return ratio(quota, period)
+	}
+
+	quota, err := ioutil.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	period, err := ioutil.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return ratio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func ratio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// cgroupMemoryLimit returns the container memory limit (cgroup v2 or v1).
+func cgroupMemoryLimit() (int64, bool) {
+	for _, file := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		// "max" in v2, and a huge page-aligned number in v1, mean no limit
+		if err != nil || limit <= 0 || limit >= math.MaxInt64/2 {
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
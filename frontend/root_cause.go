@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/superliuwr/jaeger-demo/frontend/breaker"
+	"github.com/superliuwr/jaeger-demo/frontend/rootcause"
+)
+
+// callError tags the failure of a downstream call with the service and
+// operation that failed. Its message is the one of the wrapped error.
+type callError struct {
+	service   string
+	operation string
+	err       error
+}
+
+func (e *callError) Error() string {
+	return e.err.Error()
+}
+
+func (e *callError) Unwrap() error {
+	return e.err
+}
+
+// wrapCall tags err, if any, as a failure of operation on service.
+func wrapCall(service, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &callError{service: service, operation: operation, err: err}
+}
+
+// rootCauses finds the deepest failing calls of a dispatch that failed
+// with err: every failed call of a fan-out, or else the one call that
+// failed. Errors that are not tagged with a call are the frontend's own.
+func rootCauses(err error) []rootcause.Cause {
+	var fanOut *FanOutError
+	if errors.As(err, &fanOut) {
+		if joined, ok := fanOut.err.(interface{ Unwrap() []error }); ok {
+			var causes []rootcause.Cause
+			for _, e := range joined.Unwrap() {
+				causes = append(causes, rootCauses(e)...)
+			}
+			return causes
+		}
+	}
+
+	cause := rootcause.Cause{Service: "frontend", Operation: "dispatch", Class: classify(err)}
+	var call *callError
+	if errors.As(err, &call) {
+		cause.Service, cause.Operation = call.service, call.operation
+	}
+	return []rootcause.Cause{cause}
+}
+
+// classify tells how a call failed.
+func classify(err error) string {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &grpcErr) && grpcErr.GRPCStatus().Code() == codes.DeadlineExceeded:
+		return "timeout"
+	case breaker.Rejected(err):
+		return "circuit-open"
+	default:
+		return "error"
+	}
+}
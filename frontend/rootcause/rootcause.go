@@ -0,0 +1,144 @@
+package rootcause
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// capacity is the number of failed dispatches kept for the summary.
+const capacity = 1000
+
+// maxTraceIDs is the number of example traces kept per group.
+const maxTraceIDs = 5
+
+// Cause is the deepest failing call of a dispatch, as seen by the frontend.
+type Cause struct {
+	Service   string
+	Operation string
+	// Class tells how the call failed: "timeout", "circuit-open" or "error".
+	Class string
+}
+
+// Group sums up the failed dispatches that share a cause.
+type Group struct {
+	Cause
+	// Dispatches is the number of failed dispatches with this cause, and
+	// Weight their share of the blame: a dispatch whose failed calls had
+	// several causes splits its weight of 1 among them, in proportion to
+	// the number of calls that failed for each.
+	Dispatches int
+	Weight     float64
+	// Share is Weight as a fraction of all failed dispatches.
+	Share float64
+	Last  time.Time
+	// TraceIDs are the most recent traces of the group, newest first.
+	TraceIDs []string
+}
+
+// Summary is the breakdown of the recent failed dispatches.
+type Summary struct {
+	Since    time.Time
+	Failures int
+	Groups   []Group
+}
+
+type failure struct {
+	time    time.Time
+	traceID string
+	weights map[Cause]float64
+}
+
+var (
+	lock     sync.Mutex
+	failures []failure
+)
+
+// Record adds a failed dispatch and the causes of its failed calls, one
+// per call.
+func Record(traceID string, causes ...Cause) {
+	if len(causes) == 0 {
+		return
+	}
+	weights := make(map[Cause]float64, len(causes))
+	for _, c := range causes {
+		weights[c] += 1 / float64(len(causes))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(failures) == capacity {
+		failures = failures[1:]
+	}
+	failures = append(failures, failure{time: time.Now(), traceID: traceID, weights: weights})
+}
+
+// Summarize groups the failed dispatches of the last window by cause, the
+// heaviest first. A window of 0 covers all the dispatches kept.
+func Summarize(window time.Duration) Summary {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var summary Summary
+	if window > 0 {
+		summary.Since = time.Now().Add(-window)
+	}
+	groups := make(map[Cause]*Group)
+	for i := len(failures) - 1; i >= 0; i-- {
+		f := failures[i]
+		if f.time.Before(summary.Since) {
+			break
+		}
+		summary.Failures++
+		for c, weight := range f.weights {
+			g, ok := groups[c]
+			if !ok {
+				g = &Group{Cause: c, Last: f.time}
+				groups[c] = g
+			}
+			g.Dispatches++
+			g.Weight += weight
+			if f.traceID != "" && len(g.TraceIDs) < maxTraceIDs {
+				g.TraceIDs = append(g.TraceIDs, f.traceID)
+			}
+		}
+	}
+
+	summary.Groups = make([]Group, 0, len(groups))
+	for _, g := range groups {
+		g.Share = g.Weight / float64(summary.Failures)
+		summary.Groups = append(summary.Groups, *g)
+	}
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		a, b := summary.Groups[i], summary.Groups[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Last.After(b.Last)
+	})
+	return summary
+}
+
+// Handler renders the summary as JSON. The window query parameter, e.g.
+// ?window=5m, limits it to the most recent failures.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var window time.Duration
+		if v := r.URL.Query().Get("window"); v != "" {
+			var err error
+			if window, err = time.ParseDuration(v); err != nil || window < 0 {
+				http.Error(w, "invalid window "+v, http.StatusBadRequest)
+				return
+			}
+		}
+		data, err := json.Marshal(Summarize(window))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+// violations counts, per client, the responses that did not match their schema.
+var violations = expvar.NewMap("response_schema_violations")
+
+// schemas holds the loaded schemas by client name.
+var schemas = map[string]*Schema{}
+
+// Load reads a schema for every <client>.json file in dir. It must be
+// called before the clients are created.
+func Load(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, &os.PathError{Op: "load schemas", Path: dir, Err: os.ErrNotExist}
+	}
+
+	var clients []string
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		s, err := Parse(data)
+		if err != nil {
+			return nil, &os.PathError{Op: "parse schema", Path: file, Err: err}
+		}
+		client := strings.TrimSuffix(filepath.Base(file), ".json")
+		schemas[client] = s
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// Validator checks the responses received by one client against its schema.
+// A nil Validator checks nothing.
+type Validator struct {
+	client string
+	schema *Schema
+	logger log.Factory
+}
+
+// For returns the Validator of the named client, or nil if no schema was
+// loaded for it.
+func For(client string, logger log.Factory) *Validator {
+	s, ok := schemas[client]
+	if !ok {
+		return nil
+	}
+	return &Validator{client: client, schema: s, logger: logger}
+}
+
+// Check validates the response body. Violations do not fail the call: they
+// are logged on the span in ctx, counted, and the span is tagged with their
+// number, so drift between the services' contracts shows up in traces.
+func (v *Validator) Check(ctx context.Context, endpoint string, body []byte) {
+	if v == nil {
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		// Not JSON; the caller reports the decoding error.
+		return
+	}
+
+	found := v.schema.Validate(value)
+	if len(found) == 0 {
+		return
+	}
+	violations.Add(v.client, 1)
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("schema.violations", len(found))
+	}
+	for _, violation := range found {
+		v.logger.For(ctx).Info("Response schema violation",
+			zap.String("client", v.client),
+			zap.String("endpoint", endpoint),
+			zap.String("path", violation.Path),
+			zap.String("violation", violation.Message),
+		)
+	}
+}
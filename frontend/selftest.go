@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// selftestTimeout is how long the self-test waits for its span to show up in Jaeger.
+const selftestTimeout = 30 * time.Second
+
+// selftestClient bounds each request of the self-test, so a server that
+// accepts the connection but never answers fails the check.
+var selftestClient = &http.Client{Timeout: 5 * time.Second}
+
+// check is one step of the self-test.
+type check struct {
+	name string
+	run  func() error
+}
+
+// selftest checks that the tracing pipeline works end to end: a marked span
+// is reported and then looked up through the Jaeger query API, and the
+// Prometheus metrics endpoint is scraped. It fails if any check fails, so it can gate
+// deployments of the demo.
+//
+// The Jaeger query API and metrics endpoint are read from JAEGER_QUERY_URL
+// and SELFTEST_METRICS_URL. The default metrics URL is /metrics, which unlike
+// /debug/vars is not protected by ADMIN_TOKENS.
+func selftest() error {
+	queryURL := getenv("JAEGER_QUERY_URL", "http://jaeger:16686")
+	metricsURL := getenv("SELFTEST_METRICS_URL", "http://localhost:8080/metrics")
+	loggerFactory := log.NewFactory(zap.NewNop(), zapcore.InfoLevel)
+
+	id, err := selftestID()
+	if err != nil {
+		return err
+	}
+
+	checks := []check{
+		{name: "span-reported", run: func() error { return reportSelftestSpan(loggerFactory, id) }},
+		{name: "span-queryable", run: func() error { return findSelftestSpan(queryURL, id) }},
+		{name: "metrics-scrapable", run: func() error { return scrapeMetrics(metricsURL) }},
+	}
+	for _, c := range checks {
+		if err := c.run(); err != nil {
+			fmt.Printf("%-18s FAIL %v\n", c.name, err)
+			return fmt.Errorf("self-test %s failed: %v", c.name, err)
+		}
+		fmt.Printf("%-18s ok\n", c.name)
+	}
+
+	return nil
+}
+
+func selftestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func reportSelftestSpan(loggerFactory log.Factory, id string) error {
+	tracer := tracing.Init("frontend", tracing.WithLogger(loggerFactory))
+	span := tracer.StartSpan("selftest")
+	span.SetTag("selftest.id", id)
+	span.Finish()
+
+	closer, ok := tracer.(io.Closer)
+	if !ok {
+		return fmt.Errorf("tracer %T cannot be flushed", tracer)
+	}
+	return closer.Close()
+}
+
+func findSelftestSpan(queryURL, id string) error {
+	tags, _ := json.Marshal(map[string]string{"selftest.id": id})
+	v := url.Values{}
+	v.Set("service", "frontend")
+	v.Set("operation", "selftest")
+	v.Set("tags", string(tags))
+	v.Set("lookback", "1h")
+	u := queryURL + "/api/traces?" + v.Encode()
+
+	deadline := time.Now().Add(selftestTimeout)
+	for {
+		found, err := queryTraces(u)
+		if found {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("span selftest.id=%s not found within %v", id, selftestTimeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func queryTraces(u string) (bool, error) {
+	res, err := selftestClient.Get(u)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("jaeger query returned %s", res.Status)
+	}
+	var result struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return len(result.Data) > 0, nil
+}
+
+func scrapeMetrics(metricsURL string) error {
+	res, err := selftestClient.Get(metricsURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("metrics endpoint returned %s", res.Status)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(res.Body)
+	if err != nil {
+		return fmt.Errorf("cannot parse metrics: %v", err)
+	}
+	if len(families) == 0 {
+		return fmt.Errorf("metrics endpoint returned no metrics")
+	}
+	return nil
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
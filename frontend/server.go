@@ -1,15 +1,50 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
 	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 
+	"github.com/superliuwr/jaeger-demo/frontend/addressbook"
+	"github.com/superliuwr/jaeger-demo/frontend/admin"
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+	"github.com/superliuwr/jaeger-demo/frontend/chaos"
+	"github.com/superliuwr/jaeger-demo/frontend/clients"
+	"github.com/superliuwr/jaeger-demo/frontend/cors"
+	"github.com/superliuwr/jaeger-demo/frontend/cost"
+	"github.com/superliuwr/jaeger-demo/frontend/depgraph"
+	"github.com/superliuwr/jaeger-demo/frontend/dispatchlog"
+	"github.com/superliuwr/jaeger-demo/frontend/health"
 	"github.com/superliuwr/jaeger-demo/frontend/httperr"
+	"github.com/superliuwr/jaeger-demo/frontend/incidents"
+	"github.com/superliuwr/jaeger-demo/frontend/jobs"
+	"github.com/superliuwr/jaeger-demo/frontend/limits"
 	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/outbox"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/requestid"
+	"github.com/superliuwr/jaeger-demo/frontend/resources"
+	"github.com/superliuwr/jaeger-demo/frontend/rootcause"
+	"github.com/superliuwr/jaeger-demo/frontend/synthetic"
+	"github.com/superliuwr/jaeger-demo/frontend/timeouts"
+	"github.com/superliuwr/jaeger-demo/frontend/timeseries"
 	"github.com/superliuwr/jaeger-demo/frontend/tracing"
 )
 
@@ -19,51 +54,335 @@ type Server struct {
 	tracer   opentracing.Tracer
 	logger   log.Factory
 	bestETA  *bestETA
-	assetFS  http.FileSystem
+	depGraph *depgraph.Graph
+	history  *dispatchlog.Log
+	relay    *outbox.Relay
+	monitor  *synthetic.Monitor
+	jobs     *jobs.Runner
+	address  *addressbook.Book
+	drain    drainer
+	series   *timeseries.Set
+	ui       *webUI
 	basePath string
+	limits   limits.Limits
+	options  ConfigOptions
+
+	// events is the lifecycle of dispatches, which projection projects
+	// into history.
+	events     *dispatchlog.EventStore
+	projection *dispatchlog.Projection
+	// dispatchLimiter is nil without a dispatch rate.
+	dispatchLimiter *limits.RateLimiter
 }
 
+// readinessTimeout bounds the checks of a readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// outboxSize is the number of unpublished dispatches the outbox keeps.
+const outboxSize = 1000
+
 // ConfigOptions used to make sure service clients
 // can find correct server ports
 type ConfigOptions struct {
-	FrontendHostPort string
-	DriverHostPort   string
-	CustomerHostPort string
-	RouteHostPort    string
-	BasePath         string
+	FrontendHostPort   string
+	DriverHostPort     string
+	CustomerHostPort   string
+	RouteHostPort      string
+	RouteGRPCHostPort  string
+	RouteTransport     string
+	BasePath           string
+	DepGraphWindow     time.Duration
+	DispatchHistory    int
+	FieldEncryptionKey string
+	Limits             limits.Limits
+	MockBackends       bool
+	// LocalAssets serves the web UI from ui/web_assets on disk instead of
+	// the embedded copy, and reloads open pages when the files change.
+	LocalAssets bool
+	// SPAFallback serves the index page for paths that are neither UI
+	// files nor API endpoints, for client-side routes.
+	SPAFallback bool
+	// OutboxURL is the webhook dispatches are published to through the
+	// outbox. Empty disables the outbox.
+	OutboxURL string
+	// ShutdownTimeout bounds how long in-flight requests are drained after
+	// SIGINT or SIGTERM before the server closes them.
+	ShutdownTimeout time.Duration
+	// PprofHostPort is where the pprof handlers are served. Empty
+	// disables them.
+	PprofHostPort string
+	// LogLevel is the level of the logger, changed at runtime through
+	// /admin/loglevel. Nil leaves the endpoint out.
+	LogLevel *zap.AtomicLevel
+	// SyntheticInterval is how often the synthetic monitor dispatches
+	// SyntheticCustomer. Zero disables the monitor.
+	SyntheticInterval time.Duration
+	SyntheticCustomer string
+	// CustomerTiers are the tiers the cost of dispatches is attributed to.
+	CustomerTiers cost.Tiers
+	// Admin restricts who may use the admin and debug endpoints. The zero
+	// Policy leaves them open.
+	Admin admin.Policy
+	// DriverGRPC tunes the lifecycle of the connection to the driver service.
+	DriverGRPC clients.GRPCConfig
+	// ChaosCalendar applies chaos rules on a simulated clock. Nil disables
+	// it.
+	ChaosCalendar *chaos.Calendar
+	// DispatchRate limits dispatches to that many per second, with bursts
+	// of up to DispatchBurst. Zero disables the limit.
+	DispatchRate  float64
+	DispatchBurst int
+	// CORS lets pages of other origins call the API. The zero Policy
+	// allows none.
+	CORS cors.Policy
+	// TLSCert and TLSKey are the PEM files of the certificate and key to
+	// serve HTTPS with. Empty serves plain HTTP.
+	TLSCert string
+	TLSKey  string
+	// Auth issues tokens on /login and validates them on every request.
+	// Nil disables authentication.
+	Auth *auth.Authenticator
+	// Weather adjusts ETAs for the weather in the city.
+	Weather clients.WeatherConfig
+	// BaggageAudit tags request spans with the keys of the baggage items
+	// requests came in with, and of those added and removed on the way.
+	BaggageAudit bool
+	// SyntheticToken is the token requests with the synthetic baggage item
+	// must carry in synthetic.TokenHeader, as the monitor and loadgen do.
+	// Empty clears the item from every request.
+	SyntheticToken string
+}
+
+// scheme is the URL scheme the frontend serves.
+func (o ConfigOptions) scheme() string {
+	if o.TLSCert != "" {
+		return "https"
+	}
+	return "http"
 }
 
 // NewServer creates a new frontend.Server
 func NewServer(options ConfigOptions, tracer opentracing.Tracer, logger log.Factory) *Server {
-	assetFS := FS(false)
+	depGraph := depgraph.New("frontend", options.DepGraphWindow)
+	history := dispatchlog.New(options.DispatchHistory)
+	var relay *outbox.Relay
+	if options.OutboxURL != "" {
+		history.EnableOutbox(outboxSize)
+		relay = outbox.NewRelay(tracer, logger.With(zap.String("component", "outbox")), history, options.OutboxURL)
+	}
+	var dispatchLimiter *limits.RateLimiter
+	if options.DispatchRate > 0 {
+		dispatchLimiter = limits.NewRateLimiter(options.DispatchRate, options.DispatchBurst)
+	}
+	// Each dispatch in the history takes three events.
+	events := dispatchlog.NewEventStore(3 * options.DispatchHistory)
+
+	var monitor *synthetic.Monitor
+	if options.SyntheticInterval > 0 {
+		_, port, _ := net.SplitHostPort(options.FrontendHostPort)
+		dispatchURL := options.scheme() + "://" + net.JoinHostPort("127.0.0.1", port) + path.Join(options.BasePath, "/dispatch")
+		monitor = synthetic.NewMonitor(tracer, logger.With(zap.String("component", "synthetic")), dispatchURL, options.SyntheticCustomer, options.SyntheticToken)
+	}
 
 	return &Server{
 		hostPort: options.FrontendHostPort,
 		tracer:   tracer,
 		logger:   logger,
-		bestETA:  newBestETA(tracer, logger, options),
-		assetFS:  assetFS,
+		bestETA:  newBestETA(tracer, logger, depGraph, options),
+		depGraph: depGraph,
+		history:  history,
+		relay:    relay,
+		monitor:  monitor,
+		jobs:     jobs.NewRunner(tracer, logger.With(zap.String("component", "jobs"))),
+		address:  addressbook.New(tracer, logger.With(zap.String("component", "addressbook"))),
+		series:   timeseries.NewSet(time.Second, 60),
+		ui:       newWebUI(tracer, logger, depGraph, history, options),
 		basePath: options.BasePath,
+		limits:   options.Limits,
+		options:  options,
+
+		events:          events,
+		projection:      dispatchlog.NewProjection(tracer, events, history),
+		dispatchLimiter: dispatchLimiter,
 	}
 }
 
-// Run starts the frontend server
+// Run starts the frontend server and serves until SIGINT or SIGTERM. It then
+// stops accepting connections, drains in-flight requests for at most the
+// shutdown timeout and closes the tracer, so buffered spans are flushed.
 func (s *Server) Run() error {
 	mux := s.createServeMux()
 
-	s.logger.Bg().Info("Starting", zap.String("address", "http://"+path.Join(s.hostPort, s.basePath)))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.logger.Bg().Info("Starting", zap.String("address", s.options.scheme()+"://"+path.Join(s.hostPort, s.basePath)))
+	if s.relay != nil {
+		go s.relay.Run(ctx, time.Second)
+	}
+	if s.monitor != nil {
+		go s.monitor.Run(ctx, s.options.SyntheticInterval)
+	}
+	if s.options.ChaosCalendar != nil {
+		go s.options.ChaosCalendar.Run(ctx, time.Second)
+	}
+
+	t := timeouts.Get().Server
+	server := &http.Server{
+		Addr:         s.hostPort,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(t.Read),
+		WriteTimeout: time.Duration(t.Write),
+		IdleTimeout:  time.Duration(t.Idle),
+		// net/http answers 431 itself only 4KB past this limit; requests in
+		// between are rejected, counted and traced by the limits middleware.
+		MaxHeaderBytes: s.limits.MaxHeaderBytes,
+	}
+	server.RegisterOnShutdown(s.ui.close)
+	server.RegisterOnShutdown(s.address.Close)
+	if s.options.PprofHostPort != "" {
+		profiler := s.pprofServer(s.options.PprofHostPort)
+		// Profiles may take longer than the drain, so they are not waited for.
+		server.RegisterOnShutdown(func() { _ = profiler.Close() })
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if s.options.TLSCert != "" {
+			served <- server.ListenAndServeTLS(s.options.TLSCert, s.options.TLSKey)
+			return
+		}
+		served <- server.ListenAndServe()
+	}()
 
-	return http.ListenAndServe(s.hostPort, mux)
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		stop()
+		s.logger.Bg().Info("Shutting down", zap.Duration("timeout", s.options.ShutdownTimeout))
+		drainCtx, cancel := context.WithTimeout(context.Background(), s.options.ShutdownTimeout)
+		defer cancel()
+		// Dispatches are turned away with 503 while those in flight
+		// finish, before the server stops accepting connections at all.
+		select {
+		case <-s.drain.start():
+		case <-drainCtx.Done():
+		}
+		if err = server.Shutdown(drainCtx); err != nil {
+			s.logger.Bg().Error("Cannot drain in-flight requests", zap.Error(err))
+			err = server.Close()
+		}
+	}
+	if closer, ok := s.tracer.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Bg().Error("Cannot flush spans", zap.Error(closeErr))
+		}
+	}
+	return err
 }
 
+// guardedPrefixes are the paths of the endpoints the admin guard protects.
+var guardedPrefixes = []string{"/admin/", "/debug/"}
+
 func (s *Server) createServeMux() http.Handler {
+	p := path.Join("/", s.basePath)
 	mux := tracing.NewServeMux(s.tracer)
+	if s.options.BaggageAudit {
+		mux.Use(tracing.AuditBaggage)
+	}
+	mux.UseRoute(red.Middleware)
+	mux.Use(requestid.Middleware)
+	if s.options.Auth != nil {
+		// The admin guard takes bearer tokens of its own, which are not JWTs.
+		mux.UseRoute(func(pattern string, next http.Handler) http.Handler {
+			if hasAnyPrefix(strings.TrimPrefix(pattern, strings.TrimSuffix(p, "/")), guardedPrefixes) {
+				return next
+			}
+			return s.options.Auth.Middleware(next)
+		})
+	}
+	mux.Use(s.limits.Handler)
+	mux.Use(synthetic.Middleware(s.options.SyntheticToken))
+	mux.Use(tracing.TraceResponse)
 
-	p := path.Join("/", s.basePath)
-	mux.Handle(p, http.StripPrefix(p, http.FileServer(s.assetFS)))
-	mux.Handle(path.Join(p, "/dispatch"), http.HandlerFunc(s.dispatch))
+	s.ui.register(mux, p)
+	mux.Handle(path.Join(p, "/dispatch"), s.series.Handler("dispatch", s.drain.handler(s.options.ShutdownTimeout, s.dispatchLimiter.Handler("dispatch", chaos.Errors(http.HandlerFunc(s.dispatch))))), http.MethodGet)
+	mux.Handle(path.Join(p, "/rating"), http.HandlerFunc(s.rating), http.MethodPost)
+	if s.options.Auth != nil {
+		mux.Handle(path.Join(p, "/login"), s.options.Auth.LoginHandler(), http.MethodPost)
+	}
+	mux.HandleStream(path.Join(p, "/api/v1/dispatches/stream"), 0, s.history)
+	mux.Handle(path.Join(p, "/api/v1/dispatches/events"), s.events)
+	mux.Handle(path.Join(p, "/api/v1/timeseries"), s.series)
+	mux.Handle(path.Join(p, "/api/v1/incidents"), incidents.Handler())
+	mux.Handle(path.Join(p, "/api/v1/failures/root-causes"), rootcause.Handler())
+	mux.Handle(path.Join(p, "/api/v1/costs"), cost.Handler())
+	mux.Handle(path.Join(p, "/api/v1/timeouts"), timeouts.AdaptiveHandler())
+	mux.Handle(path.Join(p, "/api/v1/reports"), http.HandlerFunc(s.report), http.MethodPost)
+	mux.Handle(path.Join(p, "/api/v1/jobs/{id}"), s.jobs)
+	mux.Handle(path.Join(p, "/api/v1/customers/{customer}/locations"), s.address, http.MethodGet, http.MethodPost)
+	mux.Handle(path.Join(p, "/api/v1/customers/{customer}/locations/{id}"), s.address, http.MethodGet, http.MethodPut, http.MethodDelete)
+	mux.HandleStream(path.Join(p, "/api/v1/locations/events"), 0, s.address.Events())
+	if s.monitor != nil {
+		mux.Handle(path.Join(p, "/api/v1/synthetic"), s.monitor)
+	}
+	if s.options.ChaosCalendar != nil {
+		mux.Handle(path.Join(p, "/api/v1/chaos/calendar"), s.options.ChaosCalendar)
+	}
+	guard := admin.NewGuard(s.options.Admin, s.logger.With(zap.String("component", "admin")))
+	mux.Handle(path.Join(p, "/debug/depgraph"), guard.Protect("debug", s.depGraph))
+	mux.Handle(path.Join(p, "/debug/vars"), guard.Protect("debug", expvar.Handler()))
+	mux.Handle(path.Join(p, "/metrics"), red.Handler())
+	mux.Handle(path.Join(p, "/debug/runtime"), guard.Protect("debug", resources.Handler()))
+	mux.Handle(path.Join(p, "/debug/timeouts"), guard.Protect("debug", timeouts.Handler()))
+	mux.Handle(path.Join(p, "/debug/config"), guard.Protect("config", configHandler(s.options)))
+	mux.Handle(path.Join(p, "/admin/projections/history/rebuild"), guard.Protect("projections", s.projection.RebuildHandler()), http.MethodPost)
+	if s.options.LogLevel != nil {
+		mux.Handle(path.Join(p, "/admin/loglevel"), guard.Protect("loglevel", s.options.LogLevel), http.MethodGet, http.MethodPut)
+	}
+	mux.Handle(path.Join(p, "/healthz"), health.Liveness())
+	mux.Handle(path.Join(p, "/readyz"), s.drain.readiness(health.Readiness(readinessTimeout, s.readinessChecks()...)))
+
+	return s.options.CORS.Handler(mux)
+}
+
+// readinessChecks probe the downstream services the frontend calls and the
+// tracing backend it reports to.
+func (s *Server) readinessChecks() []health.Check {
+	checks := []health.Check{
+		health.TCP("customer", s.options.CustomerHostPort),
+		health.TCP("driver", s.options.DriverHostPort),
+	}
+	switch {
+	case s.options.MockBackends:
+	case s.options.RouteTransport == "grpc":
+		checks = append(checks, health.TCP("route", s.options.RouteGRPCHostPort))
+	default:
+		checks = append(checks, health.TCP("route", s.options.RouteHostPort))
+	}
+
+	if _, ok := s.tracer.(*tracing.OTelTracer); ok {
+		if endpoint, err := url.Parse(getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")); err == nil {
+			checks = append(checks, health.TCP("otlp", urlHostPort(endpoint)))
+		}
+	} else if endpoint, err := url.Parse(os.Getenv("JAEGER_ENDPOINT")); err == nil && endpoint.Host != "" {
+		checks = append(checks, health.TCP("jaeger-collector", urlHostPort(endpoint)))
+	} else {
+		checks = append(checks, health.Resolve("jaeger-agent", getenv("JAEGER_AGENT_HOST", "localhost")))
+	}
+	return checks
+}
 
-	return mux
+// urlHostPort returns the host and port u connects to.
+func urlHostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
 }
 
 func (s *Server) dispatch(w http.ResponseWriter, r *http.Request) {
@@ -81,19 +400,124 @@ func (s *Server) dispatch(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing required 'customer' parameter", http.StatusBadRequest)
 		return
 	}
+	// Every service down the line reads the customer, and the session
+	// the UI sends, from the baggage and tags its spans with them.
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetBaggageItem("customer", customerID)
+		tracing.TagBaggage(span)
+		span.SetTag(cost.TierTag, s.options.CustomerTiers.Of(customerID))
+	}
 
+	// Canary dispatches of the synthetic monitor are kept out of the
+	// incidents, failure analysis, history and outbox of real traffic.
+	canary := synthetic.FromContext(ctx)
+
+	dispatch := dispatchID(ctx)
+	start := time.Now()
+	if !canary {
+		s.events.Append(ctx, dispatchlog.LifecycleEvent{Dispatch: dispatch, Type: dispatchlog.Requested, Time: start, Customer: customerID})
+	}
 	response, err := s.bestETA.Get(ctx, customerID)
+	if !canary {
+		incidents.Observe(tracing.TraceID(ctx), time.Since(start), err)
+	}
+	if err != nil && !canary {
+		rootcause.Record(tracing.TraceID(ctx), rootCauses(err)...)
+	}
+	var fanOutErr *FanOutError
+	if errors.As(err, &fanOutErr) {
+		s.logger.For(ctx).Error("request failed", zap.Error(err))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(fanOutErr)
+		return
+	}
 	if httperr.HandleError(w, err, http.StatusInternalServerError) {
 		s.logger.For(ctx).Error("request failed", zap.Error(err))
 		return
 	}
 
+	if !canary {
+		s.events.Append(ctx, dispatchlog.LifecycleEvent{Dispatch: dispatch, Type: dispatchlog.Assigned, Time: time.Now(), Driver: response.Driver, ETA: response.ETA})
+	}
+
+	response.JourneyID = journeyID(ctx)
 	data, err := json.Marshal(response)
 	if httperr.HandleError(w, err, http.StatusInternalServerError) {
 		s.logger.For(ctx).Error("cannot marshal response", zap.Error(err))
 		return
 	}
 
+	if !canary {
+		dispatchesByCustomer.Add(customerLabel.Value(customerID), 1)
+		s.events.Append(ctx, dispatchlog.LifecycleEvent{Dispatch: dispatch, Type: dispatchlog.Completed, Time: time.Now()})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server-Timing", serverTiming(response.Dependencies))
 	_, _ = w.Write(data)
 }
+
+// rating records a customer's rating of a driver. It runs in its own trace,
+// tied to the dispatch trace by the journey.id tag.
+func (s *Server) rating(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); httperr.HandleError(w, err, http.StatusBadRequest) {
+		s.logger.For(ctx).Error("bad request", zap.Error(err))
+		return
+	}
+
+	journey := r.Form.Get("journey")
+	driver := r.Form.Get("driver")
+	stars, err := strconv.Atoi(r.Form.Get("rating"))
+	if journey == "" || driver == "" || err != nil || stars < 1 || stars > 5 {
+		http.Error(w, "Required parameters: 'journey', 'driver' and 'rating' from 1 to 5", http.StatusBadRequest)
+		return
+	}
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("journey.id", journey)
+		span.SetTag("driver", driver)
+		span.SetTag("rating", stars)
+	}
+	s.logger.For(ctx).Info("Rating received", zap.String("journey", journey), zap.String("driver", driver), zap.Int("rating", stars))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unidentifiedDispatches numbers the dispatches that have no request ID.
+var unidentifiedDispatches atomic.Uint64
+
+// dispatchID identifies the dispatch in ctx in the event log by its request
+// ID, which retries of a request keep, so a retried request records its
+// events once. A trace can span several dispatches, so it doesn't do.
+func dispatchID(ctx context.Context) string {
+	if id := requestid.FromContext(ctx); id != "" {
+		return id
+	}
+	return fmt.Sprintf("unidentified-%d", unidentifiedDispatches.Add(1))
+}
+
+// journeyID returns the ID that ties later steps of a customer journey,
+// like rating the driver, to this dispatch. It is the dispatch trace ID,
+// and is also set as the journey.id tag so all traces of the journey can
+// be found with a single tag search.
+func journeyID(ctx context.Context) string {
+	id := tracing.TraceID(ctx)
+	if id == "" {
+		return ""
+	}
+	opentracing.SpanFromContext(ctx).SetTag("journey.id", id)
+	return id
+}
+
+// serverTiming formats the time waited on each dependency as a Server-Timing
+// header value, so browsers can show where the time went without opening Jaeger.
+func serverTiming(dependencies []DependencyCost) string {
+	metrics := make([]string, 0, len(dependencies))
+	for _, d := range dependencies {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.1f", d.Name, float64(d.Wait)/float64(time.Millisecond)))
+	}
+	return strings.Join(metrics, ", ")
+}
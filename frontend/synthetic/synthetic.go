@@ -0,0 +1,243 @@
+package synthetic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/red"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// Tag marks the spans of synthetic requests, to tell them from real traffic.
+const Tag = "synthetic"
+
+// TokenHeader carries the token that proves a request with the synthetic
+// baggage item comes from the monitor or loadgen.
+const TokenHeader = "X-Synthetic-Token"
+
+// historySize is the number of checks kept.
+const historySize = 100
+
+var (
+	rejected = expvar.NewMap("synthetic_rejected")
+	checks   = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frontend",
+		Name:      "synthetic_checks_total",
+		Help:      "Canary dispatches run by the synthetic monitor, by result.",
+	}, []string{"result"})
+	checkDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "frontend",
+		Name:      "synthetic_check_duration_seconds",
+		Help:      "Latency of the canary dispatches.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+// Check is the outcome of one canary dispatch.
+type Check struct {
+	Time     time.Time
+	OK       bool
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+	TraceID  string
+}
+
+// FromContext tells if the request in ctx is synthetic, from the baggage
+// item the monitor sets.
+func FromContext(ctx context.Context) bool {
+	span := opentracing.SpanFromContext(ctx)
+	return span != nil && span.BaggageItem(tracing.SyntheticBaggageKey) == "true"
+}
+
+// Middleware tags the request span of synthetic requests, those with the
+// synthetic baggage item and token in their TokenHeader. Anyone can set the
+// baggage item, so it is cleared from other requests, which would
+// otherwise be left out of the incidents, history and outbox of real
+// traffic. Clearings are counted in the synthetic_rejected expvar. It must
+// run inside the tracing middleware, before anything reads FromContext.
+func Middleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !FromContext(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			span := opentracing.SpanFromContext(r.Context())
+			if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte(token)) != 1 {
+				rejected.Add("token", 1)
+				span.SetBaggageItem(tracing.SyntheticBaggageKey, "")
+				span.LogFields(otlog.String("event", "synthetic baggage dropped"), otlog.String("reason", "no valid synthetic token"))
+				next.ServeHTTP(w, r)
+				return
+			}
+			span.SetTag(Tag, true)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Transport sets the TokenHeader of requests to token, so the frontend
+// takes them as synthetic.
+func Transport(token string, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set(TokenHeader, token)
+		return next.RoundTrip(req)
+	})
+}
+
+// NewToken returns a random token, for a frontend without a configured one:
+// only its own monitor then knows it.
+func NewToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Monitor periodically runs a canary dispatch for a dedicated customer
+// against the frontend, and keeps the results.
+type Monitor struct {
+	tracer opentracing.Tracer
+	logger log.Factory
+	client *tracing.HTTPClient
+	url    string
+
+	lock    sync.Mutex
+	history []Check
+	failing bool
+}
+
+// NewMonitor creates a Monitor that dispatches customer on the frontend
+// /dispatch endpoint at dispatchURL, with the synthetic token of the
+// frontend.
+func NewMonitor(tracer opentracing.Tracer, logger log.Factory, dispatchURL, customer, token string) *Monitor {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The monitor calls its own frontend on the loopback address, which
+	// the certificate of an HTTPS frontend doesn't name.
+	// #nosec
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return &Monitor{
+		tracer: tracer,
+		logger: logger,
+		client: &tracing.HTTPClient{
+			Client: &http.Client{Transport: tracing.Transport(tracer, red.Transport("synthetic", Transport(token, transport)))},
+			Tracer: tracer,
+		},
+		url: dispatchURL + "?" + url.Values{"customer": {customer}}.Encode(),
+	}
+}
+
+// Run runs a check every interval, each within the interval, until ctx is
+// done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check(ctx, interval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check runs one canary dispatch in a trace of its own, tagged as
+// synthetic, and raises an alert span when the canary starts or stops
+// failing.
+func (m *Monitor) check(ctx context.Context, timeout time.Duration) {
+	span := m.tracer.StartSpan("synthetic: canary dispatch")
+	defer span.Finish()
+	span.SetTag(Tag, true)
+	span.SetBaggageItem(tracing.SyntheticBaggageKey, "true")
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var response struct{ Driver string }
+	err := m.client.GetJSON(ctx, "/dispatch", m.url, &response)
+	result := Check{Time: start, OK: err == nil, Duration: time.Since(start), TraceID: tracing.TraceID(ctx)}
+	checkDuration.Observe(result.Duration.Seconds())
+	if err != nil {
+		result.Error = err.Error()
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		checks.WithLabelValues("fail").Inc()
+	} else {
+		checks.WithLabelValues("pass").Inc()
+	}
+
+	m.lock.Lock()
+	if len(m.history) == historySize {
+		m.history = m.history[1:]
+	}
+	m.history = append(m.history, result)
+	changed := m.failing != (err != nil)
+	m.failing = err != nil
+	m.lock.Unlock()
+
+	if changed {
+		m.alert(span, err)
+	}
+}
+
+// alert records a change of the canary's state as a span of the check
+// that changed it, tagged alert.state=firing or resolved.
+func (m *Monitor) alert(parent opentracing.Span, err error) {
+	span := m.tracer.StartSpan("synthetic: alert", opentracing.ChildOf(parent.Context()))
+	defer span.Finish()
+	span.SetTag(Tag, true)
+	if err != nil {
+		span.SetTag("alert.state", "firing")
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		m.logger.Bg().Error("Canary dispatch is failing", zap.Error(err))
+		return
+	}
+	span.SetTag("alert.state", "resolved")
+	m.logger.Bg().Info("Canary dispatch passes again")
+}
+
+// History returns the checks kept, oldest first.
+func (m *Monitor) History() []Check {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]Check(nil), m.history...)
+}
+
+// ServeHTTP renders the pass/fail history as JSON.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(m.History())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,198 @@
+package timeouts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+const (
+	// headroom is how much longer than the observed p99 a derived timeout
+	// is, so that it only cuts calls slower than usual.
+	headroom = 1.5
+	// minTimeout is the shortest derived timeout, unless the SLO is
+	// shorter, so that a fast dependency is not cut off by a GC pause.
+	minTimeout = 50 * time.Millisecond
+	// minSamples are needed before a timeout is derived. Until then the
+	// operation keeps its configured timeout.
+	minSamples = 20
+	// windowSize is the number of latest calls the p99 is taken over.
+	windowSize = 500
+)
+
+// window holds the latencies of the latest calls to an operation.
+type window struct {
+	lock    sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *window) add(d time.Duration) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.samples) < windowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % windowSize
+}
+
+// p99 returns the 99th percentile of the latencies, and how many there are.
+func (w *window) p99() (time.Duration, int) {
+	w.lock.Lock()
+	sorted := append([]time.Duration(nil), w.samples...)
+	w.lock.Unlock()
+	if len(sorted) == 0 {
+		return 0, 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*99-1)/100], len(sorted)
+}
+
+// Derived is the request timeout of an operation derived from its SLO.
+type Derived struct {
+	Operation string
+	// SLO is the latency calls to the operation should stay within.
+	SLO Duration
+	// P99 is the observed 99th percentile of the latest Samples calls.
+	P99     Duration
+	Samples int
+	// Timeout is the derived request timeout, zero until there are
+	// enough samples.
+	Timeout Duration
+	Updated time.Time
+}
+
+var (
+	// latencies are kept for the operations with an SLO. The map is only
+	// written by EnableAdaptive, before any calls are made.
+	latencies = map[string]*window{}
+
+	derivedLock sync.Mutex
+	derived     = map[string]*Derived{}
+)
+
+// ParseSLOs parses the latency SLOs of operations, e.g.
+// "customer=500ms,driver=300ms,route=200ms".
+func ParseSLOs(s string) (map[string]time.Duration, error) {
+	slos := make(map[string]time.Duration)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		operation, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid SLO %q, want operation=latency", pair)
+		}
+		slo, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || slo <= 0 {
+			return nil, fmt.Errorf("invalid SLO %q, want a positive latency", pair)
+		}
+		slos[strings.TrimSpace(operation)] = slo
+	}
+	return slos, nil
+}
+
+// EnableAdaptive starts recording the latency of the calls to the
+// operations with an SLO, so Adapt can derive their request timeouts. It
+// must be called before any calls are made.
+func EnableAdaptive(slos map[string]time.Duration) {
+	derivedLock.Lock()
+	defer derivedLock.Unlock()
+	for operation, slo := range slos {
+		latencies[operation] = &window{}
+		derived[operation] = &Derived{Operation: operation, SLO: Duration(slo)}
+	}
+}
+
+// Adapt derives the request timeouts of the operations with an SLO every
+// interval, until ctx is done. An operation's timeout is its observed p99
+// with some headroom, but never more than its SLO: a dependency slower
+// than its SLO is cut off rather than slowing down its callers. Flags
+// still override derived timeouts. Changes are logged.
+func Adapt(ctx context.Context, interval time.Duration, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			adapt(logger)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func adapt(logger log.Logger) {
+	now := time.Now()
+	changed := false
+	derivedLock.Lock()
+	for operation, d := range derived {
+		p99, samples := latencies[operation].p99()
+		d.P99, d.Samples = Duration(p99), samples
+		if samples < minSamples {
+			continue
+		}
+		timeout := time.Duration(float64(p99) * headroom)
+		if timeout < minTimeout {
+			timeout = minTimeout
+		}
+		if timeout > time.Duration(d.SLO) {
+			timeout = time.Duration(d.SLO)
+		}
+		// Round to the millisecond, so that noise in the p99 doesn't
+		// change the timeout every time.
+		timeout = timeout.Round(time.Millisecond)
+		if Duration(timeout) == d.Timeout {
+			continue
+		}
+		logger.Info("Adapted request timeout", zap.String("operation", operation),
+			zap.Duration("timeout", timeout), zap.Duration("previous", time.Duration(d.Timeout)),
+			zap.Duration("p99", p99), zap.Duration("slo", time.Duration(d.SLO)), zap.Int("samples", samples))
+		d.Timeout, d.Updated = Duration(timeout), now
+		changed = true
+	}
+	derivedLock.Unlock()
+	if changed {
+		store(nil)
+	}
+}
+
+// derivedTimeouts returns the request timeouts derived so far.
+func derivedTimeouts() map[string]Duration {
+	derivedLock.Lock()
+	defer derivedLock.Unlock()
+	timeouts := make(map[string]Duration, len(derived))
+	for operation, d := range derived {
+		if d.Timeout > 0 {
+			timeouts[operation] = d.Timeout
+		}
+	}
+	return timeouts
+}
+
+// AdaptiveHandler serves the derived request timeouts as JSON.
+func AdaptiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		derivedLock.Lock()
+		view := make([]Derived, 0, len(derived))
+		for _, d := range derived {
+			view = append(view, *d)
+		}
+		derivedLock.Unlock()
+		sort.Slice(view, func(i, j int) bool { return view[i].Operation < view[j].Operation })
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(view)
+	})
+}
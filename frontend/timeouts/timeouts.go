@@ -0,0 +1,292 @@
+package timeouts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+// Duration is a time.Duration written as a string such as "500ms" in config files.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Operation holds the timeouts of calls to one downstream service.
+// Zero means no timeout.
+type Operation struct {
+	Dial    Duration `json:"dial"`
+	Request Duration `json:"request"`
+}
+
+// Server holds the timeouts of the HTTP server. They are only read at startup.
+type Server struct {
+	Read  Duration `json:"read"`
+	Write Duration `json:"write"`
+	Idle  Duration `json:"idle"`
+}
+
+// Config holds all timeouts of the service.
+type Config struct {
+	Server     Server               `json:"server"`
+	Operations map[string]Operation `json:"operations"`
+}
+
+// Default is used until a config file is loaded, and for operations
+// missing from it.
+var Default = Config{
+	Operations: map[string]Operation{
+		"customer": {Dial: Duration(30 * time.Second)},
+		"driver":   {Dial: Duration(30 * time.Second), Request: Duration(time.Second)},
+		"route":    {Dial: Duration(30 * time.Second)},
+		// The weather API is a third party, with a fallback when it is slow.
+		"weather": {Dial: Duration(time.Second), Request: Duration(2 * time.Second)},
+	},
+}
+
+var (
+	current atomic.Value
+	// requestOverrides are request timeouts set by command-line flags.
+	// They take precedence over the config file.
+	requestOverrides = map[string]Duration{}
+
+	// storeLock serializes store, called by reloads of the config file
+	// and by adaptive timeouts.
+	storeLock sync.Mutex
+	// base is the config loaded last, before the derived request timeouts
+	// and the overrides are applied.
+	base = &Default
+)
+
+func init() {
+	store(&Default)
+}
+
+// SetRequestTimeout overrides the request timeout of the operation, in
+// the active config and in every config loaded later. It must be called
+// before any calls are made, typically while parsing flags.
+func SetRequestTimeout(operation string, timeout time.Duration) {
+	requestOverrides[operation] = Duration(timeout)
+	store(nil)
+}
+
+// store makes a copy of config, with the derived request timeouts and the
+// request overrides applied, active. A nil config applies them to the
+// config loaded last again.
+func store(config *Config) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	if config != nil {
+		base = config
+	}
+	effective := *base
+	effective.Operations = make(map[string]Operation, len(base.Operations)+len(requestOverrides))
+	for name, op := range base.Operations {
+		effective.Operations[name] = op
+	}
+	for name, timeout := range derivedTimeouts() {
+		op, ok := effective.Operations[name]
+		if !ok {
+			op = Default.Operations[name]
+		}
+		op.Request = timeout
+		effective.Operations[name] = op
+	}
+	for name, timeout := range requestOverrides {
+		op, ok := effective.Operations[name]
+		if !ok {
+			op = Default.Operations[name]
+		}
+		op.Request = timeout
+		effective.Operations[name] = op
+	}
+	current.Store(&effective)
+}
+
+// Get returns the active config.
+func Get() *Config {
+	return current.Load().(*Config)
+}
+
+// For returns the active timeouts of the named operation.
+func For(operation string) Operation {
+	if op, ok := Get().Operations[operation]; ok {
+		return op
+	}
+	return Default.Operations[operation]
+}
+
+// WithRequestTimeout bounds ctx by the request timeout of the operation.
+// If ctx already has an earlier deadline, such as one of the incoming
+// request, that deadline is kept. The resulting deadline is logged on the
+// span in ctx.
+func WithRequestTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(For(operation).Request)
+	inherited, hasInherited := ctx.Deadline()
+
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if span := opentracing.SpanFromContext(ctx); span != nil && ok {
+		span.LogFields(
+			otlog.String("event", "request deadline"),
+			otlog.String("operation", operation),
+			otlog.String("timeout", timeout.String()),
+			otlog.String("remaining", time.Until(deadline).String()),
+			otlog.Bool("inherited", hasInherited && !inherited.After(deadline)),
+		)
+	}
+	return ctx, cancel
+}
+
+// ObservedTransport records, with adaptive timeouts, the latency of each
+// round trip to the operation, until the response headers arrive. Each
+// retry is a round trip of its own. Only round trips that got a response
+// below 400, or ran out of time, are recorded: fast failures, like those
+// of a chaos error or a refused connection, would drag the p99 below the
+// latency of the dependency. Calls an open breaker turns away never get
+// here. A round trip cut off by the deadline counts as lasting until it,
+// so a timeout that is too short grows back on its own.
+func ObservedTransport(operation string, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := next.RoundTrip(req)
+		if samples := latencies[operation]; samples != nil {
+			if err == nil && res.StatusCode < http.StatusBadRequest || timedOut(req.Context(), err) {
+				samples.add(time.Since(start))
+			}
+		}
+		return res, err
+	})
+}
+
+// UnaryClientLatency is ObservedTransport for gRPC calls: it records those
+// that succeed or run out of time.
+func UnaryClientLatency(operation string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if samples := latencies[operation]; samples != nil && (err == nil || timedOut(ctx, err)) {
+			samples.add(time.Since(start))
+		}
+		return err
+	}
+}
+
+// timedOut tells if err is the failure of a call cut off by the deadline of ctx.
+func timedOut(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == context.DeadlineExceeded
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Dialer returns a dial function that applies the dial timeout of the
+// operation active at the time of each dial.
+func Dialer(operation string) func(ctx context.Context, address string) (net.Conn, error) {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		d := net.Dialer{Timeout: time.Duration(For(operation).Dial), KeepAlive: 30 * time.Second}
+		return d.DialContext(ctx, "tcp", address)
+	}
+}
+
+// Transport returns an http.Transport that dials with the timeouts of the operation.
+func Transport(operation string) *http.Transport {
+	dial := Dialer(operation)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dial(ctx, address)
+	}
+	return transport
+}
+
+// Load reads the config file at path and makes it active.
+func Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	store(&config)
+	return nil
+}
+
+// Watch loads the config file at path, then reloads it whenever its
+// modification time changes. Reload errors are logged and the previous
+// config stays active.
+func Watch(path string, interval time.Duration, logger log.Logger) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := Load(path); err != nil {
+		return err
+	}
+
+	go func() {
+		modTime := info.ModTime()
+		for range time.Tick(interval) {
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			if err := Load(path); err != nil {
+				logger.Error("Cannot reload timeouts", zap.Error(err))
+				continue
+			}
+			logger.Info("Reloaded timeouts", zap.String("path", path))
+		}
+	}()
+	return nil
+}
+
+// Handler serves the active config as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Get())
+	})
+}
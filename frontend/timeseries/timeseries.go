@@ -0,0 +1,170 @@
+package timeseries
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the latency histogram kept per interval.
+var latencyBounds = [...]time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+// Point summarizes the requests of one interval.
+type Point struct {
+	Time      time.Time
+	Requests  int64
+	Errors    int64
+	RPS       float64
+	ErrorRate float64
+	// P99 is the upper bound of the latency bucket holding the 99th
+	// percentile, or the largest bound if it is above all of them.
+	P99 time.Duration
+}
+
+type bucket struct {
+	index    int64
+	requests int64
+	errors   int64
+	latency  [len(latencyBounds) + 1]int64
+}
+
+// Series keeps request counts, errors and latencies over the last
+// len(buckets) intervals in a fixed-size ring buffer.
+type Series struct {
+	interval time.Duration
+
+	lock    sync.Mutex
+	buckets []bucket
+}
+
+// NewSeries creates a series of size intervals of the given length.
+func NewSeries(interval time.Duration, size int) *Series {
+	return &Series{interval: interval, buckets: make([]bucket, size)}
+}
+
+// Record adds a request that took latency and failed if failed is true.
+func (s *Series) Record(now time.Time, latency time.Duration, failed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	index := now.UnixNano() / int64(s.interval)
+	b := &s.buckets[index%int64(len(s.buckets))]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+	b.requests++
+	if failed {
+		b.errors++
+	}
+	b.latency[sort.Search(len(latencyBounds), func(i int) bool { return latency <= latencyBounds[i] })]++
+}
+
+// Points returns one point per interval of the ring, oldest first, ending
+// with the interval that contains now.
+func (s *Series) Points(now time.Time) []Point {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	size := int64(len(s.buckets))
+	last := now.UnixNano() / int64(s.interval)
+	points := make([]Point, 0, size)
+	for index := last - size + 1; index <= last; index++ {
+		point := Point{Time: time.Unix(0, index*int64(s.interval))}
+		if b := s.buckets[index%size]; b.index == index && b.requests > 0 {
+			point.Requests = b.requests
+			point.Errors = b.errors
+			point.RPS = float64(b.requests) / s.interval.Seconds()
+			point.ErrorRate = float64(b.errors) / float64(b.requests)
+			point.P99 = b.percentile(0.99)
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func (b *bucket) percentile(p float64) time.Duration {
+	rank := int64(p*float64(b.requests) + 0.5)
+	var seen int64
+	for i, count := range b.latency {
+		seen += count
+		if seen >= rank && i < len(latencyBounds) {
+			return latencyBounds[i]
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// Set is a named group of series with the same interval and size.
+type Set struct {
+	interval time.Duration
+	size     int
+
+	lock   sync.Mutex
+	series map[string]*Series
+}
+
+// NewSet creates a new Set whose series keep size intervals of the given length.
+func NewSet(interval time.Duration, size int) *Set {
+	return &Set{interval: interval, size: size, series: make(map[string]*Series)}
+}
+
+// Series returns the series with the given name, creating it if needed.
+func (s *Set) Series(name string) *Series {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	series, ok := s.series[name]
+	if !ok {
+		series = NewSeries(s.interval, s.size)
+		s.series[name] = series
+	}
+	return series
+}
+
+// Handler records every request served by next in the named series.
+// Responses with a 5xx status count as errors.
+func (s *Set) Handler(name string, next http.Handler) http.Handler {
+	series := s.Series(name)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		series.Record(start, time.Since(start), sw.status >= http.StatusInternalServerError)
+	})
+}
+
+// ServeHTTP renders the points of every series as JSON, keyed by series name.
+func (s *Set) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	all := make(map[string]*Series, len(s.series))
+	for name, series := range s.series {
+		all[name] = series
+	}
+	s.lock.Unlock()
+
+	now := time.Now()
+	points := make(map[string][]Point, len(all))
+	for name, series := range all {
+		points[name] = series.Points(now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(points)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
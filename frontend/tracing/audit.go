@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Tags of the baggage audit trail, with comma-separated key names.
+const (
+	BaggageIngressTag = "baggage.ingress"
+	BaggageAddedTag   = "baggage.added"
+	BaggageRemovedTag = "baggage.removed"
+)
+
+// BaggageKeys returns the sorted keys of the baggage items of span that
+// have a value. Values are left out, as they may be personal data.
+func BaggageKeys(span opentracing.Span) []string {
+	var keys []string
+	span.Context().ForeachBaggageItem(func(key, value string) bool {
+		if value != "" {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// AuditBaggage tags the request span with the keys of the baggage items
+// the request came in with, if any, as baggage.ingress, and, when it is
+// done, with those the frontend added and removed while serving it, as
+// baggage.added and baggage.removed. Items dropped by the baggage
+// restrictions do not reach the span, and are counted in
+// baggage_violations instead. It must be the first middleware of the mux,
+// to see the baggage before the others change it.
+func AuditBaggage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := opentracing.SpanFromContext(r.Context())
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ingress := BaggageKeys(span)
+		if len(ingress) > 0 {
+			span.SetTag(BaggageIngressTag, strings.Join(ingress, ","))
+		}
+		defer func() {
+			added, removed := diffKeys(ingress, BaggageKeys(span))
+			if len(added) > 0 {
+				span.SetTag(BaggageAddedTag, strings.Join(added, ","))
+			}
+			if len(removed) > 0 {
+				span.SetTag(BaggageRemovedTag, strings.Join(removed, ","))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// diffKeys returns the keys of after that before lacks, and those of
+// before that after lacks. Both must be sorted.
+func diffKeys(before, after []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case j == len(after) || i < len(before) && before[i] < after[j]:
+			removed = append(removed, before[i])
+			i++
+		case i == len(before) || after[j] < before[i]:
+			added = append(added, after[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
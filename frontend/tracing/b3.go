@@ -0,0 +1,197 @@
+// The driver and sidecar modules build the B3 propagator from generated
+// copies of this file. Run go generate ./tracing in both after changing it.
+
+package tracing
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Headers of the Zipkin B3 formats.
+const (
+	b3SingleHeader       = "b3"
+	b3TraceIDHeader      = "x-b3-traceid"
+	b3SpanIDHeader       = "x-b3-spanid"
+	b3ParentSpanIDHeader = "x-b3-parentspanid"
+	b3SampledHeader      = "x-b3-sampled"
+	b3FlagsHeader        = "x-b3-flags"
+)
+
+// b3Propagator propagates the trace context in the Zipkin B3 headers: the
+// x-b3-* headers, as Istio and older Zipkin services expect them, or the
+// single b3 header. It reads both, whichever it writes, so callers of
+// either kind join the trace. Baggage is kept in the Jaeger baggage
+// headers, so baggage restrictions still apply.
+type b3Propagator struct {
+	single        bool
+	baggagePrefix string
+}
+
+// Inject implements jaeger.Injector
+func (p b3Propagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	sampled := "0"
+	if sc.IsSampled() {
+		sampled = "1"
+	}
+	if p.single {
+		value := fmt.Sprintf("%s-%016x-%s", b3TraceID(sc.TraceID()), uint64(sc.SpanID()), sampled)
+		if sc.ParentID() != 0 {
+			value += fmt.Sprintf("-%016x", uint64(sc.ParentID()))
+		}
+		writer.Set(b3SingleHeader, value)
+	} else {
+		writer.Set(b3TraceIDHeader, b3TraceID(sc.TraceID()))
+		writer.Set(b3SpanIDHeader, fmt.Sprintf("%016x", uint64(sc.SpanID())))
+		if sc.ParentID() != 0 {
+			writer.Set(b3ParentSpanIDHeader, fmt.Sprintf("%016x", uint64(sc.ParentID())))
+		}
+		writer.Set(b3SampledHeader, sampled)
+	}
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		writer.Set(p.baggagePrefix+k, url.QueryEscape(v))
+		return true
+	})
+	return nil
+}
+
+// Extract implements jaeger.Extractor
+func (p b3Propagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	var single, traceID, spanID, sampled, parentID string
+	var baggage map[string]string
+	prefix := strings.ToLower(p.baggagePrefix)
+	err := reader.ForeachKey(func(key, value string) error {
+		switch key = strings.ToLower(key); {
+		case key == b3SingleHeader:
+			single = value
+		case key == b3TraceIDHeader:
+			traceID = value
+		case key == b3SpanIDHeader:
+			spanID = value
+		case key == b3SampledHeader:
+			if value == "true" {
+				value = "1"
+			}
+			if sampled != "d" {
+				sampled = value
+			}
+		case key == b3ParentSpanIDHeader:
+			parentID = value
+		case key == b3FlagsHeader:
+			if value == "1" {
+				sampled = "d"
+			}
+		case strings.HasPrefix(key, prefix):
+			if v, err := url.QueryUnescape(value); err == nil {
+				value = v
+			}
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[key[len(prefix):]] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+
+	var sc jaeger.SpanContext
+	switch {
+	case single != "":
+		sc = parseB3Single(single)
+	case traceID != "" || spanID != "":
+		sc = newB3Context(traceID, spanID, sampled, parentID)
+	case sampled == "0":
+		sc = b3Denied()
+	case len(baggage) > 0:
+		return jaeger.NewSpanContext(jaeger.TraceID{}, 0, 0, false, baggage), nil
+	default:
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	if !sc.IsValid() {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return jaeger.NewSpanContext(sc.TraceID(), sc.SpanID(), sc.ParentID(), sc.IsSampled(), baggage), nil
+}
+
+// b3TraceID formats id with 16 or 32 hex digits, as B3 requires; Jaeger
+// leaves out leading zeros.
+func b3TraceID(id jaeger.TraceID) string {
+	if id.High == 0 {
+		return fmt.Sprintf("%016x", id.Low)
+	}
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
+
+// parseB3Single parses a b3 header, {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+// with the last two parts optional, e.g.
+// 80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90.
+// A missing sampling state, which defers the decision to the receiver, is
+// taken as sampled. The deny form "0" asks not to trace the request, and
+// gets an unsampled context. It returns an invalid context if value is
+// malformed or only carries another sampling state.
+func parseB3Single(value string) jaeger.SpanContext {
+	value = strings.TrimSpace(value)
+	if value == "0" {
+		return b3Denied()
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return jaeger.SpanContext{}
+	}
+	parts = append(parts, "", "")
+	return newB3Context(parts[0], parts[1], parts[2], parts[3])
+}
+
+// b3Denied returns the context of a request that asked not to be traced,
+// with "b3: 0" or a lone "X-B3-Sampled: 0". Jaeger ignores the sampling
+// state of a context without IDs and lets its sampler decide, so the
+// context gets random IDs to carry the decision.
+func b3Denied() jaeger.SpanContext {
+	// #nosec
+	return jaeger.NewSpanContext(jaeger.TraceID{Low: rand.Uint64() | 1}, jaeger.SpanID(rand.Uint64()|1), 0, false, nil)
+}
+
+// newB3Context creates a span context from the parts of a B3 trace context:
+// a trace ID of 16 or 32 hex digits, a span ID of 16, a sampling state of
+// "1", "0", "d" for debug, or empty if deferred, and an optional parent
+// span ID. It returns an invalid context if any part is malformed.
+func newB3Context(traceID, spanID, sampled, parentID string) jaeger.SpanContext {
+	if (len(traceID) != 16 && len(traceID) != 32) || len(spanID) != 16 || (parentID != "" && len(parentID) != 16) {
+		return jaeger.SpanContext{}
+	}
+	tid, err := jaeger.TraceIDFromString(traceID)
+	if err != nil {
+		return jaeger.SpanContext{}
+	}
+	sid, err := jaeger.SpanIDFromString(spanID)
+	if err != nil {
+		return jaeger.SpanContext{}
+	}
+	var pid jaeger.SpanID
+	if parentID != "" {
+		if pid, err = jaeger.SpanIDFromString(parentID); err != nil {
+			return jaeger.SpanContext{}
+		}
+	}
+	switch sampled {
+	case "", "0", "1", "d":
+	default:
+		return jaeger.SpanContext{}
+	}
+	return jaeger.NewSpanContext(tid, sid, pid, sampled != "0", nil)
+}
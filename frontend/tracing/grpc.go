@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+)
+
+// UnaryClientInterceptor traces gRPC calls like otgrpc's client interceptor,
+// but also puts the client span in the context it passes on, so the
+// interceptors after it in the chain can annotate the span of the call
+// rather than its parent.
+func UnaryClientInterceptor(tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var parent opentracing.SpanContext
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			parent = span.Context()
+		}
+		span := tracer.StartSpan(method, opentracing.ChildOf(parent), ext.SpanKindRPCClient, opentracing.Tag{Key: string(ext.Component), Value: "gRPC"})
+		defer span.Finish()
+
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.New(nil)
+		}
+		if err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, metadataWriter(md)); err != nil {
+			span.LogFields(log.String("event", "Tracer.Inject() failed"), log.Error(err))
+		}
+		if token := auth.TokenFromContext(ctx); token != "" {
+			md.Set("authorization", "Bearer "+token)
+		}
+		ctx = opentracing.ContextWithSpan(metadata.NewOutgoingContext(ctx, md), span)
+		ctx = context.WithValue(ctx, clientSpanKey{}, span)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil {
+			otgrpc.SetSpanTags(span, err, true)
+			span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+		}
+		return err
+	}
+}
+
+// clientSpanKey holds the client span UnaryClientInterceptor started, which
+// is also the active span unless a later interceptor starts another.
+type clientSpanKey struct{}
+
+// clientSpanFromContext returns the span of the gRPC call started by
+// UnaryClientInterceptor, or nil outside of it.
+func clientSpanFromContext(ctx context.Context) opentracing.Span {
+	span, _ := ctx.Value(clientSpanKey{}).(opentracing.Span)
+	return span
+}
+
+// metadataWriter carries span contexts in gRPC metadata, whose keys must be
+// lower case.
+type metadataWriter metadata.MD
+
+func (w metadataWriter) Set(key, val string) {
+	key = strings.ToLower(key)
+	w[key] = append(w[key], val)
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// TLSHandshakeTag is the time in milliseconds a client call spent on TLS
+// handshakes, when it opened TLS connections.
+const TLSHandshakeTag = "tls.handshake_ms"
+
+// traceTLS logs the TLS handshakes of new connections req opens on the
+// client span of ht, or on the span of ctx without one, with the time they
+// took and what they negotiated. Calls over reused connections have no
+// handshake.
+func traceTLS(ctx context.Context, req *http.Request, ht *nethttp.Tracer) *http.Request {
+	// A dial abandoned by an attempt may still be handshaking when the
+	// next attempt dials.
+	var lock sync.Mutex
+	var start time.Time
+	var total time.Duration
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			lock.Lock()
+			start = time.Now()
+			lock.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			span := ht.Span()
+			if span == nil {
+				span = opentracing.SpanFromContext(ctx)
+			}
+			if span == nil {
+				return
+			}
+			took := time.Since(start)
+			total += took
+			span.SetTag(TLSHandshakeTag, total.Milliseconds())
+			fields := []log.Field{log.String("event", "TLS handshake"), log.String("duration", took.String())}
+			if err != nil {
+				span.LogFields(append(fields, log.Error(err))...)
+				return
+			}
+			fields = append(fields,
+				log.String("tls.version", tls.VersionName(state.Version)),
+				log.String("tls.cipher_suite", tls.CipherSuiteName(state.CipherSuite)),
+				log.Bool("tls.resumed", state.DidResume))
+			if len(state.PeerCertificates) > 0 {
+				fields = append(fields, log.String("tls.peer", state.PeerCertificates[0].Subject.String()))
+			}
+			span.LogFields(fields...)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
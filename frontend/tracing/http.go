@@ -1,20 +1,48 @@
 package tracing
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/superliuwr/jaeger-demo/frontend/auth"
+	"github.com/superliuwr/jaeger-demo/frontend/requestid"
+	"github.com/superliuwr/jaeger-demo/frontend/schema"
 )
 
 // HTTPClient wraps an http.Client with tracing instrumentation.
 type HTTPClient struct {
 	Tracer opentracing.Tracer
 	Client *http.Client
+	// Retry configures retries of failed requests. The zero value makes a
+	// single attempt.
+	Retry RetryPolicy
+	// Validator checks response bodies against the client's JSON schema.
+	// Nil skips the check.
+	Validator *schema.Validator
+	// External marks a client of a third-party API, which gets neither the
+	// trace context, nor the request ID and the bearer token of the caller.
+	External bool
+}
+
+// StatusError is the error of a response with a status of 400 or above.
+// Its text is the body of the response.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return e.Body
 }
 
 // GetJSON executes HTTP GET against specified url and tried to parse
@@ -24,27 +52,168 @@ func (c *HTTPClient) GetJSON(ctx context.Context, endpoint string, url string, o
 	if err != nil {
 		return err
 	}
+	if !c.External {
+		forwardHeaders(ctx, req)
+	}
 
 	req = req.WithContext(ctx)
-	req, ht := nethttp.TraceRequest(c.Tracer, req, nethttp.OperationName("HTTP GET: "+endpoint))
+	req, ht := nethttp.TraceRequest(c.Tracer, req, nethttp.OperationName("HTTP GET: "+endpoint), nethttp.InjectSpanContext(!c.External))
+	req = traceTLS(ctx, req, ht)
 	defer ht.Finish()
 
-	res, err := c.Client.Do(req)
+	res, err := c.do(ctx, req, ht)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	counted := &sizeReader{r: res.Body}
+	defer func() { tagSize(ht.Span(), ResponseSizeTag, counted.n) }()
+
+	if res.StatusCode >= 400 {
+		body, err := ioutil.ReadAll(counted)
+		if err != nil {
+			return err
+		}
+
+		return &StatusError{StatusCode: res.StatusCode, Body: string(body)}
+	}
+
+	var body io.Reader = counted
+	if c.Validator != nil {
+		data, err := ioutil.ReadAll(counted)
+		if err != nil {
+			return err
+		}
+		if span := ht.Span(); span != nil {
+			ctx = opentracing.ContextWithSpan(ctx, span)
+		}
+		c.Validator.Check(ctx, endpoint, data)
+		body = bytes.NewReader(data)
+	}
+
+	decoder := json.NewDecoder(body)
+	if err := decoder.Decode(out); err != nil {
+		if span := ht.Span(); span != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "cannot decode response"), log.Error(err))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// PostJSON executes HTTP POST of in, encoded as JSON, against specified url.
+// Responses with a status of 400 or above are returned as errors.
+func (c *HTTPClient) PostJSON(ctx context.Context, endpoint string, url string, in interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	if !c.External {
+		forwardHeaders(ctx, req)
+	}
 
+	req = req.WithContext(ctx)
+	req, ht := nethttp.TraceRequest(c.Tracer, req, nethttp.OperationName("HTTP POST: "+endpoint), nethttp.InjectSpanContext(!c.External))
+	req = traceTLS(ctx, req, ht)
+	defer ht.Finish()
+
+	res, err := c.do(ctx, req, ht)
+	// the client span only starts with the first attempt
+	tagSize(ht.Span(), RequestSizeTag, int64(len(data)))
+	if err != nil {
+		return err
+	}
 	defer res.Body.Close()
+	counted := &sizeReader{r: res.Body}
+	defer func() { tagSize(ht.Span(), ResponseSizeTag, counted.n) }()
 
 	if res.StatusCode >= 400 {
-		body, err := ioutil.ReadAll(res.Body)
+		body, err := ioutil.ReadAll(counted)
 		if err != nil {
 			return err
 		}
+		return &StatusError{StatusCode: res.StatusCode, Body: string(body)}
+	}
+	_, _ = io.Copy(ioutil.Discard, counted)
+	return nil
+}
 
-		return errors.New(string(body))
+// forwardHeaders forwards the request ID and the bearer token in ctx, if
+// any, in the headers of req.
+func forwardHeaders(ctx context.Context, req *http.Request) {
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	if token := auth.TokenFromContext(ctx); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
+}
+
+// do executes req, retrying it as configured by the Retry policy. Every
+// attempt runs in its own child span; attempts other than a first successful
+// one are logged on the client span. A Retry-After header on 429 and 503
+// responses overrides the backoff, and a backoff longer than MaxRetryAfter
+// is not waited for.
+func (c *HTTPClient) do(ctx context.Context, req *http.Request, ht *nethttp.Tracer) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		res, err := c.Client.Do(req)
+		retry := err == nil && c.Retry.retryable(res.StatusCode) || err != nil && ctx.Err() == nil
+		if attempt == 1 && err == nil && !retry {
+			return res, nil
+		}
+
+		span := ht.Span()
+		if span == nil {
+			span = opentracing.SpanFromContext(ctx)
+		}
+		fields := []log.Field{log.String("event", "attempt"), log.Int("attempt", attempt)}
+		if err != nil {
+			fields = append(fields, log.Error(err))
+		} else {
+			fields = append(fields, log.Int("status", res.StatusCode))
+		}
+		if span != nil {
+			span.SetTag("http.attempts", attempt)
+			span.LogFields(fields...)
+		}
+		if !retry || attempt >= c.Retry.MaxAttempts {
+			return res, err
+		}
 
-	decoder := json.NewDecoder(res.Body)
-	return decoder.Decode(out)
+		wait := c.Retry.backoff(attempt)
+		if err == nil {
+			if after, ok := retryAfter(res); ok {
+				if span != nil {
+					span.SetTag("http.retry_after", after.String())
+					span.LogFields(log.String("event", "server-directed backoff"), log.String("wait", after.String()))
+				}
+				if after > MaxRetryAfter {
+					return res, nil
+				}
+				wait = after
+			}
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 }
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"expvar"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Header of a trace context format that is only accepted on ingress.
+const (
+	xrayHeader = "x-amzn-trace-id"
+)
+
+// Names of the ingress formats, as counted in ingressConversions.
+const (
+	ingressB3Single = "b3-single"
+	ingressXRay     = "xray"
+)
+
+// ingressConversions counts, per format, the requests whose trace context
+// was converted from an ingress format.
+var ingressConversions = expvar.NewMap("trace_context_conversions")
+
+// ingressExtractor extracts the trace context in the configured format,
+// and falls back to the Zipkin B3 single header and the AWS X-Ray header
+// for callers that only send one of those. The trace then continues in
+// the configured format, since the context is only converted on the way
+// in. A request without a trace context may ask for a debug trace with
+// the jaeger-debug-id header, whatever the format: the trace it starts is
+// sampled and its root span tagged with the ID, to search for in Jaeger.
+type ingressExtractor struct {
+	extractor jaeger.Extractor
+	headers   *jaeger.HeadersConfig
+}
+
+// Extract implements jaeger.Extractor
+func (e ingressExtractor) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	sc, err := e.extractor.Extract(carrier)
+	if err == nil && sc.IsValid() {
+		return sc, nil
+	}
+	if err != nil && err != opentracing.ErrSpanContextNotFound {
+		return sc, err
+	}
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	var b3, xray, debugID string
+	_ = reader.ForeachKey(func(key, value string) error {
+		switch strings.ToLower(key) {
+		case b3SingleHeader:
+			b3 = value
+		case xrayHeader:
+			xray = value
+		case e.headers.JaegerDebugHeader:
+			debugID = value
+		}
+		return nil
+	})
+
+	var converted jaeger.SpanContext
+	var format string
+	switch {
+	case b3 != "":
+		converted, format = parseB3Single(b3), ingressB3Single
+	case xray != "":
+		converted, format = parseXRay(xray), ingressXRay
+	}
+	if !converted.IsValid() {
+		if debugID != "" {
+			return e.debugContext(debugID, sc), nil
+		}
+		return sc, err
+	}
+	ingressConversions.Add(format, 1)
+	// Baggage still comes from the configured format.
+	baggage := make(map[string]string)
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		baggage[k] = v
+		return true
+	})
+	return jaeger.NewSpanContext(converted.TraceID(), converted.SpanID(), converted.ParentID(), converted.IsSampled(), baggage), nil
+}
+
+// debugContext returns a context that only carries debugID and the baggage
+// of sc, for the tracer to start a debug trace from. Jaeger span contexts
+// can't be given a debug ID directly, so it is extracted like the Jaeger
+// format would.
+func (e ingressExtractor) debugContext(debugID string, sc jaeger.SpanContext) jaeger.SpanContext {
+	carrier := opentracing.TextMapCarrier{e.headers.JaegerDebugHeader: debugID}
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		carrier[e.headers.TraceBaggageHeaderPrefix+k] = v
+		return true
+	})
+	debug, err := jaeger.NewTextMapPropagator(e.headers, *jaeger.NewNullMetrics()).Extract(carrier)
+	if err != nil {
+		return sc
+	}
+	return debug
+}
+
+// parseXRay parses an X-Amzn-Trace-Id header, e.g.
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1.
+// The version and epoch of the root make up the high half of the trace ID.
+// A missing or undecided Sampled field is taken as sampled. It returns an
+// invalid context if value is malformed or has no Parent, which load
+// balancers leave out.
+func parseXRay(value string) jaeger.SpanContext {
+	var root, parent string
+	sampled := true
+	for _, field := range strings.Split(value, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			root = kv[1]
+		case "Parent":
+			parent = kv[1]
+		case "Sampled":
+			sampled = kv[1] != "0"
+		}
+	}
+	parts := strings.Split(root, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 || len(parent) != 16 {
+		return jaeger.SpanContext{}
+	}
+	traceID, err := jaeger.TraceIDFromString(parts[1] + parts[2])
+	if err != nil {
+		return jaeger.SpanContext{}
+	}
+	spanID, err := jaeger.SpanIDFromString(parent)
+	if err != nil {
+		return jaeger.SpanContext{}
+	}
+	return jaeger.NewSpanContext(traceID, spanID, 0, sampled, nil)
+}
@@ -4,14 +4,19 @@ import (
 	"fmt"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 	"go.uber.org/zap"
 
 	"github.com/superliuwr/jaeger-demo/frontend/log"
 )
 
-// Init creates a new instance of Jaeger tracer.
-func Init(serviceName string, logger log.Factory) opentracing.Tracer {
+// Init creates a new instance of Jaeger tracer, configured from the JAEGER_*
+// env vars and opts.
+func Init(serviceName string, opts ...Option) opentracing.Tracer {
+	o := newOptions(opts)
+	logger := o.logger
+
 	// Read host and port from Env Vars
 	cfg, err := config.FromEnv()
 	if err != nil {
@@ -19,15 +24,55 @@ func Init(serviceName string, logger log.Factory) opentracing.Tracer {
 	}
 
 	cfg.ServiceName = serviceName
+	cfg.Tags = append(cfg.Tags, o.tags...)
 	// Always sample all requests
 	cfg.Sampler.Type = "const"
 	cfg.Sampler.Param = 1
 
 	jaegerLogger := jaegerLoggerAdapter{logger.Bg()}
 
-	tracer, _, err := cfg.NewTracer(
+	restrictions, err := baggageRestrictionsFromEnv()
+	if err != nil {
+		logger.Bg().Fatal("cannot parse baggage restrictions", zap.Error(err))
+	}
+	sampler := o.sampler
+	if sampler == nil {
+		if sampler, err = samplerFromEnv(); err != nil {
+			logger.Bg().Fatal("cannot parse sampling overrides", zap.Error(err))
+		}
+	}
+	if o.remote != nil {
+		metrics := jaeger.NewNullMetrics()
+		if o.metrics != nil {
+			metrics = jaeger.NewMetrics(o.metrics, nil)
+		}
+		sampler = o.remote.sampler(serviceName, sampler, jaegerLogger, metrics)
+	}
+	headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	propagator, err := newPropagator(o.propagation, headers)
+	if err != nil {
+		logger.Bg().Fatal("cannot create propagator", zap.Error(err))
+	}
+	extractor := newRestrictedExtractor(ingressExtractor{extractor: propagator, headers: headers}, headers, restrictions, logger.Bg())
+
+	configOptions := []config.Option{
 		config.Logger(jaegerLogger),
-	)
+		config.Injector(opentracing.HTTPHeaders, propagator),
+		config.Extractor(opentracing.HTTPHeaders, extractor),
+		// traceparent carries 128-bit trace IDs
+		config.Gen128Bit(o.propagation == PropagationW3C),
+		config.Sampler(sampler),
+	}
+	if o.reporter != nil {
+		configOptions = append(configOptions, config.Reporter(o.reporter))
+	}
+	if o.metrics != nil {
+		configOptions = append(configOptions, config.Metrics(o.metrics))
+	}
+	for _, observer := range o.observers {
+		configOptions = append(configOptions, config.ContribObserver(observer))
+	}
+	tracer, _, err := cfg.NewTracer(configOptions...)
 	if err != nil {
 		logger.Bg().Fatal("cannot initialize Jaeger Tracer", zap.Error(err))
 	}
@@ -2,6 +2,8 @@ package tracing
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
@@ -17,22 +19,99 @@ func NewServeMux(tracer opentracing.Tracer) *TracedServeMux {
 
 // TracedServeMux is a wrapper around http.ServeMux that instruments handlers for tracing.
 type TracedServeMux struct {
-	mux    *http.ServeMux
-	tracer opentracing.Tracer
+	mux        *http.ServeMux
+	tracer     opentracing.Tracer
+	middleware []func(pattern string, next http.Handler) http.Handler
 }
 
-// Handle implements http.ServeMux#Handle
-func (tm *TracedServeMux) Handle(pattern string, handler http.Handler) {
-	middleware := nethttp.Middleware(
+// Use adds middleware that runs inside the tracing middleware, so it can
+// annotate the request span. It only applies to handlers registered later.
+func (tm *TracedServeMux) Use(middleware func(http.Handler) http.Handler) {
+	tm.UseRoute(func(_ string, next http.Handler) http.Handler { return middleware(next) })
+}
+
+// UseRoute is like Use, for middleware that needs the pattern the handler
+// is registered with.
+func (tm *TracedServeMux) UseRoute(middleware func(pattern string, next http.Handler) http.Handler) {
+	tm.middleware = append(tm.middleware, middleware)
+}
+
+// Handle registers handler for pattern and the given methods (GET, which
+// also matches HEAD, if none are given) with Go 1.22 method patterns.
+// OPTIONS is answered with the Allow header, and http.ServeMux itself
+// answers any other method with 405 Method Not Allowed.
+func (tm *TracedServeMux) Handle(pattern string, handler http.Handler, methods ...string) {
+	tm.register(pattern, tm.traced(pattern, handler), methods)
+}
+
+// HandleStream is like Handle, for handlers that stream their response
+// for longer than the server's WriteTimeout allows. The write deadline of
+// each request is set to writeTimeout from its start, or removed if it is zero.
+func (tm *TracedServeMux) HandleStream(pattern string, writeTimeout time.Duration, handler http.Handler, methods ...string) {
+	tm.register(pattern, withWriteTimeout(writeTimeout, tm.traced(pattern, handler)), methods)
+}
+
+func (tm *TracedServeMux) traced(pattern string, handler http.Handler) http.Handler {
+	for i := len(tm.middleware) - 1; i >= 0; i-- {
+		handler = tm.middleware[i](pattern, handler)
+	}
+	handler = tagScheme(handler)
+	return nethttp.Middleware(
 		tm.tracer,
 		handler,
 		nethttp.OperationNameFunc(func(r *http.Request) string {
 			return "HTTP " + r.Method + " " + pattern
 		}))
-	tm.mux.Handle(pattern, middleware)
+}
+
+func (tm *TracedServeMux) register(pattern string, handler http.Handler, methods []string) {
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet}
+	}
+	allow := make([]string, 0, len(methods)+2)
+	for _, method := range methods {
+		tm.mux.Handle(method+" "+pattern, handler)
+		allow = append(allow, method)
+		if method == http.MethodGet {
+			allow = append(allow, http.MethodHead)
+		}
+	}
+	allow = append(allow, http.MethodOptions)
+	tm.mux.HandleFunc(http.MethodOptions+" "+pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
 }
 
 // ServeHTTP implements http.ServeMux#ServeHTTP
 func (tm *TracedServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	tm.mux.ServeHTTP(w, r)
 }
+
+// tagScheme tags the request span with the scheme of the request as
+// http.scheme, which the http.url tag of server spans leaves out.
+func tagScheme(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+			span.SetTag("http.scheme", scheme)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withWriteTimeout must wrap the tracing middleware: the response writer
+// it passes on does not unwrap to the connection's.
+func withWriteTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var deadline time.Time
+		if timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		_ = http.NewResponseController(w).SetWriteDeadline(deadline)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+// Option configures the tracer created by Init or InitOTel.
+type Option func(*options)
+
+type options struct {
+	logger      log.Factory
+	propagation string
+	tags        []opentracing.Tag
+	sampler     jaeger.Sampler
+	remote      *remoteSampling
+	reporter    jaeger.Reporter
+	metrics     metrics.Factory
+	observers   []jaeger.ContribObserver
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		logger:      log.NewFactory(zap.NewNop(), zapcore.InfoLevel),
+		propagation: PropagationJaeger,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithLogger logs the errors of the tracer and fatal setup errors to
+// logger. By default nothing is logged.
+func WithLogger(logger log.Factory) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithPropagation propagates the trace context in the given format, one of
+// the Propagation constants. The default is PropagationJaeger.
+func WithPropagation(format string) Option {
+	return func(o *options) { o.propagation = format }
+}
+
+// WithTags adds tags to every span, or to the resource with InitOTel.
+func WithTags(tags ...opentracing.Tag) Option {
+	return func(o *options) { o.tags = append(o.tags, tags...) }
+}
+
+// WithSampler replaces the sampler, which by default samples every trace
+// unless SAMPLING_OPERATIONS says otherwise. Ignored by InitOTel.
+func WithSampler(sampler jaeger.Sampler) Option {
+	return func(o *options) { o.sampler = sampler }
+}
+
+// WithRemoteSampling polls the sampling server at serverURL, such as the
+// Jaeger agent's http://jaeger:5778/sampling, every refresh for the
+// strategy of the service. Until the first strategy arrives, and whenever
+// the server cannot be reached, traces are sampled as without this option.
+// Ignored by InitOTel.
+func WithRemoteSampling(serverURL string, refresh time.Duration) Option {
+	return func(o *options) { o.remote = &remoteSampling{serverURL: serverURL, refresh: refresh} }
+}
+
+// WithReporter replaces the reporter, which by default sends spans where
+// the JAEGER_* env vars say. Ignored by InitOTel.
+func WithReporter(reporter jaeger.Reporter) Option {
+	return func(o *options) { o.reporter = reporter }
+}
+
+// WithMetrics reports the tracer's own metrics, like spans started and
+// dropped, to factory. By default they are not reported. Ignored by
+// InitOTel.
+func WithMetrics(factory metrics.Factory) Option {
+	return func(o *options) { o.metrics = factory }
+}
+
+// WithObserver notifies observer of every span the tracer starts, sampled
+// or not. Ignored by InitOTel.
+func WithObserver(observer jaeger.ContribObserver) Option {
+	return func(o *options) { o.observers = append(o.observers, observer) }
+}
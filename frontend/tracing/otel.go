@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	jaegerpropagator "go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.uber.org/zap"
+)
+
+// OTelTracer is an OpenTracing tracer backed by the OpenTelemetry SDK.
+// Spans are exported over OTLP, so existing OpenTracing instrumentation
+// reports through the OpenTelemetry pipeline unchanged.
+type OTelTracer struct {
+	*otbridge.BridgeTracer
+	provider *sdktrace.TracerProvider
+}
+
+// InitOTel creates a tracer that exports spans with the OpenTelemetry SDK,
+// configured by opts. The OTLP endpoint is read from the standard
+// OTEL_EXPORTER_OTLP_* env vars.
+func InitOTel(serviceName string, opts ...Option) opentracing.Tracer {
+	o := newOptions(opts)
+	logger := o.logger
+
+	propagator, err := newOTelPropagator(o.propagation)
+	if err != nil {
+		logger.Bg().Fatal("cannot create propagator", zap.Error(err))
+	}
+
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		logger.Bg().Fatal("cannot create OTLP exporter", zap.Error(err))
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName(serviceName)}
+	for _, tag := range o.tags {
+		attrs = append(attrs, attribute.String(tag.Key, fmt.Sprint(tag.Value)))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+	)
+
+	bridge, wrapper := otbridge.NewTracerPair(provider.Tracer(serviceName))
+	bridge.SetTextMapPropagator(propagator)
+	bridge.SetWarningHandler(func(msg string) { logger.Bg().Info(msg) })
+	otel.SetTracerProvider(wrapper)
+	otel.SetTextMapPropagator(propagator)
+
+	return &OTelTracer{BridgeTracer: bridge, provider: provider}
+}
+
+func newOTelPropagator(format string) (propagation.TextMapPropagator, error) {
+	switch format {
+	case PropagationJaeger:
+		return jaegerpropagator.Jaeger{}, nil
+	case PropagationW3C:
+		return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}), nil
+	case PropagationB3:
+		return b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)), nil
+	case PropagationB3Single:
+		return b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)), nil
+	}
+	return nil, fmt.Errorf("unknown propagation format %q", format)
+}
+
+// Close flushes buffered spans to the exporter.
+func (t *OTelTracer) Close() error {
+	return t.provider.Shutdown(context.Background())
+}
+
+// Transport wraps next with client span instrumentation for the given
+// tracer: otelhttp for an OTelTracer, nethttp otherwise.
+func Transport(tracer opentracing.Tracer, next http.RoundTripper) http.RoundTripper {
+	if _, ok := tracer.(*OTelTracer); ok {
+		return otelhttp.NewTransport(next)
+	}
+	return &nethttp.Transport{RoundTripper: next}
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Formats of the trace context in HTTP headers and gRPC metadata.
+const (
+	PropagationJaeger = "jaeger"
+	PropagationW3C    = "w3c"
+	PropagationB3     = "b3"
+	// PropagationB3Single writes the single b3 header instead of the
+	// x-b3-* headers of PropagationB3. Both read either.
+	PropagationB3Single = "b3-single"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	baggageHeader     = "baggage"
+)
+
+type propagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+func newPropagator(format string, headers *jaeger.HeadersConfig) (propagator, error) {
+	switch format {
+	case PropagationJaeger:
+		return jaeger.NewHTTPHeaderPropagator(headers, *jaeger.NewNullMetrics()), nil
+	case PropagationW3C:
+		return w3cPropagator{}, nil
+	case PropagationB3, PropagationB3Single:
+		return b3Propagator{single: format == PropagationB3Single, baggagePrefix: headers.TraceBaggageHeaderPrefix}, nil
+	}
+	return nil, fmt.Errorf("unknown propagation format %q", format)
+}
+
+// w3cPropagator propagates the trace context in the W3C traceparent header
+// and baggage in the W3C baggage header. The tracestate header is not
+// forwarded, since Jaeger span contexts have no place to keep it.
+type w3cPropagator struct{}
+
+// traceparent formats sc as a W3C traceparent header value.
+func traceparent(sc jaeger.SpanContext) string {
+	var flags byte
+	if sc.IsSampled() {
+		flags = 1
+	}
+	traceID := sc.TraceID()
+	return fmt.Sprintf("00-%016x%016x-%016x-%02x", traceID.High, traceID.Low, uint64(sc.SpanID()), flags)
+}
+
+// Inject implements jaeger.Injector
+func (w3cPropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	writer.Set(traceparentHeader, traceparent(sc))
+
+	var items []string
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		items = append(items, k+"="+url.PathEscape(v))
+		return true
+	})
+	if len(items) > 0 {
+		writer.Set(baggageHeader, strings.Join(items, ","))
+	}
+	return nil
+}
+
+// Extract implements jaeger.Extractor
+func (w3cPropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+	var traceparent string
+	var baggage map[string]string
+	err := reader.ForeachKey(func(key, value string) error {
+		switch strings.ToLower(key) {
+		case traceparentHeader:
+			traceparent = value
+		case baggageHeader:
+			baggage = parseW3CBaggage(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	if traceparent == "" {
+		if len(baggage) == 0 {
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+		}
+		return jaeger.NewSpanContext(jaeger.TraceID{}, 0, 0, false, baggage), nil
+	}
+
+	// version-traceid-parentid-flags, e.g. 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	traceID, err := jaeger.TraceIDFromString(parts[1])
+	if err != nil || !traceID.IsValid() {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	spanID, err := jaeger.SpanIDFromString(parts[2])
+	if err != nil || spanID == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return jaeger.NewSpanContext(traceID, spanID, 0, flags&1 == 1, baggage), nil
+}
+
+// parseW3CBaggage parses the "key1=value1,key2=value2;property" list of the
+// baggage header. Properties are dropped.
+func parseW3CBaggage(list string) map[string]string {
+	baggage := make(map[string]string)
+	for _, member := range strings.Split(list, ",") {
+		member = strings.SplitN(member, ";", 2)[0]
+		kv := strings.SplitN(member, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			continue
+		}
+		baggage[strings.TrimSpace(kv[0])] = value
+	}
+	return baggage
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers that return the trace of a request to its caller.
+const (
+	TraceIDHeader       = "X-Trace-Id"
+	traceresponseHeader = "traceresponse"
+)
+
+// TraceResponse returns the trace of every request in the response: the
+// trace ID, ready to paste into the Jaeger UI, in X-Trace-Id, and the
+// trace and span ID in the W3C traceresponse header. It must run inside
+// the tracing middleware.
+func TraceResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := TraceID(r.Context()); id != "" {
+			w.Header().Set(TraceIDHeader, id)
+		}
+		if value := traceresponse(r); value != "" {
+			w.Header().Set(traceresponseHeader, value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceresponse formats the span of r like a traceparent header, with
+// either the Jaeger or the OpenTelemetry tracer.
+func traceresponse(r *http.Request) string {
+	span := opentracing.SpanFromContext(r.Context())
+	if span == nil {
+		return ""
+	}
+	if sc, ok := span.Context().(jaeger.SpanContext); ok {
+		return traceparent(sc)
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxRetryAfter is the longest server-directed backoff a client waits for.
+// Responses asking for more are returned as errors.
+const MaxRetryAfter = 5 * time.Second
+
+// RetryPolicy configures how HTTPClient retries failed requests.
+type RetryPolicy struct {
+	// MaxAttempts is the most attempts made per request, including the first.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It doubles with every
+	// further retry, up to MaxBackoff. A Retry-After header takes precedence.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// RetryableStatus lists the response status codes that are retried.
+	// Requests that fail without a response are always retried.
+	RetryableStatus []int
+}
+
+// DefaultRetryPolicy makes up to 3 attempts, retrying rate limited and
+// unavailable responses as well as failed connections.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     100 * time.Millisecond,
+	MaxBackoff:  time.Second,
+	RetryableStatus: []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+func (p RetryPolicy) retryable(status int) bool {
+	for _, s := range p.RetryableStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the given failed attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// retryAfter returns the backoff a 429 or 503 response asks for in its
+// Retry-After header, given either in seconds or as an HTTP date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := time.Until(date); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// Tags with the size in bytes of the request and response bodies of a
+// client call, set by HTTPClient and UnaryClientSizes.
+const (
+	RequestSizeTag  = "request.size"
+	ResponseSizeTag = "response.size"
+)
+
+// UnaryClientSizes tags the client span of each gRPC call with the encoded
+// size of its request and reply. It must come after UnaryClientInterceptor
+// in the chain. It only tags the span that interceptor started, so when it
+// is chained before it, or after otgrpc's interceptor, which does not pass
+// its span on, it tags nothing rather than the parent span.
+func UnaryClientSizes() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if span := clientSpanFromContext(ctx); span != nil {
+			if msg, ok := req.(proto.Message); ok {
+				span.SetTag(RequestSizeTag, proto.Size(msg))
+			}
+			if msg, ok := reply.(proto.Message); ok && err == nil {
+				span.SetTag(ResponseSizeTag, proto.Size(msg))
+			}
+		}
+		return err
+	}
+}
+
+// sizeReader counts the bytes read through it.
+type sizeReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *sizeReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// tagSize sets the size tag key on span, if there is one.
+func tagSize(span opentracing.Span, key string, size int64) {
+	if span != nil {
+		span.SetTag(key, size)
+	}
+}
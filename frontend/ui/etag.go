@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"time"
+)
+
+// embeddedModTime stands in for the modification time of the embedded
+// files, which go:embed does not keep. The files cannot change while the
+// binary runs, so the time it started is a valid Last-Modified.
+var embeddedModTime = time.Now().UTC().Truncate(time.Second)
+
+// ETag returns a strong ETag for data.
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// modTimeFS reports embeddedModTime as the modification time of its files.
+type modTimeFS struct {
+	http.FileSystem
+}
+
+func (m modTimeFS) Open(name string) (http.File, error) {
+	f, err := m.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return modTimeFile{f}, nil
+}
+
+type modTimeFile struct {
+	http.File
+}
+
+func (f modTimeFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return modTimeInfo{info}, nil
+}
+
+type modTimeInfo struct {
+	os.FileInfo
+}
+
+func (i modTimeInfo) ModTime() time.Time {
+	return embeddedModTime
+}
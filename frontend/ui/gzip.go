@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// acceptsGzip tells if an Accept-Encoding header value allows gzip. An
+// explicit gzip entry takes precedence over the * wildcard. It scans the
+// header in place, as it runs for every asset request.
+func acceptsGzip(header string) bool {
+	wildcard := false
+	for header != "" {
+		var coding string
+		coding, header, _ = strings.Cut(header, ",")
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.TrimSpace(name)
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		accepted := true
+		for params != "" {
+			var param string
+			param, params, _ = strings.Cut(params, ";")
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				weight, err := strconv.ParseFloat(q[2:], 64)
+				accepted = err == nil && weight > 0
+			}
+		}
+		if name == "gzip" {
+			return accepted
+		}
+		wildcard = accepted
+	}
+	return wildcard
+}
+
+// compress returns data compressed with gzip, if that makes it smaller.
+func compress(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	if buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// ServeContent serves a page rendered for r with http.ServeContent and the
+// ETag etag. Like the embedded files, it is compressed for clients that
+// accept gzip if that makes it smaller, and the compressed copy has an
+// ETag of its own.
+func ServeContent(w http.ResponseWriter, r *http.Request, content []byte, etag string) {
+	h := w.Header()
+	h["Vary"] = append(h["Vary"], varyAcceptEncoding...)
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		if gzipped, ok := compress(content); ok {
+			h["Content-Encoding"] = gzipEncoding
+			content, etag = gzipped, etag[:len(etag)-1]+`-gzip"`
+		}
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
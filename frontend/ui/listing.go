@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// FileInfo describes one file of the UI.
+type FileInfo struct {
+	// Name is the URL path of the file, relative to the base path.
+	Name string
+	Size int64
+	// SHA256 is the hex-encoded SHA-256 of the file.
+	SHA256 string
+	// ModTime is the time the binary started for embedded files, which
+	// go:embed keeps no modification time for.
+	ModTime time.Time
+}
+
+// Listing is the set of files of the UI.
+type Listing struct {
+	// Version identifies the set of files: it is the SHA-256 of their
+	// names and hashes, so two binaries serve the same UI if and only if
+	// their versions match.
+	Version string
+	Files   []FileInfo
+}
+
+var (
+	listingOnce sync.Once
+	listing     Listing
+	listingErr  error
+)
+
+// List lists the embedded files, or the files in localDir if useLocal is
+// true, sorted by name.
+func List(useLocal bool) (Listing, error) {
+	if useLocal {
+		return list(os.DirFS(localDir), time.Time{})
+	}
+	listingOnce.Do(func() {
+		assets, err := fs.Sub(embedded, "web_assets")
+		if err != nil {
+			listingErr = err
+			return
+		}
+		listing, listingErr = list(assets, embeddedModTime)
+	})
+	return listing, listingErr
+}
+
+// list walks fsys in lexical order. Files report modTime as their
+// modification time, or their own if it is zero.
+func list(fsys fs.FS, modTime time.Time) (Listing, error) {
+	var l Listing
+	version := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		file := FileInfo{
+			Name:    path.Clean("/" + name),
+			Size:    info.Size(),
+			SHA256:  hex.EncodeToString(sum[:]),
+			ModTime: modTime,
+		}
+		if modTime.IsZero() {
+			file.ModTime = info.ModTime().UTC()
+		}
+		version.Write([]byte(file.Name + "\x00" + file.SHA256 + "\n"))
+		l.Files = append(l.Files, file)
+		return nil
+	})
+	l.Version = hex.EncodeToString(version.Sum(nil))
+	return l, err
+}
+
+// ListHandler renders the listing of List(useLocal) as JSON.
+func ListHandler(useLocal bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l, err := List(useLocal)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(l)
+	})
+}
@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+)
+
+// reloadDelay coalesces the bursts of events editors cause when saving a
+// file into a single reload.
+const reloadDelay = 100 * time.Millisecond
+
+// Reloader watches the local assets served by FS(true) and tells connected
+// browsers to reload the page when any of them changes.
+type Reloader struct {
+	logger log.Factory
+
+	lock    sync.Mutex
+	clients map[chan struct{}]struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchLocal starts watching the local assets directory and its
+// subdirectories, including ones created later.
+func WatchLocal(logger log.Factory) (*Reloader, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watchTree(watcher, localDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	r := &Reloader{logger: logger, clients: make(map[chan struct{}]struct{}), done: make(chan struct{})}
+	go r.run(watcher)
+	return r, nil
+}
+
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+func (r *Reloader) run(watcher *fsnotify.Watcher) {
+	var pending <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						r.logger.Bg().Error("Cannot watch asset directory", zap.String("path", event.Name), zap.Error(err))
+					}
+				}
+			}
+			r.logger.Bg().Debug("Asset changed", zap.String("path", event.Name), zap.Stringer("op", event.Op))
+			pending = time.After(reloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Bg().Error("Cannot watch assets", zap.Error(err))
+		case <-pending:
+			pending = nil
+			r.broadcast()
+		}
+	}
+}
+
+func (r *Reloader) broadcast() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.logger.Bg().Info("Assets changed, reloading browsers", zap.Int("browsers", len(r.clients)))
+	for client := range r.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+			// A reload is already pending for this browser.
+		}
+	}
+}
+
+// Close ends the open reload streams, so they don't hold up a graceful
+// shutdown. Browsers reconnect by themselves once the server is back.
+func (r *Reloader) Close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
+
+// ServeHTTP streams a reload event to the browser, as server-sent events,
+// whenever the assets change.
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rc := http.NewResponseController(w)
+
+	client := make(chan struct{}, 1)
+	r.lock.Lock()
+	r.clients[client] = struct{}{}
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		delete(r.clients, client)
+		r.lock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": watching assets\n\n")
+	if err := rc.Flush(); err != nil {
+		r.logger.For(req.Context()).Error("Cannot stream reload events", zap.Error(err))
+		return
+	}
+
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			_ = rc.Flush()
+		case <-req.Context().Done():
+			return
+		case <-r.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"bytes"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+)
+
+var (
+	staticOnce  sync.Once
+	staticFiles map[string]*staticFile
+)
+
+// staticFile is a file prepared to be served without touching the file
+// system: its content, its gzip-compressed copy if that is smaller, and
+// the header values of both, computed once.
+type staticFile struct {
+	plain   representation
+	gzipped *representation
+	ctype   []string
+}
+
+// representation is one encoding of a staticFile. The compressed copy
+// has an ETag of its own.
+type representation struct {
+	data   []byte
+	etag   []string
+	length []string
+}
+
+func newRepresentation(data []byte, etag string) representation {
+	return representation{data: data, etag: []string{etag}, length: []string{strconv.Itoa(len(data))}}
+}
+
+// readers recycles the readers conditional and range requests are served
+// through.
+var readers = sync.Pool{New: func() interface{} { return new(bytes.Reader) }}
+
+// Header values shared by all responses. They are assigned to response
+// headers as they are and must not be modified.
+var (
+	varyAcceptEncoding = []string{"Accept-Encoding"}
+	gzipEncoding       = []string{"gzip"}
+	acceptRanges       = []string{"bytes"}
+	lastModified       = []string{embeddedModTime.Format(http.TimeFormat)}
+)
+
+// conditionalHeaders are the request headers http.ServeContent evaluates.
+// Requests without any of them get the whole file.
+var conditionalHeaders = []string{"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"}
+
+// WithStatic serves the embedded files from memory, with their strong
+// ETag, and compressed to clients that accept gzip if that makes them
+// smaller. Other paths, and all requests if useLocal is true, are passed on
+// to next.
+func WithStatic(useLocal bool, next http.Handler) http.Handler {
+	if useLocal {
+		return next
+	}
+	staticOnce.Do(func() {
+		assets, err := fs.Sub(embedded, "web_assets")
+		if err != nil {
+			panic(err)
+		}
+		staticFiles = loadStatic(assets)
+	})
+	return serveStatic(staticFiles, next)
+}
+
+// NewStatic is WithStatic for the files in fsys.
+func NewStatic(fsys fs.FS, next http.Handler) http.Handler {
+	return serveStatic(loadStatic(fsys), next)
+}
+
+func serveStatic(files map[string]*staticFile, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if len(name) == 0 || name[0] != '/' {
+			name = "/" + name
+		}
+		f, ok := files[name]
+		if !ok {
+			if f, ok = files[path.Clean(name)]; !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		h := w.Header()
+		h["Content-Type"] = f.ctype
+		rep := &f.plain
+		if f.gzipped != nil {
+			if vary := h["Vary"]; vary != nil {
+				h["Vary"] = append(vary, varyAcceptEncoding...)
+			} else {
+				h["Vary"] = varyAcceptEncoding
+			}
+			if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				h["Content-Encoding"] = gzipEncoding
+				rep = f.gzipped
+			}
+		}
+		h["Etag"] = rep.etag
+
+		if isConditional(r) || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			reader := readers.Get().(*bytes.Reader)
+			reader.Reset(rep.data)
+			http.ServeContent(w, r, name, embeddedModTime, reader)
+			reader.Reset(nil)
+			readers.Put(reader)
+			return
+		}
+		// The common case writes the file as it is held, without copying
+		// it through a reader.
+		h["Last-Modified"] = lastModified
+		h["Accept-Ranges"] = acceptRanges
+		h["Content-Length"] = rep.length
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(rep.data)
+		}
+	})
+}
+
+func isConditional(r *http.Request) bool {
+	for _, name := range conditionalHeaders {
+		if _, ok := r.Header[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadStatic prepares every file in fsys but index.html, which
+// http.FileServer redirects to its directory.
+func loadStatic(fsys fs.FS) map[string]*staticFile {
+	files := make(map[string]*staticFile)
+	_ = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == "index.html" {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = http.DetectContentType(data)
+		}
+		etag := ETag(data)
+		f := &staticFile{plain: newRepresentation(data, etag), ctype: []string{ctype}}
+		if gzipped, ok := compress(data); ok {
+			rep := newRepresentation(gzipped, etag[:len(etag)-1]+`-gzip"`)
+			f.gzipped = &rep
+		}
+		files["/"+name] = f
+		return nil
+	})
+	return files
+}
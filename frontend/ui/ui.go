@@ -0,0 +1,83 @@
+// Package ui holds the web UI of the frontend, embedded from web_assets.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"path"
+)
+
+// If web_assets/src/app.js exists, it is first bundled with esbuild into
+// web_assets/dist, and the esbuild metafile is embedded too so the bundle
+// shows up in the asset manifest.
+//go:generate sh -c "test ! -f web_assets/src/app.js || (cd web_assets && npx esbuild src/app.js --bundle --minify --entry-names=[name]-[hash] --outdir=dist --metafile=dist/meta.json)"
+
+// BundleMetafile is the esbuild metafile within the embedded assets.
+const BundleMetafile = "/dist/meta.json"
+
+// localDir is where the assets are read from when useLocal is true,
+// relative to the frontend directory.
+const localDir = "ui/web_assets"
+
+//go:embed web_assets
+var embedded embed.FS
+
+// FS returns a http.Filesystem for the embedded assets. If useLocal is true,
+// the files in localDir are served instead, so changes show up without a rebuild.
+// Embedded files report the time the binary started as their modification time.
+func FS(useLocal bool) http.FileSystem {
+	if useLocal {
+		return http.Dir(localDir)
+	}
+	assets, err := fs.Sub(embedded, "web_assets")
+	if err != nil {
+		panic(err)
+	}
+	return modTimeFS{http.FS(assets)}
+}
+
+// Dir returns a http.Filesystem for the assets under the given prefix dir.
+func Dir(useLocal bool, name string) http.FileSystem {
+	return prefixFS{fs: FS(useLocal), prefix: name}
+}
+
+// FSByte returns the named file from the assets.
+func FSByte(useLocal bool, name string) ([]byte, error) {
+	f, err := FS(useLocal).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// FSMustByte is the same as FSByte, but panics if name is not present.
+func FSMustByte(useLocal bool, name string) []byte {
+	b, err := FSByte(useLocal, name)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// FSString is the string version of FSByte.
+func FSString(useLocal bool, name string) (string, error) {
+	b, err := FSByte(useLocal, name)
+	return string(b), err
+}
+
+// FSMustString is the string version of FSMustByte.
+func FSMustString(useLocal bool, name string) string {
+	return string(FSMustByte(useLocal, name))
+}
+
+type prefixFS struct {
+	fs     http.FileSystem
+	prefix string
+}
+
+func (p prefixFS) Open(name string) (http.File, error) {
+	return p.fs.Open(path.Join(p.prefix, name))
+}
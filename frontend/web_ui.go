@@ -0,0 +1,196 @@
+//go:build !lite
+// +build !lite
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/frontend/depgraph"
+	"github.com/superliuwr/jaeger-demo/frontend/dispatchlog"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/manifest"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+	"github.com/superliuwr/jaeger-demo/frontend/ui"
+)
+
+// webUI serves the embedded web UI. Building with the lite tag leaves it out.
+type webUI struct {
+	assetFS  http.FileSystem
+	local    bool
+	preload  []string
+	manifest *manifest.Manifest
+	index    *indexPage
+	reloader *ui.Reloader
+	// spaFallback serves the index page for unknown paths.
+	spaFallback bool
+}
+
+func newWebUI(tracer opentracing.Tracer, logger log.Factory, depGraph *depgraph.Graph, history *dispatchlog.Log, options ConfigOptions) *webUI {
+	local := options.LocalAssets
+	assetFS := ui.FS(local)
+
+	assets, err := manifest.Load(assetFS, ui.BundleMetafile)
+	if os.IsNotExist(err) {
+		assets, err = manifest.Empty(), nil
+	}
+	if err != nil {
+		logger.Bg().Fatal("Cannot load UI bundle manifest", zap.Error(err))
+	}
+
+	var reloader *ui.Reloader
+	if local {
+		if reloader, err = ui.WatchLocal(logger); err != nil {
+			logger.Bg().Fatal("Cannot watch local UI assets", zap.Error(err))
+		}
+	}
+
+	return &webUI{
+		assetFS:     assetFS,
+		local:       local,
+		spaFallback: options.SPAFallback,
+		preload:     preloadLinks(ui.FSMustString(local, "/index.html")),
+		manifest:    assets,
+		index: &indexPage{
+			tracer:   tracer,
+			logger:   logger,
+			depGraph: depGraph,
+			history:  history,
+			local:    local,
+			basePath: strings.TrimSuffix(path.Join("/", options.BasePath), "/"),
+		},
+		reloader: reloader,
+	}
+}
+
+// register adds the UI routes under the base path p.
+func (u *webUI) register(mux *tracing.TracedServeMux, p string) {
+	var assets http.Handler = u.manifest.Immutable(ui.WithStatic(u.local, http.FileServer(u.assetFS)))
+	if u.spaFallback {
+		assets = withSPAFallback(u.assetFS, u.index, assets)
+	}
+	mux.Handle(p, http.StripPrefix(p, withPreloadLinks(u.preload, u.index.Handler(assets))))
+	mux.Handle(path.Join(p, "/debug/assets"), u.manifest)
+	mux.Handle(path.Join(p, "/api/v1/assets"), ui.ListHandler(u.local))
+	if u.reloader != nil {
+		mux.HandleStream(path.Join(p, "/debug/reload"), 0, u.reloader)
+	}
+}
+
+// close ends the streams the UI keeps open, when the server shuts down.
+func (u *webUI) close() {
+	if u.reloader != nil {
+		u.reloader.Close()
+	}
+}
+
+// apiPrefixes are the paths that never fall back to the index page, so
+// mistyped API calls still get a 404.
+var apiPrefixes = []string{"/api/", "/debug/", "/admin/"}
+
+// withSPAFallback serves the index page for paths that are neither assets
+// nor API endpoints, so the UI can use client-side routes.
+func withSPAFallback(assets http.FileSystem, index http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if hasAnyPrefix(name+"/", apiPrefixes) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		f, err := assets.Open(name)
+		if os.IsNotExist(err) {
+			index.ServeHTTP(w, r)
+			return
+		}
+		if err == nil {
+			f.Close()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func uiBenchmarks() []benchmark {
+	return []benchmark{
+		{name: "assets-fs", run: func(b *testing.B) error {
+			return benchAssets(b, http.FileServer(http.FS(benchAssetFS)), "")
+		}},
+		{name: "assets", run: func(b *testing.B) error {
+			return benchAssets(b, ui.NewStatic(benchAssetFS, http.NotFoundHandler()), "")
+		}},
+		{name: "assets-gzip", run: func(b *testing.B) error {
+			return benchAssets(b, ui.NewStatic(benchAssetFS, http.NotFoundHandler()), "gzip")
+		}},
+	}
+}
+
+// benchAssetFS holds a script the size of a typical bundle, as the embedded
+// assets may have none.
+var benchAssetFS = fstest.MapFS{
+	"dist/app.js": {Data: bytes.Repeat([]byte("export function dispatch(customer) { return fetch('/dispatch?customer=' + customer); }\n"), 1000)},
+}
+
+// benchAssets requests the script from handler on all CPUs at once, like
+// browsers loading the UI in parallel. assets-fs serves it through
+// http.FileServer, as files not held in memory are.
+func benchAssets(b *testing.B, handler http.Handler, acceptEncoding string) error {
+	b.ReportAllocs()
+	var failed atomic.Int32
+	b.RunParallel(func(pb *testing.PB) {
+		r := httptest.NewRequest(http.MethodGet, "/dist/app.js", nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := &discardResponseWriter{header: make(http.Header)}
+		for pb.Next() {
+			clear(w.header)
+			w.status = 0
+			handler.ServeHTTP(w, r)
+			if w.status != http.StatusOK {
+				failed.Store(int32(w.status))
+			}
+		}
+	})
+	if status := failed.Load(); status != 0 {
+		return fmt.Errorf("unexpected status %d", status)
+	}
+	return nil
+}
+
+// discardResponseWriter drops what is written to it, so benchmarks measure
+// the handler alone.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header { return w.header }
+
+func (w *discardResponseWriter) WriteHeader(status int) { w.status = status }
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(p), nil
+}
+
+// ReadFrom copies through pooled buffers, as the server's response writer does.
+func (w *discardResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return io.Copy(io.Discard, r)
+}
@@ -0,0 +1,28 @@
+//go:build lite
+// +build lite
+
+package main
+
+import (
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/superliuwr/jaeger-demo/frontend/depgraph"
+	"github.com/superliuwr/jaeger-demo/frontend/dispatchlog"
+	"github.com/superliuwr/jaeger-demo/frontend/log"
+	"github.com/superliuwr/jaeger-demo/frontend/tracing"
+)
+
+// webUI is empty in lite builds, which serve the API only.
+type webUI struct{}
+
+func newWebUI(tracer opentracing.Tracer, logger log.Factory, depGraph *depgraph.Graph, history *dispatchlog.Log, options ConfigOptions) *webUI {
+	return &webUI{}
+}
+
+func (u *webUI) register(mux *tracing.TracedServeMux, p string) {}
+
+func (u *webUI) close() {}
+
+func uiBenchmarks() []benchmark {
+	return nil
+}
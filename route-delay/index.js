@@ -21,6 +21,7 @@ async function getDelay (req, res) {
   })
 
   const delay = Math.floor(Math.random() * 500) + 200
+  debug(span, 'generated delay', { delay, customer: customerInBaggage })
 
   span.setTag('delay', delay)
   span.finish()
@@ -107,6 +108,14 @@ function tracingMiddleWare(req, res, next) {
   next()
 }
 
+// ------ Utils -----
+// Only log when the request asked for debug logs via the log-level baggage item
+function debug(span, msg, fields) {
+  if (span.getBaggageItem('log-level') === 'debug') {
+    console.log('DEBUG', msg, JSON.stringify(fields))
+  }
+}
+
 // ----- App -----
 const app = express()
 app.use(tracingMiddleWare)
@@ -1,14 +1,69 @@
 const express = require('express')
 const bent = require('bent')
+const grpc = require('@grpc/grpc-js')
+const protoLoader = require('@grpc/proto-loader')
+const dns = require('dns').promises
+const crypto = require('crypto')
+const fs = require('fs')
+const https = require('https')
+const net = require('net')
+const { Worker } = require('worker_threads')
 const { initTracerFromEnv } = require("jaeger-client")
 const opentracing = require('opentracing')
 
 const port = process.env.PORT || 8083
+const grpcPort = process.env.GRPC_PORT || 8086
 const serviceName = process.env.SERVICE_NAME || 'route'
+const maxLocationLength = parseInt(process.env.MAX_LOCATION_LENGTH || '64', 10)
+const matrixWorkers = parseInt(process.env.MATRIX_WORKERS || '8', 10)
+const matrixTimeout = parseInt(process.env.MATRIX_TIMEOUT_MS || '5000', 10)
+const maxMatrixCells = parseInt(process.env.MAX_MATRIX_CELLS || '400', 10)
+const routeWorkers = parseInt(process.env.ROUTE_WORKERS || '2', 10)
+const routeCPUMillis = parseInt(process.env.ROUTE_CPU_MS || '20', 10)
+const cacheShards = parseInt(process.env.ROUTE_CACHE_SHARDS || '0', 10)
+const cacheShardSize = parseInt(process.env.ROUTE_CACHE_SHARD_SIZE || '1000', 10)
+const cacheTTL = parseInt(process.env.ROUTE_CACHE_TTL_MS || '60000', 10)
+// With a certificate and key the HTTP API is served over HTTPS, and with a
+// CA bundle as well only to clients with a certificate it signed.
+const tlsCert = process.env.ROUTE_TLS_CERT
+const tlsKey = process.env.ROUTE_TLS_KEY
+const tlsClientCA = process.env.ROUTE_TLS_CLIENT_CA
+// The secret the frontend signs the tokens of its users with. Without it
+// tokens are not checked.
+const jwtSecret = process.env.JWT_SECRET
+const errorRate = parseFloat(flag('error-rate') || process.env.ERROR_RATE || '0')
+// The baggage items accepted from callers, as in the Go services.
+const allowedBaggageKeys = new Set((process.env.BAGGAGE_ALLOWED_KEYS || 'customer,session,request,log-level,synthetic').split(',').map(k => k.trim()))
+const maxBaggageValueLength = parseInt(process.env.BAGGAGE_MAX_VALUE_LENGTH || '256', 10)
+const readinessTimeout = 2000
+const locationsFile = process.env.LOCATIONS_CSV || __dirname + '/data/locations.csv'
 
 const tracer = initTracer(serviceName)
 opentracing.initGlobalTracer(tracer)
 
+if (!(errorRate >= 0 && errorRate <= 1)) {
+  throw new Error(`--error-rate must be between 0 and 1, got ${errorRate}`)
+}
+if (!(Number.isInteger(maxBaggageValueLength) && maxBaggageValueLength >= 0)) {
+  throw new Error(`BAGGAGE_MAX_VALUE_LENGTH must be a non-negative integer, got ${process.env.BAGGAGE_MAX_VALUE_LENGTH}`)
+}
+if (!(Number.isInteger(routeWorkers) && routeWorkers >= 1)) {
+  throw new Error(`ROUTE_WORKERS must be a positive integer, got ${process.env.ROUTE_WORKERS}`)
+}
+if (!(Number.isInteger(cacheShards) && cacheShards >= 0)) {
+  throw new Error(`ROUTE_CACHE_SHARDS must be a non-negative integer, got ${process.env.ROUTE_CACHE_SHARDS}`)
+}
+if (!(Number.isInteger(cacheShardSize) && cacheShardSize >= 1)) {
+  throw new Error(`ROUTE_CACHE_SHARD_SIZE must be a positive integer, got ${process.env.ROUTE_CACHE_SHARD_SIZE}`)
+}
+if (!(Number.isInteger(cacheTTL) && cacheTTL >= 1)) {
+  throw new Error(`ROUTE_CACHE_TTL_MS must be a positive integer, got ${process.env.ROUTE_CACHE_TTL_MS}`)
+}
+
+let locations = loadLocations(locationsFile)
+const pool = createPool(routeWorkers)
+const cache = createCache(cacheShards, cacheShardSize, cacheTTL)
+
 // ----- Express handlers -----
 async function getRoute (req, res) {
   const tracer = opentracing.globalTracer()
@@ -17,6 +72,22 @@ async function getRoute (req, res) {
   const pickup = req.query.pickup
   const dropoff = req.query.dropoff
 
+  for (const [name, value] of Object.entries({ pickup, dropoff })) {
+    if (typeof value === 'string' && value.length > maxLocationLength) {
+      span.setTag('limit.rejected', 'param_' + name)
+      span.log({ event: 'request rejected', reason: `parameter '${name}' over ${maxLocationLength} bytes` })
+      span.finish()
+      res.status(414).send(`URI Too Long: parameter '${name}' over ${maxLocationLength} bytes`)
+      return
+    }
+  }
+
+  if (injectError(span)) {
+    span.finish()
+    res.status(500).send('chaos: injected error')
+    return
+  }
+
   const customerInBaggage = span.getBaggageItem('customer')
 
   span.log({
@@ -25,22 +96,517 @@ async function getRoute (req, res) {
       'dropoff': dropoff,
       'customer': customerInBaggage
   })
+  debug(span, 'finding route', { pickup, dropoff, customer: customerInBaggage })
+
+  let response
+  try {
+    response = await findRoute(span, pickup, dropoff)
+  } catch (e) {
+    span.setTag(opentracing.Tags.ERROR, true)
+    span.log({ event: 'error', message: e.message })
+    span.finish()
+    res.status(500).send(e.message)
+    return
+  }
+
+  span.finish()
+
+  res.json(response)
+}
+
+// getRouteMatrix computes the ETA from every pickup to every dropoff with a
+// bounded pool of workers. Each cell is a child span. Cells not done within
+// the matrix timeout are left null and the response is marked incomplete.
+async function getRouteMatrix (req, res) {
+  const tracer = opentracing.globalTracer()
+  const span = tracer.startSpan('getRouteMatrix', { childOf: req.span })
+
+  const { pickups, dropoffs } = req.body || {}
+  const invalid = matrixParamError({ pickups, dropoffs })
+  if (invalid) {
+    span.setTag(opentracing.Tags.ERROR, true)
+    span.log({ event: 'request rejected', reason: invalid.message })
+    span.finish()
+    res.status(invalid.status).send(invalid.message)
+    return
+  }
+
+  span.setTag('matrix.pickups', pickups.length)
+  span.setTag('matrix.dropoffs', dropoffs.length)
+  span.setTag('matrix.workers', matrixWorkers)
+  debug(span, 'computing route matrix', { pickups, dropoffs })
+
+  const eta = pickups.map(() => dropoffs.map(() => null))
+  const cells = []
+  pickups.forEach((pickup, row) => dropoffs.forEach((dropoff, col) => cells.push({ row, col, pickup, dropoff })))
+
+  let next = 0
+  let timedOut = false
+  let timer
+  const timeout = new Promise(resolve => {
+    timer = setTimeout(() => {
+      timedOut = true
+      resolve()
+    }, matrixTimeout)
+  })
+
+  const worker = async () => {
+    while (!timedOut && next < cells.length) {
+      const cell = cells[next++]
+      const cellSpan = tracer.startSpan('matrix cell', { childOf: span })
+      cellSpan.setTag('matrix.row', cell.row)
+      cellSpan.setTag('matrix.col', cell.col)
+      try {
+        const route = await findRoute(cellSpan, cell.pickup, cell.dropoff)
+        if (timedOut) {
+          cellSpan.log({ event: 'result discarded', reason: 'matrix timed out' })
+        } else {
+          eta[cell.row][cell.col] = route.ETA
+        }
+      } catch (e) {
+        cellSpan.setTag(opentracing.Tags.ERROR, true)
+        cellSpan.log({ event: 'error', message: e.message })
+      }
+      cellSpan.finish()
+    }
+  }
+  const workers = Array.from({ length: Math.min(matrixWorkers, cells.length) }, worker)
+  await Promise.race([Promise.all(workers), timeout])
+  clearTimeout(timer)
+
+  const computed = eta.reduce((n, row) => n + row.filter(v => v !== null).length, 0)
+  span.setTag('matrix.cells', cells.length)
+  span.setTag('matrix.computed', computed)
+  if (timedOut) {
+    span.log({ event: 'matrix timed out', timeout: matrixTimeout, computed, cells: cells.length })
+  }
+  span.finish()
+
+  res.json({ Pickups: pickups, Dropoffs: dropoffs, ETA: eta, Complete: computed === cells.length })
+}
+
+// matrixParamError returns the status and message to reject invalid matrix
+// parameters with, or null if they are valid.
+function matrixParamError (params) {
+  for (const [name, values] of Object.entries(params)) {
+    if (!Array.isArray(values) || values.length === 0 || !values.every(v => typeof v === 'string')) {
+      return { status: 400, message: `Bad Request: '${name}' must be a non-empty array of strings` }
+    }
+    if (values.some(v => v.length > maxLocationLength)) {
+      return { status: 400, message: `Bad Request: location in '${name}' over ${maxLocationLength} bytes` }
+    }
+  }
+  const cells = params.pickups.length * params.dropoffs.length
+  if (cells > maxMatrixCells) {
+    return { status: 413, message: `Payload Too Large: ${cells} cells, at most ${maxMatrixCells} allowed` }
+  }
+  return null
+}
+
+// ----- gRPC handlers -----
+async function getRouteGRPC (call, callback) {
+  const tracer = opentracing.globalTracer()
+  // otgrpc clients send the span context as gRPC metadata
+  const wireCtx = tracer.extract(opentracing.FORMAT_TEXT_MAP, restrictBaggage(call.metadata.getMap(), false))
+  const span = tracer.startSpan('/route.RouteService/FindRoute', { childOf: wireCtx })
+  span.setTag(opentracing.Tags.SPAN_KIND, opentracing.Tags.SPAN_KIND_RPC_SERVER)
+  span.setTag(opentracing.Tags.COMPONENT, 'gRPC')
+  tagBaggage(span)
+
+  const denied = authenticate(span, call.metadata.get('authorization')[0])
+  if (denied) {
+    span.finish()
+    callback({ code: grpc.status.UNAUTHENTICATED, details: denied })
+    return
+  }
+
+  if (injectError(span)) {
+    span.finish()
+    callback({ code: grpc.status.INTERNAL, details: 'chaos: injected error' })
+    return
+  }
+
+  const { pickup, dropoff } = call.request
+  debug(span, 'finding route', { pickup, dropoff, customer: span.getBaggageItem('customer') })
+
+  try {
+    const response = await findRoute(span, pickup, dropoff)
+    callback(null, { pickup: response.Pickup, dropoff: response.Dropoff, eta: response.ETA })
+  } catch (e) {
+    span.setTag(opentracing.Tags.ERROR, true)
+    span.log({ event: 'error', message: e.message })
+    callback(e)
+  }
+  span.finish()
+}
+
+// ----- Health checks -----
+function getHealth (req, res) {
+  res.type('text').send('ok\n')
+}
+
+// getReadiness checks that the delay service accepts connections and that
+// the Jaeger agent host resolves; the agent is reached over UDP, so it
+// cannot be probed with a connection.
+async function getReadiness (req, res) {
+  const delayHost = process.env.DELAY_SERVICE_HOST || 'route-delay'
+  const delayPort = process.env.DELAY_SERVICE_PORT || '8084'
+  const agentHost = process.env.JAEGER_AGENT_HOST || 'localhost'
+
+  const checks = await Promise.all([
+    check('route-delay', `${delayHost}:${delayPort}`, () => connect(delayHost, delayPort)),
+    check('jaeger-agent', agentHost, () => dns.lookup(agentHost)),
+  ])
+  const ready = checks.every(c => c.OK)
+  res.status(ready ? 200 : 503).json({ Ready: ready, Checks: checks })
+}
+
+async function check (name, target, probe) {
+  try {
+    await withTimeout(probe(), readinessTimeout)
+    return { Name: name, Target: target, OK: true }
+  } catch (e) {
+    return { Name: name, Target: target, OK: false, Error: e.message }
+  }
+}
+
+function connect (host, port) {
+  return new Promise((resolve, reject) => {
+    const socket = net.connect(port, host, () => {
+      socket.end()
+      resolve()
+    })
+    socket.setTimeout(readinessTimeout, () => socket.destroy(new Error('connect timed out')))
+    socket.on('error', reject)
+  })
+}
+
+function withTimeout (promise, ms) {
+  let timer
+  const timeout = new Promise((resolve, reject) => {
+    timer = setTimeout(() => reject(new Error(`timed out after ${ms}ms`)), ms)
+  })
+  return Promise.race([promise, timeout]).finally(() => clearTimeout(timer))
+}
+
+// ----- Locations -----
+// The locations data set divides the map into areas, each with the average
+// speed of traffic in it, in map units per minute. It is read from a CSV
+// file with a "name,x,y,speed" header; a point belongs to the closest area.
+function loadLocations (file) {
+  const lines = fs.readFileSync(file, 'utf8').split(/\r?\n/).filter(line => line.trim() !== '')
+  if (lines[0] !== 'name,x,y,speed') {
+    throw new Error(`${file}: header must be "name,x,y,speed"`)
+  }
+  const errors = []
+  const areas = []
+  const names = new Set()
+  lines.slice(1).forEach((line, i) => {
+    const [name, ...numbers] = line.split(',')
+    const [x, y, speed] = numbers.map(Number)
+    const where = `${file}:${i + 2}`
+    if (numbers.length !== 3 || !name || numbers.some(n => n.trim() === '' || !Number.isFinite(Number(n)))) {
+      errors.push(`${where}: want a name and 3 numbers`)
+    } else if (speed <= 0) {
+      errors.push(`${where}: speed must be positive`)
+    } else if (names.has(name)) {
+      errors.push(`${where}: duplicate area ${name}`)
+    } else {
+      names.add(name)
+      areas.push({ name, x, y, speed })
+    }
+  })
+  if (areas.length === 0 && errors.length === 0) {
+    errors.push(`${file}: no areas`)
+  }
+  if (errors.length > 0) {
+    throw new Error(errors.join('; '))
+  }
+  return { file, areas, loaded: new Date() }
+}
+
+// geocode parses an "x,y" location and finds the area it is in. Locations
+// that are not coordinates have no area.
+function geocode (location) {
+  const [x, y] = String(location).split(',').map(Number)
+  if (!Number.isFinite(x) || !Number.isFinite(y)) {
+    return null
+  }
+  const distance = area => Math.hypot(area.x - x, area.y - y)
+  const area = locations.areas.reduce((closest, area) => distance(area) < distance(closest) ? area : closest)
+  return { x, y, area }
+}
+
+// estimateMinutes is the time to drive straight from pickup to dropoff at
+// the average speed of their areas, or a random 1 to 10 minutes if either
+// is not on the map.
+function estimateMinutes (span, pickup, dropoff) {
+  const from = geocode(pickup)
+  const to = geocode(dropoff)
+  if (!from || !to) {
+    return Math.floor(Math.random() * 10) + 1
+  }
+  span.setTag('pickup.area', from.area.name)
+  span.setTag('dropoff.area', to.area.name)
+  const speed = (from.area.speed + to.area.speed) / 2
+  return Math.max(1, Math.round(Math.hypot(to.x - from.x, to.y - from.y) / speed))
+}
+
+function getLocations (req, res) {
+  res.json({ File: locations.file, Loaded: locations.loaded, Areas: locations.areas })
+}
+
+// reloadLocations reads the locations file again. An invalid file is
+// rejected and the current data set is kept.
+function reloadLocations (req, res) {
+  try {
+    locations = loadLocations(locations.file)
+  } catch (e) {
+    req.span.setTag(opentracing.Tags.ERROR, true)
+    req.span.log({ event: 'reload rejected', message: e.message })
+    res.status(400).send(e.message)
+    return
+  }
+  // Cached ETAs were computed on the old map.
+  cache.clear()
+  req.span.log({ event: 'locations reloaded', areas: locations.areas.length })
+  console.log('INFO ', `reloaded ${locations.areas.length} areas from ${locations.file}`)
+  getLocations(req, res)
+}
 
+// ----- Route computation -----
+async function findRoute(span, pickup, dropoff) {
+  const cached = cache.get(span, pickup, dropoff)
+  if (cached) {
+    return cached
+  }
   const delay = await fetchDelay(span)
+  debug(span, 'sleeping for delay', { delay })
   await sleep(delay)
 
   const response = {
     'Pickup': pickup,
     'Dropoff': dropoff,
-    'ETA': (Math.floor(Math.random() * 10) + 1) * (1000000 * 1000 * 60),
+    'ETA': await computeETA(span, pickup, dropoff) * (1000000 * 1000 * 60),
   }
 
   span.setTag('delay', delay)
   span.setTag('response', response)
+  cache.set(pickup, dropoff, response)
+
+  return response
+}
+
+// computeETA estimates the ETA on the worker pool. The time spent waiting
+// for a free worker is a queue span of its own, so under load the queueing
+// delay shows up in traces next to the computation.
+async function computeETA (parentSpan, pickup, dropoff) {
+  const tracer = opentracing.globalTracer()
+  const queueSpan = tracer.startSpan('queue', { childOf: parentSpan })
+  queueSpan.setTag('pool.workers', pool.size)
+  queueSpan.setTag('pool.queued', pool.queued())
 
+  let span
+  try {
+    await pool.submit(routeCPUMillis, () => {
+      queueSpan.finish()
+      span = tracer.startSpan('computeETA', { childOf: parentSpan })
+      span.setTag('cpu_ms', routeCPUMillis)
+    })
+  } catch (e) {
+    span.setTag(opentracing.Tags.ERROR, true)
+    span.log({ event: 'error', message: e.message })
+    span.finish()
+    throw e
+  }
+  const minutes = estimateMinutes(span, pickup, dropoff)
   span.finish()
+  return minutes
+}
 
-  res.json(response)
+// ----- Worker pool -----
+// burnCPU is the code of the worker threads: it keeps a CPU busy for as
+// long as it is asked to, standing in for a path search on a road graph.
+const burnCPU = `
+const { parentPort } = require('worker_threads')
+parentPort.on('message', ({ millis }) => {
+  const end = Date.now() + millis
+  let x = 0
+  while (Date.now() < end) {
+    x = Math.sqrt(x + Math.random())
+  }
+  parentPort.postMessage({ done: true })
+})
+`
+
+// createPool starts size worker threads. Jobs run on the first idle worker,
+// or wait in a queue, oldest first, until one is idle. Running the work off
+// the event loop keeps the service responsive while all workers are busy.
+// A worker that fails rejects its job and is replaced, so the pool keeps
+// its size.
+function createPool (size) {
+  const idle = []
+  const queue = []
+  const run = (worker, job) => {
+    worker.job = job
+    job.started()
+    worker.postMessage({ millis: job.millis })
+  }
+  // next gives worker the oldest queued job, or makes it idle.
+  const next = worker => {
+    worker.job = null
+    const job = queue.shift()
+    if (job) {
+      run(worker, job)
+    } else {
+      idle.push(worker)
+    }
+  }
+  const spawn = () => {
+    const worker = new Worker(burnCPU, { eval: true })
+    worker.on('message', () => {
+      const job = worker.job
+      next(worker)
+      job.resolve()
+    })
+    worker.on('error', e => {
+      console.log('ERROR', `route worker failed, replacing it: ${e.message}`)
+      const i = idle.indexOf(worker)
+      if (i >= 0) {
+        idle.splice(i, 1)
+      }
+      if (worker.job) {
+        worker.job.reject(new Error(`route worker failed: ${e.message}`))
+      }
+      next(spawn())
+    })
+    return worker
+  }
+  for (let i = 0; i < size; i++) {
+    idle.push(spawn())
+  }
+  return {
+    size,
+    queued: () => queue.length,
+    // submit runs millis of CPU work on the pool. started is called when a
+    // worker picks the job up.
+    submit: (millis, started) => new Promise((resolve, reject) => {
+      const job = { millis, started, resolve, reject }
+      const worker = idle.pop()
+      if (worker) {
+        run(worker, job)
+      } else {
+        queue.push(job)
+      }
+    }),
+  }
+}
+
+// ----- Route cache -----
+// createCache caches routes in shards of up to shardSize entries each, for
+// ttl milliseconds. A route belongs to the shard its pickup and dropoff
+// hash to on a consistent hashing ring, where every shard owns many small
+// arcs, so keys spread evenly and adding a shard only moves the keys of
+// the arcs it takes over. A frequent route always lands on the same shard,
+// which the hits per shard in stats() make visible as a hotspot. A full
+// shard evicts its least recently used route. With no shards, nothing is
+// cached.
+function createCache (shards, shardSize, ttl) {
+  const pointsPerShard = 100
+  const hash = s => crypto.createHash('md5').update(s).digest().readUInt32BE(0)
+  const ring = []
+  const stats = []
+  const entries = []
+  for (let i = 0; i < shards; i++) {
+    for (let p = 0; p < pointsPerShard; p++) {
+      ring.push({ point: hash(`shard-${i}-${p}`), shard: i })
+    }
+    stats.push({ Shard: i, Hits: 0, Misses: 0, Evictions: 0 })
+    entries.push(new Map())
+  }
+  ring.sort((a, b) => a.point - b.point)
+
+  // shardOf finds the first point of the ring at or after the hash of key,
+  // wrapping around past the last one.
+  const shardOf = key => {
+    const h = hash(key)
+    let lo = 0
+    let hi = ring.length
+    while (lo < hi) {
+      const mid = (lo + hi) >> 1
+      if (ring[mid].point < h) {
+        lo = mid + 1
+      } else {
+        hi = mid
+      }
+    }
+    return ring[lo % ring.length].shard
+  }
+  const keyOf = (pickup, dropoff) => `${pickup}|${dropoff}`
+
+  return {
+    // get returns the cached route, if any, and tags span with the shard
+    // and whether it was a hit.
+    get: (span, pickup, dropoff) => {
+      if (shards === 0) {
+        return null
+      }
+      const key = keyOf(pickup, dropoff)
+      const shard = shardOf(key)
+      const entry = entries[shard].get(key)
+      const hit = entry !== undefined && entry.expires > Date.now()
+      span.setTag('cache.shard', shard)
+      span.setTag('cache.hit', hit)
+      if (!hit) {
+        stats[shard].Misses++
+        return null
+      }
+      stats[shard].Hits++
+      // Move the entry to the end, as the most recently used.
+      entries[shard].delete(key)
+      entries[shard].set(key, entry)
+      span.log({ event: 'route cache hit', shard })
+      return entry.route
+    },
+    set: (pickup, dropoff, route) => {
+      if (shards === 0) {
+        return
+      }
+      const key = keyOf(pickup, dropoff)
+      const shard = shardOf(key)
+      const shardEntries = entries[shard]
+      shardEntries.delete(key)
+      if (shardEntries.size >= shardSize) {
+        shardEntries.delete(shardEntries.keys().next().value)
+        stats[shard].Evictions++
+      }
+      shardEntries.set(key, { route, expires: Date.now() + ttl })
+    },
+    clear: () => entries.forEach(e => e.clear()),
+    // stats returns the hits, misses, evictions and entries of every
+    // shard, with each shard's share of all hits.
+    stats: () => {
+      const hits = stats.reduce((n, s) => n + s.Hits, 0)
+      return stats.map((s, i) => Object.assign({}, s, {
+        Entries: entries[i].size,
+        HitShare: hits === 0 ? 0 : s.Hits / hits,
+      }))
+    },
+  }
+}
+
+// getCache shows the cache settings and the stats of every shard. Imbalance
+// is the hits of the busiest shard over the mean, 1 when hits are even.
+function getCache (req, res) {
+  const shards = cache.stats()
+  const hits = shards.map(s => s.Hits)
+  const mean = hits.reduce((a, b) => a + b, 0) / (hits.length || 1)
+  res.json({
+    Shards: shards,
+    ShardSize: cacheShardSize,
+    TTL: cacheTTL,
+    Imbalance: mean === 0 ? 0 : Math.max(...hits) / mean,
+  })
 }
 
 // ----- Calling another API -----
@@ -56,6 +622,7 @@ async function fetchDelay(parentSpan) {
 
   const headers = {}
   tracer.inject(span, opentracing.FORMAT_HTTP_HEADERS, headers)
+  debug(span, 'calling delay service', { url })
 
   const request = bent('string', headers)
 
@@ -110,16 +677,23 @@ function initTracer(serviceName) {
 function tracingMiddleWare(req, res, next) {
   const tracer = opentracing.globalTracer()
   // Extracting the tracing headers from the incoming http request
-  const wireCtx = tracer.extract(opentracing.FORMAT_HTTP_HEADERS, req.headers)
+  const wireCtx = tracer.extract(opentracing.FORMAT_HTTP_HEADERS, restrictBaggage(req.headers, true))
   // Creating our span with context from incoming request
   const span = tracer.startSpan(req.path, { childOf: wireCtx })
   // Use the log api to capture a log
   span.log({ event: 'request_received' })
+  tagBaggage(span)
 
   // Use the setTag api to capture standard span tags for http traces
   span.setTag(opentracing.Tags.HTTP_METHOD, req.method)
   span.setTag(opentracing.Tags.SPAN_KIND, opentracing.Tags.SPAN_KIND_RPC_SERVER)
   span.setTag(opentracing.Tags.HTTP_URL, req.path)
+  if (req.socket.encrypted) {
+    const peer = req.socket.getPeerCertificate()
+    if (peer && peer.subject) {
+      span.setTag('tls.client', peer.subject.CN)
+    }
+  }
 
   // include trace ID in headers so that we can debug slow requests we see in
   // the browser by looking up the trace ID found in response headers
@@ -153,15 +727,204 @@ function tracingMiddleWare(req, res, next) {
 }
 
 // ------ Utils -----
+// flag returns the value of a --name=value command line argument.
+function flag (name) {
+  const arg = process.argv.find(a => a.startsWith(`--${name}=`))
+  return arg && arg.slice(name.length + 3)
+}
+
+// injectError tells if the request should fail, at errorRate, and tags
+// span as an error if so.
+function injectError (span) {
+  if (Math.random() >= errorRate) {
+    return false
+  }
+  span.setTag(opentracing.Tags.ERROR, true)
+  span.log({ event: 'error', message: 'chaos: injected error' })
+  return true
+}
+
 function sleep(ms) {
   return new Promise(resolve => setTimeout(resolve, ms))
 }
 
+// Tags the span with the customer, session and user baggage items the frontend sets
+function tagBaggage(span) {
+  for (const key of ['customer', 'session', 'user']) {
+    const value = span.getBaggageItem(key)
+    if (value) {
+      span.setTag(key, value)
+    }
+  }
+}
+
+// restrictBaggage returns a copy of the carrier of an incoming request
+// without the baggage items that are not allowed, and with values over the
+// limit truncated. Baggage comes in uberctx-* entries, URL-encoded in HTTP
+// headers, and in the key1=value1, key2=value2 list of jaeger-baggage.
+function restrictBaggage(carrier, urlEncoded) {
+  const restricted = {}
+  for (const [name, value] of Object.entries(carrier)) {
+    const key = name.toLowerCase()
+    if (typeof value !== 'string') {
+      restricted[name] = value
+    } else if (key.startsWith('uberctx-')) {
+      let item = value
+      if (urlEncoded) {
+        try {
+          item = decodeURIComponent(value)
+        } catch (e) {
+          restricted[name] = value
+          continue
+        }
+      }
+      item = restrictBaggageItem(key.slice('uberctx-'.length), item)
+      if (item !== undefined) {
+        restricted[name] = urlEncoded ? encodeURIComponent(item) : item
+      }
+    } else if (key === 'jaeger-baggage') {
+      restricted[name] = value.split(',')
+        .map(item => item.trim().split(/=(.*)/s))
+        .filter(kv => kv.length > 1)
+        .map(([k, v]) => [k, restrictBaggageItem(k, v)])
+        .filter(([, v]) => v !== undefined)
+        .map(([k, v]) => `${k}=${v}`)
+        .join(', ')
+    } else {
+      restricted[name] = value
+    }
+  }
+  return restricted
+}
+
+// restrictBaggageItem returns the value to keep for a baggage item, or
+// undefined if it must be dropped.
+function restrictBaggageItem(key, value) {
+  if (!allowedBaggageKeys.has(key)) {
+    console.log('INFO ', 'Baggage item restricted', JSON.stringify({ reason: 'key_not_allowed', key }))
+    return undefined
+  }
+  if (value.length > maxBaggageValueLength) {
+    console.log('INFO ', 'Baggage item restricted', JSON.stringify({ reason: 'value_too_long', key }))
+    return value.slice(0, maxBaggageValueLength)
+  }
+  return value
+}
+
+// Validates the bearer token of a call, if it has one, and tags its span
+// with the user of the token, which it also sets as baggage. Calls without
+// a token are served anonymously. Returns why an invalid or expired token
+// is rejected, or nothing if the call may go on.
+function authenticate(span, authorization) {
+  if (!jwtSecret) {
+    return
+  }
+  const [scheme, token] = (authorization || '').split(' ')
+  if (!token || scheme.toLowerCase() !== 'bearer') {
+    if (span.getBaggageItem('user')) {
+      span.setBaggageItem('user', '')
+    }
+    return
+  }
+  let claims
+  try {
+    claims = verifyToken(token)
+  } catch (e) {
+    span.setTag(opentracing.Tags.ERROR, true)
+    span.log({ event: 'invalid token', message: e.message })
+    return 'invalid token: ' + e.message
+  }
+  span.setTag('user', claims.sub)
+  span.setBaggageItem('user', claims.sub)
+}
+
+// Checks the HS256 signature and the expiry of a token the frontend issued,
+// at the Unix time now, and returns its claims. The rules are those of
+// "Token format" in the README, which api/auth/jwt-vectors.json tests.
+function verifyToken(token, now = Date.now() / 1000) {
+  const parts = token.split('.')
+  if (parts.length !== 3 || parts[0] !== Buffer.from('{"alg":"HS256","typ":"JWT"}').toString('base64url')) {
+    throw new Error('malformed')
+  }
+  const [header, payload, signature] = parts
+  const expected = Buffer.from(crypto.createHmac('sha256', jwtSecret).update(header + '.' + payload).digest('base64url'))
+  const actual = Buffer.from(signature)
+  if (expected.length !== actual.length || !crypto.timingSafeEqual(expected, actual)) {
+    throw new Error('bad_signature')
+  }
+  // Unpadded base64url only, which Buffer does not insist on.
+  if (!/^[A-Za-z0-9_-]*$/.test(payload)) {
+    throw new Error('malformed')
+  }
+  let claims
+  try {
+    claims = JSON.parse(Buffer.from(payload, 'base64url'))
+  } catch (e) {
+    throw new Error('malformed')
+  }
+  if (claims === null || claims.iss !== 'hotrod' || typeof claims.sub !== 'string' || !claims.sub ||
+    !Number.isInteger(claims.exp) || claims.exp <= 0) {
+    throw new Error('malformed')
+  }
+  if (now >= claims.exp) {
+    throw new Error('expired')
+  }
+  return claims
+}
+
+function authMiddleWare(req, res, next) {
+  const denied = authenticate(req.span, req.get('Authorization'))
+  if (denied) {
+    res.set('WWW-Authenticate', 'Bearer error="invalid_token"')
+    res.status(401).send(denied)
+    return
+  }
+  next()
+}
+
+// Only log when the request asked for debug logs via the log-level baggage item
+function debug(span, msg, fields) {
+  if (span.getBaggageItem('log-level') === 'debug') {
+    console.log('DEBUG', msg, JSON.stringify(fields))
+  }
+}
+
 // ----- App -----
 const app = express()
 app.use(tracingMiddleWare)
+app.use(authMiddleWare)
 app.get('/route', getRoute)
+app.post('/route/matrix', express.json(), getRouteMatrix)
+app.get('/healthz', getHealth)
+app.get('/locations', getLocations)
+app.post('/locations/reload', reloadLocations)
+app.get('/cache', getCache)
+app.get('/readyz', getReadiness)
 app.disable('etag')
-app.listen(port, () => {
-  console.log('Route app listening on port ' + port)
+if (tlsCert && tlsKey) {
+  const options = { cert: fs.readFileSync(tlsCert), key: fs.readFileSync(tlsKey) }
+  if (tlsClientCA) {
+    Object.assign(options, { ca: fs.readFileSync(tlsClientCA), requestCert: true, rejectUnauthorized: true })
+  }
+  https.createServer(options, app).listen(port, () => {
+    console.log('Route app listening on port ' + port + ' over HTTPS' + (tlsClientCA ? ', client certificates required' : ''))
+  })
+} else {
+  app.listen(port, () => {
+    console.log('Route app listening on port ' + port)
+  })
+}
+
+const routeProto = grpc.loadPackageDefinition(
+  protoLoader.loadSync(__dirname + '/route.proto', { longs: Number })
+).route
+const grpcServer = new grpc.Server()
+grpcServer.addService(routeProto.RouteService.service, { findRoute: getRouteGRPC })
+grpcServer.bindAsync('0.0.0.0:' + grpcPort, grpc.ServerCredentials.createInsecure(), (err) => {
+  if (err) {
+    console.log('ERROR', err)
+    return
+  }
+  grpcServer.start()
+  console.log('Route gRPC listening on port ' + grpcPort)
 })
\ No newline at end of file
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/superliuwr/jaeger-demo/sidecar/log"
+	"github.com/superliuwr/jaeger-demo/sidecar/tracing"
+)
+
+func main() {
+	if err := execute(); err != nil {
+		os.Exit(-1)
+	}
+}
+
+func execute() error {
+	var options ConfigOptions
+
+	service := getenv("SIDECAR_SERVICE", "app")
+	options.HostPort = getenv("SIDECAR_LISTEN", "0.0.0.0:15001")
+	options.AdminHostPort = getenv("SIDECAR_ADMIN", "0.0.0.0:15000")
+	options.TLS.CertFile = os.Getenv("SIDECAR_TLS_CERT")
+	options.TLS.KeyFile = os.Getenv("SIDECAR_TLS_KEY")
+	options.TLS.ClientCAFile = os.Getenv("SIDECAR_TLS_CLIENT_CA")
+
+	rootLogger, _ := zap.NewDevelopment(
+		zap.AddStacktrace(zapcore.FatalLevel),
+		zap.AddCallerSkip(1),
+	)
+	appLogger := rootLogger.With(zap.String("service", service+"-sidecar"))
+	loggerFactory := log.NewFactory(appLogger, zapcore.InfoLevel)
+
+	var err error
+	if options.Upstream, err = url.Parse(getenv("SIDECAR_UPSTREAM", "http://127.0.0.1:8080")); err != nil {
+		return logError(appLogger, err)
+	}
+	if options.Retries, err = strconv.Atoi(getenv("SIDECAR_RETRIES", "2")); err != nil {
+		return logError(appLogger, err)
+	}
+	if options.Retries < 0 {
+		return logError(appLogger, fmt.Errorf("SIDECAR_RETRIES must not be negative, got %d", options.Retries))
+	}
+	if options.ShutdownTimeout, err = time.ParseDuration(getenv("SIDECAR_SHUTDOWN_TIMEOUT", "10s")); err != nil {
+		return logError(appLogger, err)
+	}
+
+	server := NewServer(
+		options,
+		tracing.Init(service+"-sidecar",
+			tracing.WithLogger(loggerFactory),
+			tracing.WithPropagation(getenv("SIDECAR_PROPAGATION", tracing.PropagationJaeger)),
+		),
+		loggerFactory,
+	)
+
+	return logError(appLogger, server.Run())
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func logError(logger *zap.Logger, err error) error {
+	if err != nil {
+		logger.Error("Error running command", zap.Error(err))
+	}
+
+	return err
+}
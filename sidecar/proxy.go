@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/sidecar/log"
+)
+
+// retryBackoff is the delay before the first retry; it doubles on every attempt.
+const retryBackoff = 25 * time.Millisecond
+
+var metrics = expvar.NewMap("sidecar")
+
+// newProxy creates a reverse proxy to the local upstream that retries
+// failed attempts and records a client span for each of them.
+func newProxy(upstream *url.URL, retries int, tracer opentracing.Tracer, logger log.Factory) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	proxy.Transport = &retryTransport{
+		tracer:  tracer,
+		logger:  logger,
+		retries: retries,
+		next:    &nethttp.Transport{},
+	}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		metrics.Add("responses_"+strconv.Itoa(res.StatusCode/100)+"xx", 1)
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		metrics.Add("upstream_errors", 1)
+		logger.For(r.Context()).Error("upstream request failed", zap.Error(err))
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return proxy
+}
+
+// retryTransport retries idempotent requests that fail at the connection
+// level, the way a mesh proxy does by default. Requests that may have side
+// effects, such as POST, are forwarded once: a retry could apply them twice.
+type retryTransport struct {
+	tracer  opentracing.Tracer
+	logger  log.Factory
+	retries int
+	next    http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	metrics.Add("requests", 1)
+
+	retries := t.retries
+	if !replayable(req) {
+		retries = 0
+	}
+
+	var body []byte
+	if retries > 0 && req.Body != nil && req.GetBody == nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	// Retries keep the request ID, by which the upstream can tell them
+	// from new requests.
+	if req.Header.Get(requestIDHeader) == "" {
+		req.Header.Set(requestIDHeader, newRequestID())
+	}
+
+	req, ht := nethttp.TraceRequest(t.tracer, req,
+		nethttp.OperationName("forward "+req.URL.Host),
+		nethttp.ComponentName("sidecar"))
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			b, err := req.GetBody()
+			if err != nil {
+				ht.Finish()
+				return nil, err
+			}
+			req.Body = b
+		} else if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		res, err := t.next.RoundTrip(req)
+		if attempt == retries || !retryable(err) {
+			if err != nil {
+				ht.Finish()
+				return nil, err
+			}
+			ht.Span().SetTag("retries", attempt)
+			res.Body = finishOnClose{res.Body, ht}
+			return res, nil
+		}
+
+		t.logger.For(req.Context()).Info("retrying upstream request", zap.Int("attempt", attempt+1), zap.Error(err))
+		metrics.Add("retries", 1)
+
+		select {
+		case <-time.After(retryBackoff << uint(attempt)):
+		case <-req.Context().Done():
+			ext.Error.Set(ht.Span(), true)
+			ht.Finish()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// requestIDHeader carries the ID of a request, as the frontend names it.
+const requestIDHeader = "X-Request-Id"
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// replayable reports whether req can be sent again without risk: its method
+// is idempotent, or it carries a GetBody to replay its body from.
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut:
+		return true
+	}
+	return req.GetBody != nil
+}
+
+// retryable reports whether err is a connection-level failure. Responses,
+// whatever their status, are returned to the caller as they are.
+func retryable(err error) bool {
+	var opErr *net.OpError
+	return err != nil && errors.As(err, &opErr)
+}
+
+// finishOnClose finishes the forwarding span once the response has been
+// copied back to the caller.
+type finishOnClose struct {
+	io.ReadCloser
+	ht *nethttp.Tracer
+}
+
+func (f finishOnClose) Close() error {
+	err := f.ReadCloser.Close()
+	f.ht.Finish()
+	return err
+}
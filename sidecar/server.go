@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"expvar"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/sidecar/log"
+)
+
+// Server implements the sidecar proxy
+type Server struct {
+	hostPort      string
+	adminHostPort string
+	tracer        opentracing.Tracer
+	logger        log.Factory
+	proxy         http.Handler
+	tls           TLSOptions
+	shutdown      time.Duration
+}
+
+// ConfigOptions describe where the sidecar listens and what it fronts
+type ConfigOptions struct {
+	HostPort      string
+	AdminHostPort string
+	Upstream      *url.URL
+	Retries       int
+	TLS           TLSOptions
+	// ShutdownTimeout bounds how long in-flight requests are drained
+	// after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration
+}
+
+// TLSOptions enable TLS on the listener. If ClientCAFile is set, callers
+// must present a certificate signed by it (mutual TLS).
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// NewServer creates a new sidecar.Server
+func NewServer(options ConfigOptions, tracer opentracing.Tracer, logger log.Factory) *Server {
+	return &Server{
+		hostPort:      options.HostPort,
+		adminHostPort: options.AdminHostPort,
+		tracer:        tracer,
+		logger:        logger,
+		proxy:         newProxy(options.Upstream, options.Retries, tracer, logger),
+		tls:           options.TLS,
+		shutdown:      options.ShutdownTimeout,
+	}
+}
+
+// Run starts the admin endpoint and the proxy, and serves until SIGINT or
+// SIGTERM. It then drains in-flight requests for at most the shutdown
+// timeout and closes the tracer, so buffered spans are flushed.
+func (s *Server) Run() error {
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/debug/vars", expvar.Handler())
+	admin := &http.Server{Addr: s.adminHostPort, Handler: adminMux}
+	go func() {
+		s.logger.Bg().Info("Starting admin", zap.String("address", s.adminHostPort))
+		if err := admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Bg().Error("admin endpoint stopped", zap.Error(err))
+		}
+	}()
+
+	server := &http.Server{
+		Addr: s.hostPort,
+		Handler: nethttp.Middleware(
+			s.tracer,
+			s.proxy,
+			nethttp.OperationNameFunc(func(r *http.Request) string {
+				return "ingress " + r.Method + " " + r.URL.Path
+			}),
+			nethttp.MWComponentName("sidecar"),
+			nethttp.MWSpanObserver(func(span opentracing.Span, r *http.Request) {
+				if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+					span.SetTag("peer.tls.subject", r.TLS.PeerCertificates[0].Subject.String())
+				}
+			})),
+	}
+
+	serve := server.ListenAndServe
+	if s.tls.CertFile == "" {
+		s.logger.Bg().Info("Starting", zap.String("address", "http://"+s.hostPort))
+	} else {
+		tlsConfig, err := s.tls.config()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+		serve = func() error { return server.ListenAndServeTLS(s.tls.CertFile, s.tls.KeyFile) }
+		s.logger.Bg().Info("Starting", zap.String("address", "https://"+s.hostPort), zap.Bool("mtls", s.tls.ClientCAFile != ""))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() { served <- serve() }()
+
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		stop()
+		s.logger.Bg().Info("Shutting down", zap.Duration("timeout", s.shutdown))
+		_ = admin.Close()
+		drainCtx, cancel := context.WithTimeout(context.Background(), s.shutdown)
+		defer cancel()
+		if err = server.Shutdown(drainCtx); err != nil {
+			s.logger.Bg().Error("Cannot drain in-flight requests", zap.Error(err))
+			err = server.Close()
+		}
+	}
+	if closer, ok := s.tracer.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Bg().Error("Cannot flush spans", zap.Error(closeErr))
+		}
+	}
+	return err
+}
+
+func (o TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.ClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(o.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in " + o.ClientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// b3Header is the header go generate writes above the copy of b3.go.
+const b3Header = "// Code generated from frontend/tracing/b3.go by go generate. DO NOT EDIT.\n\n"
+
+// TestB3InSync checks that b3.go is an up to date copy of the frontend's.
+// It is skipped when the module is built on its own, as in its Dockerfile.
+func TestB3InSync(t *testing.T) {
+	original, err := ioutil.ReadFile("../../frontend/tracing/b3.go")
+	if os.IsNotExist(err) {
+		t.Skip("frontend/tracing/b3.go is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile("b3.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, append([]byte(b3Header), original...)) {
+		t.Error("b3.go differs from frontend/tracing/b3.go, run go generate ./tracing")
+	}
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/config"
+	"go.uber.org/zap"
+
+	"github.com/superliuwr/jaeger-demo/sidecar/log"
+)
+
+// Init creates a new instance of Jaeger tracer, configured from the JAEGER_*
+// env vars and opts.
+func Init(serviceName string, opts ...Option) opentracing.Tracer {
+	o := newOptions(opts)
+	logger := o.logger
+
+	// Read host and port from Env Vars
+	cfg, err := config.FromEnv()
+	if err != nil {
+		logger.Bg().Fatal("cannot parse Jaeger env vars", zap.Error(err))
+	}
+
+	cfg.ServiceName = serviceName
+	cfg.Tags = append(cfg.Tags, o.tags...)
+	// Always sample all requests
+	cfg.Sampler.Type = "const"
+	cfg.Sampler.Param = 1
+
+	jaegerLogger := jaegerLoggerAdapter{logger.Bg()}
+
+	restrictions, err := baggageRestrictionsFromEnv()
+	if err != nil {
+		logger.Bg().Fatal("cannot parse baggage restrictions", zap.Error(err))
+	}
+	sampler := o.sampler
+	if sampler == nil {
+		if sampler, err = samplerFromEnv(); err != nil {
+			logger.Bg().Fatal("cannot parse sampling overrides", zap.Error(err))
+		}
+	}
+	headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	propagator, err := newPropagator(o.propagation, headers)
+	if err != nil {
+		logger.Bg().Fatal("cannot create propagator", zap.Error(err))
+	}
+	extractor := newRestrictedExtractor(propagator, headers, restrictions, logger.Bg())
+
+	configOptions := []config.Option{
+		config.Logger(jaegerLogger),
+		config.Injector(opentracing.HTTPHeaders, propagator),
+		config.Extractor(opentracing.HTTPHeaders, extractor),
+		config.Sampler(sampler),
+	}
+	if o.reporter != nil {
+		configOptions = append(configOptions, config.Reporter(o.reporter))
+	}
+	if o.metrics != nil {
+		configOptions = append(configOptions, config.Metrics(o.metrics))
+	}
+	tracer, _, err := cfg.NewTracer(configOptions...)
+	if err != nil {
+		logger.Bg().Fatal("cannot initialize Jaeger Tracer", zap.Error(err))
+	}
+
+	return tracer
+}
+
+type jaegerLoggerAdapter struct {
+	logger log.Logger
+}
+
+func (l jaegerLoggerAdapter) Error(msg string) {
+	l.logger.Error(msg)
+}
+
+func (l jaegerLoggerAdapter) Infof(msg string, args ...interface{}) {
+	l.logger.Info(fmt.Sprintf(msg, args...))
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+// operationSampler samples traces by the operation name of their root span,
+// falling back to a default sampler for operations without an override.
+type operationSampler struct {
+	defaultSampler jaeger.Sampler
+	operations     map[string]jaeger.Sampler
+}
+
+// samplerFromEnv reads per-operation sampling rates from SAMPLING_OPERATIONS,
+// formatted as "operation=rate" pairs separated by commas, for example
+// "HTTP GET /dispatch=1,HTTP GET /debug/vars=0.01". Other operations are
+// always sampled.
+func samplerFromEnv() (jaeger.Sampler, error) {
+	sampler := &operationSampler{
+		defaultSampler: jaeger.NewConstSampler(true),
+		operations:     make(map[string]jaeger.Sampler),
+	}
+	overrides := os.Getenv("SAMPLING_OPERATIONS")
+	if overrides == "" {
+		return sampler, nil
+	}
+	for _, override := range strings.Split(overrides, ",") {
+		i := strings.LastIndex(override, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid sampling override %q, want operation=rate", override)
+		}
+		rate, err := strconv.ParseFloat(override[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampling override %q: %v", override, err)
+		}
+		probabilistic, err := jaeger.NewProbabilisticSampler(rate)
+		if err != nil {
+			return nil, err
+		}
+		sampler.operations[strings.TrimSpace(override[:i])] = probabilistic
+	}
+	return sampler, nil
+}
+
+// IsSampled implements jaeger.Sampler
+func (s *operationSampler) IsSampled(id jaeger.TraceID, operation string) (bool, []jaeger.Tag) {
+	if sampler, ok := s.operations[operation]; ok {
+		return sampler.IsSampled(id, operation)
+	}
+	return s.defaultSampler.IsSampled(id, operation)
+}
+
+// Close implements jaeger.Sampler
+func (s *operationSampler) Close() {
+	s.defaultSampler.Close()
+	for _, sampler := range s.operations {
+		sampler.Close()
+	}
+}
+
+// Equal implements jaeger.Sampler
+func (s *operationSampler) Equal(other jaeger.Sampler) bool {
+	o, ok := other.(*operationSampler)
+	if !ok || !s.defaultSampler.Equal(o.defaultSampler) || len(s.operations) != len(o.operations) {
+		return false
+	}
+	for operation, sampler := range s.operations {
+		if otherSampler, ok := o.operations[operation]; !ok || !sampler.Equal(otherSampler) {
+			return false
+		}
+	}
+	return true
+}